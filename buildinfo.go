@@ -0,0 +1,21 @@
+package jkv
+
+// Commit and BuildDate are set at build time with
+//
+//	go build -ldflags "-X github.com/panduit-joeb/jkv.Commit=$(git rev-parse --short HEAD) -X github.com/panduit-joeb/jkv.BuildDate=$(date -u +%F)"
+var (
+	Commit    string
+	BuildDate string
+)
+
+// BuildVersion returns VERSION followed by the commit and build date when they were set at build time
+func BuildVersion() string {
+	v := VERSION
+	if Commit != "" {
+		v += " commit=" + Commit
+	}
+	if BuildDate != "" {
+		v += " built=" + BuildDate
+	}
+	return v
+}
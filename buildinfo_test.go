@@ -0,0 +1,16 @@
+package jkv_test
+
+import (
+	"testing"
+
+	"github.com/panduit-joeb/jkv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildVersion(t *testing.T) {
+	defer func(commit, date string) { jkv.Commit, jkv.BuildDate = commit, date }(jkv.Commit, jkv.BuildDate)
+	jkv.Commit, jkv.BuildDate = "", ""
+	assert.Equal(t, jkv.VERSION, jkv.BuildVersion())
+	jkv.Commit, jkv.BuildDate = "abc1234", "2024-07-11"
+	assert.Equal(t, jkv.VERSION+" commit=abc1234 built=2024-07-11", jkv.BuildVersion())
+}
@@ -0,0 +1,53 @@
+package jkv
+
+import "sort"
+
+// Capability names a feature that only some backends have, beyond the commands of Client
+type Capability string
+
+const (
+	CapPop           Capability = "pop"            // GETDEL and HPOP
+	CapMeta          Capability = "meta"           // metadata stored alongside a key, SETMETA and GETMETA
+	CapRenamePrefix  Capability = "rename-prefix"  // RENAMEPREFIX
+	CapCompact       Capability = "compact"        // COMPACT
+	CapFsck          Capability = "fsck"           // FSCK
+	CapEncoding      Capability = "encoding"       // OBJECT ENCODING
+	CapMultiDBScan   Capability = "multi-db-scan"  // KEYSALL
+	CapStream        Capability = "stream"         // streaming values with GetTo and SetFrom
+	CapActiveExpire  Capability = "active-expire"  // DEBUG SET-ACTIVE-EXPIRE
+	CapLockStats     Capability = "lock-stats"     // DEBUG LOCKS
+	CapConfigSet     Capability = "config-set"     // CONFIG SET
+	CapChecksum      Capability = "checksum"       // CHECKSUM
+	CapHAppend       Capability = "happend"        // HAPPEND
+	CapTypes         Capability = "types"          // TYPES
+	CapSwap          Capability = "swap"           // SWAP
+	CapReplicaReads  Capability = "replica-reads"  // reads served by a replica
+	CapServerCommand Capability = "server-command" // Do passes any command to a server
+)
+
+// Capabilities is the set of features a backend has
+type Capabilities map[Capability]bool
+
+// NewCapabilities returns a set holding caps
+func NewCapabilities(caps ...Capability) Capabilities {
+	set := Capabilities{}
+	for _, c := range caps {
+		set[c] = true
+	}
+	return set
+}
+
+// Has returns true if the backend has feature c
+func (caps Capabilities) Has(c Capability) bool { return caps[c] }
+
+// List returns the features in the set in order
+func (caps Capabilities) List() []string {
+	list := make([]string, 0, len(caps))
+	for c, ok := range caps {
+		if ok {
+			list = append(list, string(c))
+		}
+	}
+	sort.Strings(list)
+	return list
+}
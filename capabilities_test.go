@@ -0,0 +1,29 @@
+package jkv_test
+
+import (
+	"testing"
+
+	"github.com/panduit-joeb/jkv"
+	"github.com/panduit-joeb/jkv/store/fs"
+	"github.com/panduit-joeb/jkv/store/redis"
+	"github.com/panduit-joeb/jkv/store/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilities(t *testing.T) {
+	a := assert.New(t)
+
+	caps := fs.NewClient(&fs.Options{Addr: t.TempDir()}).Capabilities()
+	a.Equal([]string{"active-expire", "checksum", "compact", "config-set", "encoding", "fsck", "happend", "lock-stats", "meta",
+		"multi-db-scan", "pop", "rename-prefix", "stream", "swap", "types"}, caps.List())
+	a.True(caps.Has(jkv.CapCompact))
+	a.False(caps.Has(jkv.CapReplicaReads))
+
+	rc := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	defer rc.Close()
+	caps = rc.Capabilities()
+	a.Equal([]string{"config-set", "replica-reads", "server-command"}, caps.List())
+	a.False(caps.Has(jkv.CapCompact))
+
+	a.Equal(rc.Capabilities(), retry.New(rc, retry.Policy{}).Capabilities())
+}
@@ -0,0 +1,155 @@
+package jkv
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// DiffEntry is a scalar, or a field of a hash, whose value differs between two databases. A hash field that is
+// missing on one side is reported with an empty value for that side.
+type DiffEntry struct {
+	Key, Field string
+	A, B       string
+}
+
+// DiffReport is the result of comparing database a with database b
+type DiffReport struct {
+	OnlyInA []string
+	OnlyInB []string
+	Changed []DiffEntry
+}
+
+// Equal returns true if no differences were found
+func (r *DiffReport) Equal() bool {
+	return len(r.OnlyInA) == 0 && len(r.OnlyInB) == 0 && len(r.Changed) == 0
+}
+
+// Diff compares every scalar and hash in a with b and reports keys missing on either side and values that differ
+func Diff(ctx context.Context, a, b Client) (*DiffReport, error) {
+	ra := a.Keys(ctx, "*")
+	if ra.Err() != nil {
+		return nil, ra.Err()
+	}
+	rb := b.Keys(ctx, "*")
+	if rb.Err() != nil {
+		return nil, rb.Err()
+	}
+
+	inB := map[string]bool{}
+	for _, key := range rb.Val() {
+		inB[key] = true
+	}
+
+	report := &DiffReport{}
+	inA := map[string]bool{}
+	for _, key := range ra.Val() {
+		inA[key] = true
+		if !inB[key] {
+			report.OnlyInA = append(report.OnlyInA, key)
+			continue
+		}
+		changed, err := diffKey(ctx, a, b, key)
+		if err != nil {
+			return nil, err
+		}
+		report.Changed = append(report.Changed, changed...)
+	}
+	for _, key := range rb.Val() {
+		if !inA[key] {
+			report.OnlyInB = append(report.OnlyInB, key)
+		}
+	}
+
+	sort.Strings(report.OnlyInA)
+	sort.Strings(report.OnlyInB)
+	sort.Slice(report.Changed, func(i, j int) bool {
+		if report.Changed[i].Key != report.Changed[j].Key {
+			return report.Changed[i].Key < report.Changed[j].Key
+		}
+		return report.Changed[i].Field < report.Changed[j].Field
+	})
+	return report, nil
+}
+
+// diffKey compares a key present in both databases
+func diffKey(ctx context.Context, a, b Client, key string) ([]DiffEntry, error) {
+	fa, err := hashFields(ctx, a, key)
+	if err != nil {
+		return nil, err
+	}
+	fb, err := hashFields(ctx, b, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if fa == nil && fb == nil {
+		va, err := scalarValue(ctx, a, key)
+		if err != nil {
+			return nil, err
+		}
+		vb, err := scalarValue(ctx, b, key)
+		if err != nil {
+			return nil, err
+		}
+		if va != vb {
+			return []DiffEntry{{Key: key, A: va, B: vb}}, nil
+		}
+		return nil, nil
+	}
+
+	if fa == nil || fb == nil {
+		// one side is a scalar and the other is a hash
+		entry := DiffEntry{Key: key, A: "(hash)", B: "(hash)"}
+		if fa == nil {
+			entry.A, _ = scalarValue(ctx, a, key)
+		} else {
+			entry.B, _ = scalarValue(ctx, b, key)
+		}
+		return []DiffEntry{entry}, nil
+	}
+
+	var changed []DiffEntry
+	for field, va := range fa {
+		if vb, ok := fb[field]; !ok || va != vb {
+			changed = append(changed, DiffEntry{Key: key, Field: field, A: va, B: vb})
+		}
+	}
+	for field, vb := range fb {
+		if _, ok := fa[field]; !ok {
+			changed = append(changed, DiffEntry{Key: key, Field: field, B: vb})
+		}
+	}
+	return changed, nil
+}
+
+// hashFields returns the fields and values of a hash, or nil if key is not a hash. A field deleted or expired between
+// listing the fields and reading it is left out.
+func hashFields(ctx context.Context, c Client, key string) (map[string]string, error) {
+	rec := c.HKeys(ctx, key)
+	if rec.Err() != nil {
+		if ErrorCode(rec.Err()) == "WRONGTYPE" {
+			return nil, nil // a scalar, as Redis reports it
+		}
+		return nil, rec.Err()
+	}
+	fields := map[string]string{}
+	for _, field := range rec.Val() {
+		value := c.HGet(ctx, key, field)
+		if errors.Is(value.Err(), Nil) {
+			continue
+		} else if value.Err() != nil {
+			return nil, value.Err()
+		}
+		fields[field] = value.Val()
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+func scalarValue(ctx context.Context, c Client, key string) (string, error) {
+	rec := c.Get(ctx, key)
+	return rec.Val(), rec.Err()
+}
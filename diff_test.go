@@ -0,0 +1,84 @@
+package jkv_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/panduit-joeb/jkv"
+	"github.com/panduit-joeb/jkv/store/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	src := fs.NewClient(&fs.Options{Addr: t.TempDir()})
+	dst := fs.NewClient(&fs.Options{Addr: t.TempDir()})
+	a.Nil(src.Open())
+	a.Nil(dst.Open())
+	defer src.Close()
+	defer dst.Close()
+
+	for _, c := range []*fs.Client{src, dst} {
+		c.Set(ctx, "same", "value", 0)
+		c.HSet(ctx, "hash", "one", "1", "two", "2")
+	}
+	src.Set(ctx, "changed", "old", 0)
+	dst.Set(ctx, "changed", "new", 0)
+	src.Set(ctx, "gone", "x", 0)
+	dst.Set(ctx, "added", "y", 0)
+	dst.HSet(ctx, "hash", "two", "too")
+
+	report, err := jkv.Diff(ctx, src, dst)
+	a.Nil(err)
+	a.False(report.Equal())
+	a.Equal([]string{"gone"}, report.OnlyInA)
+	a.Equal([]string{"added"}, report.OnlyInB)
+	a.Equal([]jkv.DiffEntry{
+		{Key: "changed", A: "old", B: "new"},
+		{Key: "hash", Field: "two", A: "2", B: "too"},
+	}, report.Changed)
+
+	report, err = jkv.Diff(ctx, src, src)
+	a.Nil(err)
+	a.True(report.Equal())
+}
+
+// hgetErrors fails HGet of the fields in errs with their errors
+type hgetErrors struct {
+	jkv.Client
+	errs map[string]error
+}
+
+func (c hgetErrors) HGet(ctx context.Context, hash, field string) *jkv.StringCmd {
+	if err, ok := c.errs[field]; ok {
+		return jkv.NewStringCmd("", err)
+	}
+	return c.Client.HGet(ctx, hash, field)
+}
+
+func TestDiffHashErrors(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	src := fs.NewClient(&fs.Options{Addr: t.TempDir()})
+	dst := fs.NewClient(&fs.Options{Addr: t.TempDir()})
+	a.Nil(src.Open())
+	a.Nil(dst.Open())
+	defer src.Close()
+	defer dst.Close()
+	for _, c := range []*fs.Client{src, dst} {
+		c.HSet(ctx, "hash", "one", "1", "two", "2")
+	}
+
+	// a field that is gone by the time it is read is missing, not empty
+	report, err := jkv.Diff(ctx, hgetErrors{src, map[string]error{"two": jkv.Nil}}, dst)
+	a.Nil(err)
+	a.Equal([]jkv.DiffEntry{{Key: "hash", Field: "two", B: "2"}}, report.Changed)
+
+	// other errors are returned
+	_, err = jkv.Diff(ctx, hgetErrors{src, map[string]error{"two": os.ErrPermission}}, dst)
+	a.ErrorIs(err, os.ErrPermission)
+}
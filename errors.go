@@ -0,0 +1,65 @@
+package jkv
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrReadOnly    = errors.New("READONLY You can't write against a read only replica.")
+	ErrNameTooLong = errors.New("ERR key or field name is too long")
+	ErrNotInteger  = errors.New("ERR value is not an integer or out of range")
+	ErrOverflow    = errors.New("ERR increment or decrement would overflow")
+	ErrWrongType   = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	ErrLockTimeout = errors.New("BUSY timed out waiting for a lock")
+	// ErrKeyNotFound is returned when reading a key or field that doesn't exist, telling it apart from an empty value
+	ErrKeyNotFound = errors.New("jkv: key not found")
+	// Nil is ErrKeyNotFound under the name go-redis uses, so errors.Is(err, jkv.Nil) detects a miss on every backend
+	Nil = ErrKeyNotFound
+	// ErrNoKey is returned by Migrate when the key doesn't exist in the source, like the NOKEY reply of MIGRATE
+	ErrNoKey = errors.New("NOKEY No such key")
+	// ErrBusyKey is returned by Migrate when the key exists in the destination and Replace isn't set
+	ErrBusyKey = errors.New("BUSYKEY Target key name already exists.")
+)
+
+// errorCodes are the codes of the sentinel errors, for ErrorCode
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrReadOnly, "READONLY"},
+	{ErrNameTooLong, "ERR"},
+	{ErrNotInteger, "ERR"},
+	{ErrOverflow, "ERR"},
+	{ErrWrongType, "WRONGTYPE"},
+	{ErrLockTimeout, "BUSY"},
+	{ErrKeyNotFound, "NOTFOUND"},
+	{ErrNoKey, "NOKEY"},
+	{ErrBusyKey, "BUSYKEY"},
+}
+
+// ErrorCode returns a stable code for err that callers can branch on instead of the message, like the WRONGTYPE or
+// READONLY a Redis error starts with. Errors that aren't jkv sentinels get the code their message starts with, or
+// ERR if it doesn't start with one.
+func ErrorCode(err error) string {
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	if code, _ := SplitCode(err.Error()); code != "" {
+		return code
+	}
+	return "ERR"
+}
+
+// SplitCode splits a Redis style error message into the upper case code it starts with and the rest, code is empty
+// if it doesn't start with one. Codes are at least three letters, so a message starting with a word like DB isn't
+// mistaken for one.
+func SplitCode(msg string) (code, rest string) {
+	word, rest, _ := strings.Cut(msg, " ")
+	if len(word) < 3 || strings.ToUpper(word) != word || strings.ToLower(word) == word {
+		return "", msg
+	}
+	return word, rest
+}
@@ -0,0 +1,30 @@
+package jkv_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/panduit-joeb/jkv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCode(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal("WRONGTYPE", jkv.ErrorCode(jkv.ErrWrongType))
+	a.Equal("WRONGTYPE", jkv.ErrorCode(fmt.Errorf("pfadd: %w", jkv.ErrWrongType)))
+	a.Equal("READONLY", jkv.ErrorCode(jkv.ErrReadOnly))
+	a.Equal("BUSY", jkv.ErrorCode(jkv.ErrLockTimeout))
+	a.Equal("ERR", jkv.ErrorCode(jkv.ErrNotInteger))
+	a.Equal("NOTFOUND", jkv.ErrorCode(jkv.Nil))
+	a.Equal("NOSCRIPT", jkv.ErrorCode(errors.New("NOSCRIPT No matching script")))
+	a.Equal("ERR", jkv.ErrorCode(errors.New("open /tmp/x: permission denied")))
+
+	code, rest := jkv.SplitCode("WRONGTYPE Operation against a key")
+	a.Equal("WRONGTYPE", code)
+	a.Equal("Operation against a key", rest)
+	code, rest = jkv.SplitCode("DB is not open")
+	a.Equal("", code)
+	a.Equal("DB is not open", rest)
+}
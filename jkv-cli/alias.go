@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// aliases maps an upper case alias to the command and leading arguments it stands for
+var aliases = map[string][]string{}
+
+// expandAlias replaces an alias at the start of tokens with what it stands for. Aliases are only expanded once, and
+// defineAlias refuses an alias of an alias, so expansion can't loop.
+func expandAlias(tokens []string) []string {
+	expansion, ok := aliases[strings.ToUpper(tokens[0])]
+	if !ok {
+		return tokens
+	}
+	return append(append([]string{}, expansion...), tokens[1:]...)
+}
+
+// defineAlias makes alias stand for command
+func defineAlias(alias string, command []string) error {
+	name, target := strings.ToUpper(alias), strings.ToUpper(command[0])
+	switch {
+	case name == "ALIAS" || name == "UNALIAS":
+		return fmt.Errorf("ERR %s can't be aliased", name)
+	case name == target:
+		return fmt.Errorf("ERR alias '%s' refers to itself", alias)
+	}
+	if _, ok := aliases[target]; ok {
+		return fmt.Errorf("ERR '%s' is an alias, aliases of aliases are not allowed", command[0])
+	}
+	for other, expansion := range aliases {
+		if strings.ToUpper(expansion[0]) == name {
+			return fmt.Errorf("ERR alias '%s' already refers to '%s'", strings.ToLower(other), alias)
+		}
+	}
+	if current, ok := aliases[name]; ok && strings.Join(current, " ") != strings.Join(command, " ") {
+		return fmt.Errorf("ERR alias '%s' is already defined as '%s', UNALIAS it first", alias, strings.Join(current, " "))
+	}
+	aliases[name] = command
+	return nil
+}
+
+// aliasLines lists the aliases as "alias command" lines in alias order
+func aliasLines() []string {
+	lines := make([]string, 0, len(aliases))
+	for name, command := range aliases {
+		lines = append(lines, strings.ToLower(name)+" "+strings.Join(command, " "))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// loadAliases reads ALIAS commands from the file named path, one per line. Blank lines and lines starting with #
+// are skipped, and a missing file is not an error.
+func loadAliases(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		tokens := strings.Fields(scanner.Text())
+		if len(tokens) == 0 || strings.HasPrefix(tokens[0], "#") {
+			continue
+		}
+		if strings.ToUpper(tokens[0]) != "ALIAS" || len(tokens) < 3 {
+			return fmt.Errorf("%s:%d: expected ALIAS name command", path, n)
+		}
+		if err := defineAlias(tokens[1], tokens[2:]); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var errUnbalancedQuotes = errors.New("ERR unbalanced quotes in request")
+
+// splitArgs splits a command line into arguments at white space like strings.Fields, except that an argument
+// starting with a quote runs to the matching quote and may hold spaces. Inside double quotes \" \\ \n \r \t \b \a
+// and \xHH are escapes as in redis-cli, inside single quotes only \'. A quote anywhere but the start of an argument
+// is kept as it is, so unquoted input splits the same as it always did. Arguments are cut out of line byte for byte,
+// so invalid UTF-8 in a value is kept as it is.
+func splitArgs(line string) ([]string, error) {
+	args := []string{}
+	for i := 0; i < len(line); {
+		if r, size := utf8.DecodeRuneInString(line[i:]); unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+		if q := line[i]; q == '"' || q == '\'' {
+			arg, n, err := quoted(line[i:])
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			i += n
+			continue
+		}
+		start := i
+		for i < len(line) && !spaceAt(line, i) {
+			_, size := utf8.DecodeRuneInString(line[i:])
+			i += size
+		}
+		args = append(args, line[start:i])
+	}
+	return args, nil
+}
+
+// spaceAt returns true if the character starting at byte i of s is white space
+func spaceAt(s string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return unicode.IsSpace(r)
+}
+
+// quoted returns the argument in the quoted string s starts with and the number of bytes it took up, which must be
+// followed by white space or the end of the line. Quotes and escapes are ASCII, which is never part of a multibyte
+// character, so s is scanned a byte at a time and everything else is copied through unchanged.
+func quoted(s string) (string, int, error) {
+	q := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == q:
+			if i+1 < len(s) && !spaceAt(s, i+1) {
+				return "", 0, errUnbalancedQuotes
+			}
+			return b.String(), i + 1, nil
+		case ch == '\\' && i+1 < len(s) && q == '\'':
+			if s[i+1] == '\'' {
+				i++
+			}
+			b.WriteByte(s[i])
+		case ch == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'b':
+				b.WriteByte('\b')
+			case 'a':
+				b.WriteByte('\a')
+			case 'x':
+				if i+2 < len(s) {
+					if n, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+						b.WriteByte(byte(n))
+						i += 2
+						continue
+					}
+				}
+				b.WriteByte('x')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return "", 0, errUnbalancedQuotes
+}
+
+// joinArgs joins the arguments the CLI was run with into a line splitArgs splits back into the same arguments,
+// quoting the ones that are empty, hold white space or start with a quote
+func joinArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && strings.IndexFunc(arg, unicode.IsSpace) < 0 && arg[0] != '"' && arg[0] != '\'' {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+	}
+	return strings.Join(quoted, " ")
+}
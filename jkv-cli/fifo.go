@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// Batch mode exits at the end of its input, -fifo keeps going: when the last writer closes the FIFO it is opened
+// again, which blocks until the next writer comes along, so jkv-cli can sit at the end of a pipeline as a sink.
+
+// fifoWakeInterval is how often a FIFO is poked to unblock an open of it once serveFIFO has been told to stop
+var fifoWakeInterval = 50 * time.Millisecond
+
+// serveFIFO runs the commands written to the FIFO at path, one per line, until ctx is done
+func serveFIFO(ctx context.Context, db jkv.Client, path string, opt_x bool) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		return fmt.Errorf("%s is not a FIFO", path)
+	}
+
+	// opening a FIFO to read blocks until there is a writer, so once ctx is done keep opening it to write until
+	// the loop below notices
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stopped:
+			return
+		}
+		ticker := time.NewTicker(fifoWakeInterval)
+		defer ticker.Stop()
+		for {
+			if f, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+				f.Close()
+			}
+			select {
+			case <-ticker.C:
+			case <-stopped:
+				return
+			}
+		}
+	}()
+
+	for ctx.Err() == nil {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(f)
+		for ctx.Err() == nil && scanner.Scan() {
+			ProcessCmd(db, scanner.Text(), opt_x, true)
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
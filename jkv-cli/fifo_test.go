@@ -0,0 +1,50 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFIFO(t *testing.T) {
+	db := newTestDB(t)
+	path := t.TempDir() + "/commands"
+	assert.Nil(t, syscall.Mkfifo(path, 0600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	var out string
+	go func() {
+		var err error
+		out = capture(t, func() { err = serveFIFO(ctx, db, path, false) })
+		done <- err
+	}()
+
+	// each writer opens the FIFO, writes and closes it, and serveFIFO carries on with the next one
+	for _, commands := range []string{"SET one 1\nSET two 2\n", "GET one\nHSET hash field value\n"} {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		assert.Nil(t, err)
+		f.WriteString(commands)
+		f.Close()
+	}
+	assert.Eventually(t, func() bool { return db.HGet(ctx, "hash", "field").Val() == "value" }, time.Second,
+		10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serveFIFO didn't stop")
+	}
+	assert.Equal(t, "OK\nOK\n\"1\"\n1\n", out)
+	assert.Equal(t, "2", db.Get(context.Background(), "two").Val())
+
+	assert.ErrorContains(t, serveFIFO(context.Background(), db, t.TempDir(), false), "not a FIFO")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencySamples is how many of the most recent durations are kept for each command
+const latencySamples = 1024
+
+// latencyRecorder keeps a ring buffer of recent durations for each command
+type latencyRecorder struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+	next    map[string]int
+}
+
+var latency = newLatencyRecorder()
+
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{samples: map[string][]time.Duration{}, next: map[string]int{}}
+}
+
+func (l *latencyRecorder) record(cmd string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.samples[cmd]) < latencySamples {
+		l.samples[cmd] = append(l.samples[cmd], d)
+		return
+	}
+	l.samples[cmd][l.next[cmd]] = d
+	l.next[cmd] = (l.next[cmd] + 1) % latencySamples
+}
+
+func (l *latencyRecorder) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.samples = map[string][]time.Duration{}
+	l.next = map[string]int{}
+}
+
+// report returns a line of min/p50/p99/max for each command, sorted by command
+func (l *latencyRecorder) report() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var cmds []string
+	for cmd := range l.samples {
+		cmds = append(cmds, cmd)
+	}
+	sort.Strings(cmds)
+
+	var lines []string
+	for _, cmd := range cmds {
+		d := append([]time.Duration{}, l.samples[cmd]...)
+		sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+		percentile := func(p int) time.Duration { return d[(len(d)-1)*p/100] }
+		lines = append(lines, fmt.Sprintf("%s: samples=%d min=%s p50=%s p99=%s max=%s",
+			strings.ToLower(cmd), len(d), d[0], percentile(50), percentile(99), d[len(d)-1]))
+	}
+	return lines
+}
@@ -2,12 +2,24 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"math"
+	"net/url"
 	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/panduit-joeb/jkv"
 	"github.com/panduit-joeb/jkv/store/fs"
@@ -23,7 +35,7 @@ func main() {
 	// fmt.Println("cmd is", cmd)
 
 	var redis_cmd, fs_cmd, version, opt_x, prompt, info bool
-	var redis_host, db_dir string
+	var redis_host, db_dir, fifo string
 	flag.BoolVar(&redis_cmd, "r", cmd == "redis-cli", "Run JKV tests using Redis")
 	flag.BoolVar(&fs_cmd, "f", cmd == "jkv-cli", "Run JKV tests using FS")
 	flag.BoolVar(&version, "v", false, "Print version")
@@ -31,10 +43,17 @@ func main() {
 	flag.BoolVar(&info, "i", false, "Get DBDir, etc.")
 	flag.StringVar(&redis_host, "h", redis.DEFAULT_DB, "Redis server host and port")
 	flag.StringVar(&db_dir, "d", fs.DEFAULT_DB, "Location of FS DB")
+	flag.BoolVar(&envValues, "env", false, "HSET values written $NAME are read from the environment variable NAME")
+	flag.BoolVar(&jsonErrors, "json", false, "Print errors as JSON objects with a type, code and message")
+	flag.StringVar(&fifo, "fifo", "", "Read commands from the FIFO at this path until interrupted")
+	flag.Func("default", "Value GET and HGET print for a missing key or field instead of (nil)", func(value string) error {
+		missDefault = &value
+		return nil
+	})
 	flag.Parse()
 
 	if version {
-		fmt.Println(jkv.VERSION)
+		fmt.Println(jkv.BuildVersion())
 		os.Exit(0)
 	}
 
@@ -52,11 +71,28 @@ func main() {
 	}
 	db.Open()
 
+	if home, err := os.UserHomeDir(); err == nil {
+		if err := loadAliases(home + "/.jkvrc"); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
 	if info {
 		fmt.Println(db.GetDBDir())
 		os.Exit(0)
 	}
 
+	if fifo != "" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err := serveFIFO(ctx, db, fifo, opt_x)
+		stop()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(exitStatus)
+	}
+
 	if prompt {
 		scanner := bufio.NewScanner(os.Stdin)
 
@@ -70,19 +106,146 @@ func main() {
 			fmt.Println("Error reading input:", err)
 		}
 	} else {
-		ProcessCmd(db, strings.Join(flag.Args(), " "), opt_x, isPipe())
+		ProcessCmd(db, joinArgs(flag.Args()), opt_x, isPipe())
+		os.Exit(exitStatus)
+	}
+}
+
+// requires maps commands, and subcommands after a space, to the capability a backend needs to run them
+var requires = map[string]jkv.Capability{
+	"GETDEL":                  jkv.CapPop,
+	"HPOP":                    jkv.CapPop,
+	"SETMETA":                 jkv.CapMeta,
+	"GETMETA":                 jkv.CapMeta,
+	"RENAMEPREFIX":            jkv.CapRenamePrefix,
+	"COMPACT":                 jkv.CapCompact,
+	"FSCK":                    jkv.CapFsck,
+	"OBJECT ENCODING":         jkv.CapEncoding,
+	"KEYSALL":                 jkv.CapMultiDBScan,
+	"DEBUG SET-ACTIVE-EXPIRE": jkv.CapActiveExpire,
+	"DEBUG LOCKS":             jkv.CapLockStats,
+	"CONFIG SET":              jkv.CapConfigSet,
+	"CHECKSUM":                jkv.CapChecksum,
+	"HAPPEND":                 jkv.CapHAppend,
+	"TYPES":                   jkv.CapTypes,
+	"SWAP":                    jkv.CapSwap,
+}
+
+// supported returns false if the command in tokens needs a capability db doesn't have
+func supported(db jkv.Client, tokens []string) bool {
+	name := strings.ToUpper(tokens[0])
+	c, ok := requires[name]
+	if !ok && len(tokens) > 1 {
+		c, ok = requires[name+" "+strings.ToUpper(tokens[1])]
+	}
+	return !ok || db.Capabilities().Has(c)
+}
+
+// missDefault is what GET and HGET print for a missing key or field, set by --default, nil to print (nil)
+var missDefault *string
+
+// printMiss prints the reply of a GET or HGET that failed with err
+func printMiss(err error) {
+	if missDefault != nil && errors.Is(err, jkv.ErrKeyNotFound) {
+		fmt.Printf("\"%s\"\n", *missDefault)
+	} else {
+		fmt.Println("(nil)")
+	}
+}
+
+// envValues is set by --env, HSET then takes a value written $NAME from the environment variable NAME so secrets
+// needn't appear on the command line
+var envValues bool
+
+// substituteEnv returns the field value pairs with each value written $NAME replaced by the environment variable
+// NAME, which must be set
+func substituteEnv(pairs []string) ([]string, error) {
+	out := append([]string(nil), pairs...)
+	for i := 1; i < len(out); i += 2 {
+		if name := strings.TrimPrefix(out[i], "$"); name != out[i] && name != "" {
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				return nil, fmt.Errorf("ERR environment variable %s is not set", name)
+			}
+			out[i] = value
+		}
 	}
+	return out, nil
 }
 
+// exitStatus is set to 1 by commands that fail in a way a script running the CLI should notice
+var exitStatus int
+
 func ProcessCmd(db jkv.Client, cmd string, opt_x, is_pipe bool) {
-	tokens := strings.Fields(cmd)
+	tokens, err := splitArgs(cmd)
+	if err != nil {
+		report("(error)", err.Error(), is_pipe)
+		return
+	}
 	if len(tokens) == 0 {
 		return
 	}
+	tokens = expandAlias(tokens)
 	ctx := context.Background()
-	switch strings.ToUpper(tokens[0]) {
+	name := strings.ToUpper(tokens[0])
+	if name != "LATENCY" && name != "PROFILE" {
+		defer func(start time.Time) {
+			d := time.Since(start)
+			latency.record(name, d)
+			profile.record(name, d)
+		}(time.Now())
+	}
+	if !supported(db, tokens) {
+		report("(error)", "ERR command not supported by this backend", is_pipe)
+		return
+	}
+	switch name {
 	case "PING":
 		fmt.Println("PONG")
+	case "RESET":
+		// the CLI keeps no transaction, selected DB or subscription state yet, so there is nothing to discard
+		if len(tokens) == 1 {
+			fmt.Println("RESET")
+		} else {
+			report("(error)", "ERR wrong number of arguments for 'reset' command", is_pipe)
+		}
+	case "ALIAS":
+		switch len(tokens) {
+		case 1:
+			printLines(aliasLines(), is_pipe)
+		case 2:
+			report("(error)", "ERR wrong number of arguments for 'alias' command", is_pipe)
+		default:
+			if err := defineAlias(tokens[1], tokens[2:]); err != nil {
+				report("(error)", err.Error(), is_pipe)
+			} else {
+				fmt.Println("OK")
+			}
+		}
+	case "UNALIAS":
+		if len(tokens) != 2 {
+			report("(error)", "ERR wrong number of arguments for 'unalias' command", is_pipe)
+			return
+		}
+		n := 0
+		if _, ok := aliases[strings.ToUpper(tokens[1])]; ok {
+			delete(aliases, strings.ToUpper(tokens[1]))
+			n = 1
+		}
+		report("(integer)", strconv.Itoa(n), is_pipe)
+	case "CAPABILITIES":
+		if len(tokens) != 1 {
+			report("(error)", "ERR wrong number of arguments for 'capabilities' command", is_pipe)
+			return
+		}
+		printList(db.Capabilities().List(), is_pipe)
+	case "INFO":
+		rec := db.Info(ctx, tokens[1:]...)
+		if rec.Err() != nil {
+			report("(error)", "ERR "+rec.Err().Error(), is_pipe)
+		} else {
+			fmt.Print(strings.ReplaceAll(rec.Val(), "\r\n", "\n"))
+		}
 	case "FLUSHDB":
 		if len(tokens) == 1 {
 			db.FlushDB(ctx)
@@ -94,7 +257,7 @@ func ProcessCmd(db jkv.Client, cmd string, opt_x, is_pipe bool) {
 		if len(tokens) == 3 {
 			rec := db.HGet(ctx, tokens[1], tokens[2])
 			if rec.Err() != nil {
-				fmt.Println("(nil)")
+				printMiss(rec.Err())
 			} else {
 				fmt.Printf("\"%s\"\n", rec.Val())
 			}
@@ -104,28 +267,60 @@ func ProcessCmd(db jkv.Client, cmd string, opt_x, is_pipe bool) {
 	case "HSET":
 		ctx := context.Background()
 		if opt_x {
-			if len(tokens) == 3 {
-				var buf = make([]byte, 1024*1024)
-				var n = 0
-				n, err := os.Stdin.Read(buf)
-				if n == 0 {
-					if err != io.EOF {
-						panic(err.Error())
+			if len(tokens) == 2 {
+				// field<TAB>value pairs, one per line, all set by one HSET
+				input, err := readValue(stdin)
+				if err != nil {
+					report("(error)", "ERR "+err.Error(), is_pipe)
+					return
+				}
+				pairs, errs := parsePairs(input)
+				if len(errs) > 0 {
+					for _, err := range errs {
+						report("(error)", "ERR "+err.Error(), is_pipe)
 					}
+					exitStatus = 1
+					return
+				}
+				if len(pairs) == 0 {
+					return
+				}
+				rec := db.HSet(ctx, tokens[1], pairs...)
+				if rec.Err() != nil {
+					report("(error)", rec.Err().Error(), is_pipe)
+					exitStatus = 1
+					return
+				}
+				report("(integer)", fmt.Sprintf("%d", rec.Val()), is_pipe)
+			} else if len(tokens) == 3 {
+				value, err := readValue(stdin)
+				if err != nil {
+					report("(error)", "ERR "+err.Error(), is_pipe)
+					return
+				}
+				if value == "" {
 					return
 				}
-				// buf = []byte("hello")
 
 				hash := tokens[1]
 				key := tokens[2]
-				rec := db.HSet(ctx, hash, key, string(buf))
+				rec := db.HSet(ctx, hash, key, value)
 				report("(integer)", fmt.Sprintf("%d", rec.Val()), is_pipe)
 			} else {
 				report("(error)", "ERR wrong number of arguments for 'hset' command", is_pipe)
 			}
 		} else {
 			if len(tokens) > 2 {
-				rec := db.HSet(ctx, tokens[1], tokens[2:]...)
+				pairs := tokens[2:]
+				if envValues {
+					var err error
+					if pairs, err = substituteEnv(pairs); err != nil {
+						report("(error)", err.Error(), is_pipe)
+						exitStatus = 1
+						return
+					}
+				}
+				rec := db.HSet(ctx, tokens[1], pairs...)
 				if rec.Err() != nil {
 					fmt.Println(rec.Err().Error())
 					return
@@ -150,21 +345,24 @@ func ProcessCmd(db jkv.Client, cmd string, opt_x, is_pipe bool) {
 			}
 		}
 	case "HDEL":
-		if len(tokens) == 3 {
-			ctx := context.Background()
-			rec := db.HDel(ctx, tokens[1], tokens[2])
-			if rec.Err() != nil {
-				fmt.Println("(nil)")
-			} else {
-				report("(integer)", fmt.Sprintf("%d", rec.Val()), is_pipe)
-			}
+		if len(tokens) < 3 {
+			report("(error)", "ERR wrong number of arguments for 'hdel' command", is_pipe)
+			return
+		}
+		if rec := db.HDel(ctx, tokens[1], tokens[2:]...); rec.Err() != nil {
+			report("(error)", "ERR "+rec.Err().Error(), is_pipe)
 		} else {
-			fmt.Println("(nil)")
+			report("(integer)", fmt.Sprintf("%d", rec.Val()), is_pipe)
 		}
 	case "HKEYS":
-		if len(tokens) == 2 {
+		if len(tokens) == 2 || len(tokens) == 4 && strings.ToUpper(tokens[2]) == "MATCH" {
 			ctx := context.Background()
-			rec := db.HKeys(ctx, tokens[1])
+			var rec *jkv.StringSliceCmd
+			if len(tokens) == 4 {
+				rec = db.HKeysMatch(ctx, tokens[1], tokens[3])
+			} else {
+				rec = db.HKeys(ctx, tokens[1])
+			}
 			if rec.Err() != nil {
 				if os.IsNotExist(rec.Err()) {
 					report("(empty array)", "", is_pipe)
@@ -175,17 +373,35 @@ func ProcessCmd(db jkv.Client, cmd string, opt_x, is_pipe bool) {
 				if len(rec.Val()) == 0 {
 					report("(empty array)", "", is_pipe)
 				}
-				for i, v := range rec.Val() {
-					if is_pipe {
-						fmt.Println(v)
-					} else {
-						fmt.Printf("%d) \"%s\"\n", i+1, v)
-					}
-				}
+				printList(rec.Val(), is_pipe)
 			}
 		} else {
 			report("(error)", "ERR wrong number of arguments for 'hkeys' command", is_pipe)
 		}
+	case "HGETALL":
+		if len(tokens) != 2 {
+			report("(error)", "ERR wrong number of arguments for 'hgetall' command", is_pipe)
+			return
+		}
+		rec := db.HGetAll(ctx, tokens[1])
+		if rec.Err() != nil {
+			report("(error)", "ERR "+rec.Err().Error(), is_pipe)
+			return
+		}
+		if len(rec.Val()) == 0 {
+			report("(empty array)", "", is_pipe)
+			return
+		}
+		fields := make([]string, 0, len(rec.Val()))
+		for field := range rec.Val() {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		pairs := make([]string, 0, 2*len(fields))
+		for _, field := range fields {
+			pairs = append(pairs, field, rec.Val()[field])
+		}
+		printList(pairs, is_pipe)
 	case "HEXISTS":
 		if len(tokens) == 3 {
 			ctx := context.Background()
@@ -198,45 +414,131 @@ func ProcessCmd(db jkv.Client, cmd string, opt_x, is_pipe bool) {
 		} else {
 			report("(error)", "ERR wrong number of arguments for 'exists' command", is_pipe)
 		}
+	case "EXPIRE":
+		if len(tokens) != 3 {
+			report("(error)", "ERR wrong number of arguments for 'expire' command", is_pipe)
+			return
+		}
+		seconds, err := strconv.ParseInt(tokens[2], 10, 64)
+		if err != nil {
+			report("(error)", "ERR value is not an integer or out of range", is_pipe)
+			return
+		}
+		rec := db.Expire(ctx, tokens[1], time.Duration(seconds)*time.Second)
+		if rec.Err() != nil {
+			report("(error)", "ERR "+rec.Err().Error(), is_pipe)
+		} else if rec.Val() {
+			report("(integer)", "1", is_pipe)
+		} else {
+			report("(integer)", "0", is_pipe)
+		}
+	case "TTL":
+		if len(tokens) != 2 {
+			report("(error)", "ERR wrong number of arguments for 'ttl' command", is_pipe)
+			return
+		}
+		rec := db.TTL(ctx, tokens[1])
+		if rec.Err() != nil {
+			report("(error)", "ERR "+rec.Err().Error(), is_pipe)
+		} else {
+			report("(integer)", fmt.Sprintf("%d", rec.Val()), is_pipe)
+		}
+	case "HEXPIRE":
+		if len(tokens) < 6 {
+			report("(error)", "ERR wrong number of arguments for 'hexpire' command", is_pipe)
+			return
+		}
+		seconds, err := strconv.ParseInt(tokens[2], 10, 64)
+		if err != nil {
+			report("(error)", "ERR value is not an integer or out of range", is_pipe)
+			return
+		}
+		fields, err := parseFields(tokens[3:])
+		if err != nil {
+			report("(error)", err.Error(), is_pipe)
+			return
+		}
+		rec := db.HExpire(ctx, tokens[1], seconds, fields...)
+		if rec.Err() != nil {
+			report("(error)", "ERR "+rec.Err().Error(), is_pipe)
+		} else {
+			printInts(rec.Val(), is_pipe)
+		}
+	case "HTTL":
+		if len(tokens) < 5 {
+			report("(error)", "ERR wrong number of arguments for 'httl' command", is_pipe)
+			return
+		}
+		fields, err := parseFields(tokens[2:])
+		if err != nil {
+			report("(error)", err.Error(), is_pipe)
+			return
+		}
+		rec := db.HTTL(ctx, tokens[1], fields...)
+		if rec.Err() != nil {
+			report("(error)", "ERR "+rec.Err().Error(), is_pipe)
+		} else {
+			printInts(rec.Val(), is_pipe)
+		}
 	case "GET":
 		if len(tokens) == 2 {
 			ctx := context.Background()
 			rec := db.Get(ctx, tokens[1])
 			if rec.Err() != nil {
-				fmt.Println("(nil)")
+				printMiss(rec.Err())
 			} else {
 				fmt.Printf("\"%s\"\n", rec.Val())
 			}
 		} else {
 			fmt.Println("(nil)")
 		}
+	case "GETEX":
+		if len(tokens) < 2 {
+			report("(error)", "ERR wrong number of arguments for 'getex' command", is_pipe)
+			return
+		}
+		opts, err := parseExpiry(tokens[2:])
+		if err != nil {
+			report("(error)", err.Error(), is_pipe)
+			return
+		}
+		rec := db.GetEX(ctx, tokens[1], opts)
+		if rec.Err() != nil {
+			fmt.Println("(nil)")
+		} else {
+			fmt.Printf("\"%s\"\n", rec.Val())
+		}
 	case "SET":
 		if opt_x {
 			if len(tokens) == 2 {
 				ctx := context.Background()
-				var buf = make([]byte, 1024*1024)
-				var n = 0
-				n, err := os.Stdin.Read(buf)
-				if n == 0 {
-					if err != io.EOF {
-						panic(err.Error())
-					}
+				value, err := readValue(stdin)
+				if err != nil {
+					report("(error)", "ERR "+err.Error(), is_pipe)
+					return
+				}
+				if value == "" {
 					return
 				}
 				key := tokens[1]
-				rec := db.Set(ctx, key, string(buf[:n-1]), 0)
+				rec := db.Set(ctx, key, strings.TrimSuffix(value, "\n"), 0)
 				if rec.Err() != nil {
 					fmt.Println("(nil)")
 				} else {
 					fmt.Println("OK")
 				}
 			} else {
-				fmt.Println("(error) ERR wrong number of arguments for 'set' command")
+				report("(error)", "ERR wrong number of arguments for 'set' command", false)
 			}
 		} else {
-			if len(tokens) == 3 {
+			if len(tokens) >= 3 {
+				expiration, err := setExpiration(tokens[3:])
+				if err != nil {
+					report("(error)", err.Error(), is_pipe)
+					return
+				}
 				ctx := context.Background()
-				rec := db.Set(ctx, tokens[1], tokens[2], 0)
+				rec := db.Set(ctx, tokens[1], tokens[2], expiration)
 				if rec.Err() != nil {
 					fmt.Println("(nil)")
 				} else {
@@ -246,17 +548,121 @@ func ProcessCmd(db jkv.Client, cmd string, opt_x, is_pipe bool) {
 				report("(error)", "ERR wrong number of arguments for 'set' command", is_pipe)
 			}
 		}
+	case "INCR", "DECR":
+		if len(tokens) != 2 {
+			report("(error)", "ERR wrong number of arguments for '"+strings.ToLower(name)+"' command", is_pipe)
+			return
+		}
+		incr := db.Incr
+		if name == "DECR" {
+			incr = db.Decr
+		}
+		rec := incr(ctx, tokens[1])
+		if rec.Err() != nil {
+			report("(error)", rec.Err().Error(), is_pipe)
+		} else {
+			report("(integer)", fmt.Sprintf("%d", rec.Val()), is_pipe)
+		}
+	case "SETBIT":
+		if len(tokens) != 4 {
+			report("(error)", "ERR wrong number of arguments for 'setbit' command", is_pipe)
+			return
+		}
+		offset, err := strconv.ParseInt(tokens[2], 10, 64)
+		if err != nil || offset < 0 || offset > math.MaxUint32 {
+			report("(error)", "ERR bit offset is not an integer or out of range", is_pipe)
+			return
+		}
+		value, err := strconv.Atoi(tokens[3])
+		if err != nil || value < 0 || value > 1 {
+			report("(error)", "ERR bit is not an integer or out of range", is_pipe)
+			return
+		}
+		rec := db.SetBit(ctx, tokens[1], offset, value)
+		if rec.Err() != nil {
+			report("(error)", rec.Err().Error(), is_pipe)
+		} else {
+			report("(integer)", fmt.Sprintf("%d", rec.Val()), is_pipe)
+		}
+	case "GETBIT":
+		if len(tokens) != 3 {
+			report("(error)", "ERR wrong number of arguments for 'getbit' command", is_pipe)
+			return
+		}
+		offset, err := strconv.ParseInt(tokens[2], 10, 64)
+		if err != nil || offset < 0 || offset > math.MaxUint32 {
+			report("(error)", "ERR bit offset is not an integer or out of range", is_pipe)
+			return
+		}
+		rec := db.GetBit(ctx, tokens[1], offset)
+		if rec.Err() != nil {
+			report("(error)", rec.Err().Error(), is_pipe)
+		} else {
+			report("(integer)", fmt.Sprintf("%d", rec.Val()), is_pipe)
+		}
+	case "BITCOUNT":
+		var bitCount *jkv.BitCount
+		if len(tokens) == 4 {
+			start, err1 := strconv.ParseInt(tokens[2], 10, 64)
+			end, err2 := strconv.ParseInt(tokens[3], 10, 64)
+			if err1 != nil || err2 != nil {
+				report("(error)", "ERR value is not an integer or out of range", is_pipe)
+				return
+			}
+			bitCount = &jkv.BitCount{Start: start, End: end}
+		} else if len(tokens) != 2 {
+			report("(error)", "ERR wrong number of arguments for 'bitcount' command", is_pipe)
+			return
+		}
+		rec := db.BitCount(ctx, tokens[1], bitCount)
+		if rec.Err() != nil {
+			report("(error)", rec.Err().Error(), is_pipe)
+		} else {
+			report("(integer)", fmt.Sprintf("%d", rec.Val()), is_pipe)
+		}
+	case "PFADD":
+		if len(tokens) < 2 {
+			report("(error)", "ERR wrong number of arguments for 'pfadd' command", is_pipe)
+			return
+		}
+		rec := db.PFAdd(ctx, tokens[1], tokens[2:]...)
+		if rec.Err() != nil {
+			report("(error)", rec.Err().Error(), is_pipe)
+		} else {
+			report("(integer)", fmt.Sprintf("%d", rec.Val()), is_pipe)
+		}
+	case "PFCOUNT":
+		if len(tokens) < 2 {
+			report("(error)", "ERR wrong number of arguments for 'pfcount' command", is_pipe)
+			return
+		}
+		rec := db.PFCount(ctx, tokens[1:]...)
+		if rec.Err() != nil {
+			report("(error)", rec.Err().Error(), is_pipe)
+		} else {
+			report("(integer)", fmt.Sprintf("%d", rec.Val()), is_pipe)
+		}
+	case "PFMERGE":
+		if len(tokens) < 2 {
+			report("(error)", "ERR wrong number of arguments for 'pfmerge' command", is_pipe)
+			return
+		}
+		if rec := db.PFMerge(ctx, tokens[1], tokens[2:]...); rec.Err() != nil {
+			report("(error)", rec.Err().Error(), is_pipe)
+		} else {
+			fmt.Println("OK")
+		}
 	case "DEL":
 		if len(tokens) >= 2 {
 			ctx := context.Background()
 			rec := db.Del(ctx, tokens[1:]...)
 			if rec.Err() != nil {
-				fmt.Println("(nil)", rec.Err().Error())
+				report("(error)", "ERR "+rec.Err().Error(), is_pipe)
 			} else {
 				report("(integer)", fmt.Sprintf("%d", rec.Val()), is_pipe)
 			}
 		} else {
-			fmt.Println("(nil)")
+			report("(error)", "ERR wrong number of arguments for 'del' command", is_pipe)
 		}
 	case "KEYS":
 		if len(tokens) == 2 {
@@ -268,45 +674,865 @@ func ProcessCmd(db jkv.Client, cmd string, opt_x, is_pipe bool) {
 				if len(rec.Val()) == 0 {
 					report("(empty array)", "", is_pipe)
 				} else {
-					for i, v := range rec.Val() {
-						if is_pipe {
-							fmt.Println(v)
-						} else {
-							fmt.Printf("%d) \"%s\"\n", i+1, v)
-						}
-					}
+					printList(rec.Val(), is_pipe)
 				}
 			}
 		} else {
 			report("(error)", "ERR wrong number of arguments for 'keys' command", is_pipe)
 		}
+	case "TTLKEYS":
+		if len(tokens) != 2 {
+			report("(error)", "ERR wrong number of arguments for 'ttlkeys' command", is_pipe)
+			return
+		}
+		rec := db.Keys(ctx, tokens[1])
+		if rec.Err() != nil {
+			report("(error)", "ERR "+rec.Err().Error(), is_pipe)
+			return
+		}
+		if len(rec.Val()) == 0 {
+			report("(empty array)", "", is_pipe)
+			return
+		}
+		lines := make([]string, 0, len(rec.Val()))
+		for _, key := range rec.Val() {
+			ttl := db.Do(ctx, "TTL", key)
+			if ttl.Err() != nil {
+				report("(error)", "ERR "+ttl.Err().Error(), is_pipe)
+				return
+			}
+			seconds, _ := ttl.Val().(int64)
+			if seconds == -2 {
+				continue // expired since it was listed
+			}
+			line := key + " " + ttlNote(seconds)
+			if !is_pipe {
+				line = fmt.Sprintf("%d) \"%s\" %s", len(lines)+1, key, ttlNote(seconds))
+			}
+			lines = append(lines, line)
+		}
+		printLines(lines, is_pipe)
+	case "SCAN":
+		if len(tokens) < 2 || len(tokens)%2 != 0 {
+			report("(error)", "ERR wrong number of arguments for 'scan' command", is_pipe)
+			return
+		}
+		match, count, keyType := "*", int64(10), ""
+		for i := 2; i < len(tokens); i += 2 {
+			switch strings.ToUpper(tokens[i]) {
+			case "MATCH":
+				match = tokens[i+1]
+			case "TYPE":
+				keyType = tokens[i+1]
+			case "COUNT":
+				n, err := strconv.ParseInt(tokens[i+1], 10, 64)
+				if err != nil || n <= 0 {
+					report("(error)", "ERR value is not an integer or out of range", is_pipe)
+					return
+				}
+				count = n
+			default:
+				report("(error)", "ERR syntax error", is_pipe)
+				return
+			}
+		}
+		rec := db.ScanType(ctx, tokens[1], match, count, keyType)
+		if rec.Err() != nil {
+			report("(error)", rec.Err().Error(), is_pipe)
+			return
+		}
+		keys, cursor := rec.Val()
+		lines := []string{cursor}
+		if !is_pipe {
+			lines[0] = fmt.Sprintf("1) \"%s\"", cursor)
+			if len(keys) == 0 {
+				lines = append(lines, "2) (empty array)")
+			}
+		}
+		for i, key := range keys {
+			if is_pipe {
+				lines = append(lines, key)
+			} else if i == 0 {
+				lines = append(lines, fmt.Sprintf("2) %d) \"%s\"", i+1, key))
+			} else {
+				lines = append(lines, fmt.Sprintf("   %d) \"%s\"", i+1, key))
+			}
+		}
+		printLines(lines, is_pipe)
 	case "EXISTS":
 		if len(tokens) >= 2 {
-			ctx := context.Background()
-			var n int64
-			for _, token := range tokens[1:] {
-				rec := db.Exists(ctx, token)
-				if rec.Err() != nil {
-					// fmt.Println("(nil)")
-					break
-				}
-				n = n + rec.Val()
+			if rec := db.Exists(ctx, tokens[1:]...); rec.Err() != nil {
+				report("(error)", "ERR "+rec.Err().Error(), is_pipe)
+			} else {
+				report("(integer)", fmt.Sprintf("%d", rec.Val()), is_pipe)
 			}
-			report("(integer)", fmt.Sprintf("%d", n), is_pipe)
 		} else {
 			report("(error)", "ERR wrong number of arguments for 'exists' command", is_pipe)
 		}
+	case "EDIT":
+		if len(tokens) == 2 {
+			changed, err := editValue(ctx, db, tokens[1])
+			if err != nil {
+				report("(error)", "ERR "+err.Error(), is_pipe)
+			} else if changed {
+				fmt.Println("OK")
+			} else {
+				report("(nil)", "", is_pipe)
+			}
+		} else {
+			report("(error)", "ERR wrong number of arguments for 'edit' command", is_pipe)
+		}
+	case "TREE":
+		limit := 20
+		if len(tokens) == 2 {
+			n, err := strconv.Atoi(tokens[1])
+			if err != nil || n < 0 {
+				report("(error)", "ERR value is not an integer or out of range", is_pipe)
+				return
+			}
+			limit = n
+		} else if len(tokens) > 2 {
+			report("(error)", "ERR wrong number of arguments for 'tree' command", is_pipe)
+			return
+		}
+		if err := printTree(ctx, db, limit); err != nil {
+			report("(error)", "ERR "+err.Error(), is_pipe)
+		}
+	case "DEBUG":
+		if len(tokens) == 2 && strings.ToUpper(tokens[1]) == "LOCKS" {
+			f, ok := db.(*fs.Client)
+			if !ok {
+				report("(error)", "ERR DEBUG LOCKS is not supported by this backend", is_pipe)
+				return
+			}
+			stats := f.DebugStats(ctx)
+			fmt.Printf("lock_waits:%d\n", stats.LockWaits)
+			fmt.Printf("lock_wait_time_us:%d\n", stats.LockWaitTime.Microseconds())
+		} else if len(tokens) == 3 && strings.ToUpper(tokens[1]) == "SET-ACTIVE-EXPIRE" {
+			f, ok := db.(*fs.Client)
+			if !ok {
+				report("(error)", "ERR DEBUG SET-ACTIVE-EXPIRE is not supported by this backend", is_pipe)
+				return
+			}
+			if tokens[2] != "0" && tokens[2] != "1" {
+				report("(error)", "ERR value is not an integer or out of range", is_pipe)
+				return
+			}
+			f.SetActiveExpire(tokens[2] == "1")
+			fmt.Println("OK")
+		} else {
+			report("(error)", "ERR unknown subcommand or wrong number of arguments for 'debug' command", is_pipe)
+		}
+	case "CHECKSUM":
+		if len(tokens) != 1 {
+			report("(error)", "ERR wrong number of arguments for 'checksum' command", is_pipe)
+			return
+		}
+		f, ok := db.(*fs.Client)
+		if !ok {
+			report("(error)", "ERR CHECKSUM is not supported by this backend", is_pipe)
+			return
+		}
+		if rec := f.Checksum(ctx); rec.Err() != nil {
+			report("(error)", "ERR "+rec.Err().Error(), is_pipe)
+		} else if is_pipe {
+			fmt.Println(rec.Val())
+		} else {
+			fmt.Printf("\"%s\"\n", rec.Val())
+		}
+	case "TYPES":
+		if len(tokens) != 1 {
+			report("(error)", "ERR wrong number of arguments for 'types' command", is_pipe)
+			return
+		}
+		f, ok := db.(*fs.Client)
+		if !ok {
+			report("(error)", "ERR TYPES is not supported by this backend", is_pipe)
+			return
+		}
+		rec := f.Types(ctx)
+		if rec.Err() != nil {
+			report("(error)", "ERR "+rec.Err().Error(), is_pipe)
+			return
+		}
+		printLines(typeTable(rec.Val()), is_pipe)
+	case "HAPPEND":
+		if len(tokens) != 4 {
+			report("(error)", "ERR wrong number of arguments for 'happend' command", is_pipe)
+			return
+		}
+		f, ok := db.(*fs.Client)
+		if !ok {
+			report("(error)", "ERR HAPPEND is not supported by this backend", is_pipe)
+			return
+		}
+		if rec := f.HAppend(ctx, tokens[1], tokens[2], tokens[3]); rec.Err() != nil {
+			report("(error)", "ERR "+rec.Err().Error(), is_pipe)
+		} else {
+			report("(integer)", fmt.Sprintf("%d", rec.Val()), is_pipe)
+		}
+	case "COMPACT":
+		if len(tokens) != 1 {
+			report("(error)", "ERR wrong number of arguments for 'compact' command", is_pipe)
+			return
+		}
+		f, ok := db.(*fs.Client)
+		if !ok {
+			report("(error)", "ERR COMPACT is not supported by this backend", is_pipe)
+			return
+		}
+		if rec := f.Compact(ctx); rec.Err() != nil {
+			report("(error)", "ERR "+rec.Err().Error(), is_pipe)
+		} else {
+			report("(integer)", fmt.Sprintf("%d", rec.Val()), is_pipe)
+		}
+	case "FSCK":
+		repair := len(tokens) == 2 && strings.ToUpper(tokens[1]) == "REPAIR"
+		if len(tokens) > 1 && !repair {
+			report("(error)", "ERR syntax error", is_pipe)
+			return
+		}
+		f, ok := db.(*fs.Client)
+		if !ok {
+			report("(error)", "ERR FSCK is not supported by this backend", is_pipe)
+			return
+		}
+		problems, err := f.Fsck(ctx, repair)
+		if err != nil {
+			report("(error)", "ERR "+err.Error(), is_pipe)
+		} else if len(problems) == 0 {
+			fmt.Println("OK")
+		} else {
+			printList(problems, is_pipe)
+		}
+	case "GETDEL", "HPOP":
+		name := strings.ToLower(tokens[0])
+		if name == "getdel" && len(tokens) != 2 || name == "hpop" && len(tokens) != 3 {
+			report("(error)", "ERR wrong number of arguments for '"+name+"' command", is_pipe)
+			return
+		}
+		f, ok := db.(*fs.Client)
+		if !ok {
+			report("(error)", "ERR "+strings.ToUpper(name)+" is not supported by this backend", is_pipe)
+			return
+		}
+		var rec *jkv.StringCmd
+		if name == "getdel" {
+			rec = f.PopScalar(ctx, tokens[1])
+		} else {
+			rec = f.HPop(ctx, tokens[1], tokens[2])
+		}
+		if rec.Err() != nil {
+			fmt.Println("(nil)")
+		} else {
+			fmt.Printf("\"%s\"\n", rec.Val())
+		}
+	case "SETMETA":
+		if len(tokens) < 3 || len(tokens)%2 == 0 {
+			report("(error)", "ERR wrong number of arguments for 'setmeta' command", is_pipe)
+			return
+		}
+		f, ok := db.(*fs.Client)
+		if !ok {
+			report("(error)", "ERR SETMETA is not supported by this backend", is_pipe)
+			return
+		}
+		meta := map[string]string{}
+		for i := 3; i < len(tokens); i += 2 {
+			meta[tokens[i]] = tokens[i+1]
+		}
+		if rec := f.SetWithMeta(ctx, tokens[1], tokens[2], meta); rec.Err() != nil {
+			report("(error)", "ERR "+rec.Err().Error(), is_pipe)
+		} else {
+			fmt.Println(rec.Val())
+		}
+	case "GETMETA":
+		if len(tokens) != 2 {
+			report("(error)", "ERR wrong number of arguments for 'getmeta' command", is_pipe)
+			return
+		}
+		f, ok := db.(*fs.Client)
+		if !ok {
+			report("(error)", "ERR GETMETA is not supported by this backend", is_pipe)
+			return
+		}
+		value, meta, err := f.GetWithMeta(ctx, tokens[1])
+		if err != nil {
+			fmt.Println("(nil)")
+			return
+		}
+		names := make([]string, 0, len(meta))
+		for name := range meta {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		values := []string{value}
+		for _, name := range names {
+			values = append(values, name, meta[name])
+		}
+		printList(values, is_pipe)
+	case "MONITOR":
+		if len(tokens) != 3 || strings.ToUpper(tokens[1]) != "KEY" {
+			report("(error)", "ERR wrong number of arguments for 'monitor' command, use MONITOR KEY key", is_pipe)
+			return
+		}
+		ctx, cancel := monitorContext()
+		defer cancel()
+		monitorKey(ctx, db, tokens[2])
+	case "RENAMEPREFIX":
+		if len(tokens) != 3 {
+			report("(error)", "ERR wrong number of arguments for 'renameprefix' command", is_pipe)
+			return
+		}
+		f, ok := db.(*fs.Client)
+		if !ok {
+			report("(error)", "ERR RENAMEPREFIX is not supported by this backend", is_pipe)
+			return
+		}
+		if rec := f.RenamePrefix(ctx, tokens[1], tokens[2]); rec.Err() != nil {
+			report("(error)", rec.Err().Error(), is_pipe)
+		} else {
+			report("(integer)", fmt.Sprintf("%d", rec.Val()), is_pipe)
+		}
+	case "SWAP":
+		if len(tokens) != 3 {
+			report("(error)", "ERR wrong number of arguments for 'swap' command", is_pipe)
+			return
+		}
+		f, ok := db.(*fs.Client)
+		if !ok {
+			report("(error)", "ERR SWAP is not supported by this backend", is_pipe)
+			return
+		}
+		if rec := f.Swap(ctx, tokens[1], tokens[2]); rec.Err() != nil {
+			report("(error)", rec.Err().Error(), is_pipe)
+		} else {
+			fmt.Println(rec.Val())
+		}
+	case "VERSION":
+		if len(tokens) != 1 {
+			report("(error)", "ERR wrong number of arguments for 'version' command", is_pipe)
+			return
+		}
+		rec := db.Version(ctx)
+		if rec.Err() != nil {
+			report("(error)", rec.Err().Error(), is_pipe)
+		} else {
+			fmt.Printf("\"%s\"\n", rec.Val())
+		}
+	case "CONFIG":
+		if len(tokens) == 4 && strings.ToUpper(tokens[1]) == "SET" {
+			var err error
+			if f, ok := db.(*fs.Client); ok {
+				err = f.SetOption(ctx, tokens[2], tokens[3])
+			} else {
+				err = db.Do(ctx, "config", "set", tokens[2], tokens[3]).Err()
+			}
+			if err != nil {
+				report("(error)", err.Error(), is_pipe)
+			} else {
+				fmt.Println("OK")
+			}
+			return
+		}
+		if len(tokens) != 3 || strings.ToUpper(tokens[1]) != "GET" {
+			report("(error)", "ERR unknown subcommand or wrong number of arguments for 'config' command", is_pipe)
+			return
+		}
+		rec := db.ConfigGet(ctx, tokens[2])
+		if rec.Err() != nil {
+			report("(error)", rec.Err().Error(), is_pipe)
+			return
+		}
+		names := make([]string, 0, len(rec.Val()))
+		for name := range rec.Val() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		values := []string{}
+		for _, name := range names {
+			values = append(values, name, rec.Val()[name])
+		}
+		if len(values) == 0 {
+			report("(empty array)", "", is_pipe)
+		}
+		printList(values, is_pipe)
+	case "KEYSALL":
+		if len(tokens) != 2 {
+			report("(error)", "ERR wrong number of arguments for 'keysall' command", is_pipe)
+			return
+		}
+		f, ok := db.(*fs.Client)
+		if !ok {
+			report("(error)", "ERR KEYSALL is not supported by this backend", is_pipe)
+			return
+		}
+		keys, err := f.ScanAllDBs(ctx, tokens[1])
+		if err != nil {
+			report("(error)", "ERR "+err.Error(), is_pipe)
+			return
+		}
+		lines := make([]string, len(keys))
+		for i, k := range keys {
+			if is_pipe {
+				lines[i] = fmt.Sprintf("%d\t%s", k.DB, k.Key)
+			} else {
+				lines[i] = fmt.Sprintf("%d) \"%s\"", k.DB, k.Key)
+			}
+		}
+		printLines(lines, is_pipe)
+	case "OBJECT":
+		if len(tokens) != 3 || strings.ToUpper(tokens[1]) != "ENCODING" {
+			report("(error)", "ERR unknown subcommand or wrong number of arguments for 'object' command", is_pipe)
+			return
+		}
+		f, ok := db.(*fs.Client)
+		if !ok {
+			report("(error)", "ERR OBJECT is not supported by this backend", is_pipe)
+			return
+		}
+		if rec := f.Encoding(ctx, tokens[2]); rec.Err() != nil {
+			fmt.Println("(nil)")
+		} else {
+			fmt.Printf("\"%s\"\n", rec.Val())
+		}
+	case "LATENCY":
+		if len(tokens) == 1 {
+			lines := latency.report()
+			if len(lines) == 0 {
+				report("(empty array)", "", is_pipe)
+			}
+			printLines(lines, is_pipe)
+		} else if len(tokens) == 2 && strings.ToUpper(tokens[1]) == "RESET" {
+			latency.reset()
+			fmt.Println("OK")
+		} else {
+			report("(error)", "ERR unknown subcommand or wrong number of arguments for 'latency' command", is_pipe)
+		}
+	case "PROFILE":
+		if len(tokens) != 2 {
+			report("(error)", "ERR unknown subcommand or wrong number of arguments for 'profile' command", is_pipe)
+			return
+		}
+		switch strings.ToUpper(tokens[1]) {
+		case "ON":
+			profile.start()
+			fmt.Println("OK")
+		case "OFF":
+			profile.stop()
+			fmt.Println("OK")
+		case "REPORT":
+			lines := profile.report()
+			if len(lines) == 0 {
+				report("(empty array)", "", is_pipe)
+			}
+			printLines(lines, is_pipe)
+		default:
+			report("(error)", "ERR unknown subcommand or wrong number of arguments for 'profile' command", is_pipe)
+		}
+	case "MIGRATE":
+		if len(tokens) < 3 {
+			report("(error)", "ERR wrong number of arguments for 'migrate' command", is_pipe)
+			return
+		}
+		var opts jkv.MigrateOptions
+		for _, option := range tokens[3:] {
+			switch strings.ToUpper(option) {
+			case "COPY":
+				opts.Copy = true
+			case "REPLACE":
+				opts.Replace = true
+			default:
+				report("(error)", "ERR syntax error", is_pipe)
+				return
+			}
+		}
+		dst, err := openDSN(tokens[1])
+		if err != nil {
+			report("(error)", "ERR "+err.Error(), is_pipe)
+			return
+		}
+		defer dst.Close()
+		if err := jkv.Migrate(ctx, db, dst, tokens[2], opts); errors.Is(err, jkv.ErrNoKey) {
+			fmt.Println("NOKEY")
+		} else if err != nil {
+			report("(error)", err.Error(), is_pipe)
+		} else {
+			fmt.Println("OK")
+		}
+	case "DIFF":
+		if len(tokens) == 3 {
+			a, err := openDSN(tokens[1])
+			if err != nil {
+				report("(error)", "ERR "+err.Error(), is_pipe)
+				return
+			}
+			defer a.Close()
+			b, err := openDSN(tokens[2])
+			if err != nil {
+				report("(error)", "ERR "+err.Error(), is_pipe)
+				return
+			}
+			defer b.Close()
+			diff, err := jkv.Diff(ctx, a, b)
+			if err != nil {
+				report("(error)", "ERR "+err.Error(), is_pipe)
+				return
+			}
+			if diff.Equal() {
+				report("(empty array)", "", is_pipe)
+				return
+			}
+			for _, key := range diff.OnlyInA {
+				fmt.Printf("< %s\n", key)
+			}
+			for _, key := range diff.OnlyInB {
+				fmt.Printf("> %s\n", key)
+			}
+			for _, e := range diff.Changed {
+				if e.Field == "" {
+					fmt.Printf("~ %s \"%s\" \"%s\"\n", e.Key, e.A, e.B)
+				} else {
+					fmt.Printf("~ %s %s \"%s\" \"%s\"\n", e.Key, e.Field, e.A, e.B)
+				}
+			}
+		} else {
+			report("(error)", "ERR wrong number of arguments for 'diff' command", is_pipe)
+		}
 	default:
 		report("(error)", fmt.Sprintf("ERR unknown command '%s', with args beginning with:\n", tokens[0]), is_pipe)
 	}
 }
 
+// editValue opens the value of key in $EDITOR and stores the result, returning false if it was saved unchanged. A
+// nonzero exit from the editor aborts the edit. The key keeps its TTL.
+func editValue(ctx context.Context, db jkv.Client, key string) (bool, error) {
+	rec := db.Get(ctx, key)
+	if rec.Err() != nil && !errors.Is(rec.Err(), jkv.Nil) {
+		return false, rec.Err()
+	}
+	exists := rec.Err() == nil
+	old := rec.Val()
+
+	f, err := os.CreateTemp("", "jkv-edit-*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(old)
+	f.Close()
+	if err != nil {
+		return false, err
+	}
+
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+	cmd := exec.Command(editor[0], append(editor[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return false, fmt.Errorf("edit aborted, %s", err.Error())
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return false, err
+	}
+	if exists && string(data) == old {
+		return false, nil
+	}
+	return true, db.Set(ctx, key, string(data), jkv.KeepTTL).Err()
+}
+
+// printTree prints the scalars and hashes in db as a tree with at most limit fields shown for each hash
+func printTree(ctx context.Context, db jkv.Client, limit int) error {
+	rec := db.Keys(ctx, "*")
+	if rec.Err() != nil {
+		return rec.Err()
+	}
+	keys := rec.Val()
+	sort.Strings(keys)
+	fmt.Println(db.GetDBDir())
+	for _, key := range keys {
+		fields := db.HKeys(ctx, key)
+		if fields.Err() != nil || len(fields.Val()) == 0 {
+			fmt.Println("  " + key)
+			continue
+		}
+		fmt.Println("  " + key + "/")
+		names := fields.Val()
+		sort.Strings(names)
+		for i, name := range names {
+			if i == limit {
+				fmt.Printf("    ... %d more\n", len(names)-limit)
+				break
+			}
+			fmt.Println("    " + name)
+		}
+	}
+	return nil
+}
+
+// ttlNote describes a TTL in seconds as returned by TTL for TTLKEYS
+func ttlNote(seconds int64) string {
+	if seconds < 0 {
+		return "(no expiry)"
+	}
+	return fmt.Sprintf("(ttl: %ds)", seconds)
+}
+
+// typeTable lays out the counts of TYPES in a column, sorted by type
+func typeTable(counts map[string]int64) []string {
+	types, width := []string{}, 0
+	for t := range counts {
+		types = append(types, t)
+		if len(t) > width {
+			width = len(t)
+		}
+	}
+	sort.Strings(types)
+	lines := make([]string, len(types))
+	for i, t := range types {
+		lines[i] = fmt.Sprintf("%-*s %d", width, t, counts[t])
+	}
+	return lines
+}
+
+// parseFields parses the FIELDS numfields field ... arguments of HEXPIRE and HTTL
+func parseFields(tokens []string) ([]string, error) {
+	if strings.ToUpper(tokens[0]) != "FIELDS" {
+		return nil, errors.New("ERR Mandatory argument FIELDS is missing or not at the right position")
+	}
+	n, err := strconv.Atoi(tokens[1])
+	if err != nil || n != len(tokens)-2 {
+		return nil, errors.New("ERR The `numfields` parameter must match the number of arguments")
+	}
+	return tokens[2:], nil
+}
+
+func printInts(values []int64, is_pipe bool) {
+	for i, v := range values {
+		if is_pipe {
+			fmt.Println(v)
+		} else {
+			fmt.Printf("%d) (integer) %d\n", i+1, v)
+		}
+	}
+}
+
+// parseExpiry parses the EX seconds, PX milliseconds, EXAT timestamp, PXAT timestamp or PERSIST options of GETEX
+func parseExpiry(tokens []string) (opts jkv.ExpiryOptions, err error) {
+	if len(tokens) == 0 {
+		return opts, nil
+	}
+	option := strings.ToUpper(tokens[0])
+	if option == "PERSIST" && len(tokens) == 1 {
+		opts.Persist = true
+		return opts, nil
+	}
+	if len(tokens) != 2 {
+		return opts, errors.New("ERR syntax error")
+	}
+	n, err := strconv.ParseInt(tokens[1], 10, 64)
+	if err != nil || n <= 0 {
+		return opts, errors.New("ERR invalid expire time in 'getex' command")
+	}
+	switch option {
+	case "EX":
+		opts.EX = time.Duration(n) * time.Second
+	case "PX":
+		opts.PX = time.Duration(n) * time.Millisecond
+	case "EXAT":
+		opts.EXAT = time.Unix(n, 0)
+	case "PXAT":
+		opts.PXAT = time.UnixMilli(n)
+	default:
+		return opts, errors.New("ERR syntax error")
+	}
+	return opts, nil
+}
+
+// setExpiration parses the [EX seconds|PX milliseconds|KEEPTTL|PERSIST] options of SET
+func setExpiration(tokens []string) (time.Duration, error) {
+	switch {
+	case len(tokens) == 0:
+		return 0, nil
+	case len(tokens) == 1 && strings.ToUpper(tokens[0]) == "KEEPTTL":
+		return jkv.KeepTTL, nil
+	case len(tokens) == 1 && strings.ToUpper(tokens[0]) == "PERSIST":
+		return jkv.NoExpiration, nil
+	case len(tokens) != 2:
+		return 0, errors.New("ERR syntax error")
+	}
+	n, err := strconv.ParseInt(tokens[1], 10, 64)
+	if err != nil || n <= 0 {
+		return 0, errors.New("ERR invalid expire time in 'set' command")
+	}
+	switch strings.ToUpper(tokens[0]) {
+	case "EX":
+		return time.Duration(n) * time.Second, nil
+	case "PX":
+		return time.Duration(n) * time.Millisecond, nil
+	}
+	return 0, errors.New("ERR syntax error")
+}
+
+// openDSN opens a database named by fs:///path/to/db or redis://[:password@]host:port[/db]
+func openDSN(dsn string) (jkv.Client, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	var db jkv.Client
+	switch u.Scheme {
+	case "fs":
+		dir := u.Path
+		if dir == "" {
+			dir = u.Opaque
+		}
+		db = fs.NewClient(&fs.Options{Addr: dir})
+	case "redis":
+		password, _ := u.User.Password()
+		n := 0
+		if p := strings.TrimPrefix(u.Path, "/"); p != "" {
+			if n, err = strconv.Atoi(p); err != nil {
+				return nil, fmt.Errorf("bad redis DB number in %s", dsn)
+			}
+		}
+		db = redis.NewClient(&redis.Options{Addr: u.Host, Password: password, DB: n})
+	default:
+		return nil, fmt.Errorf("unknown DSN scheme in %s, use fs:// or redis://", dsn)
+	}
+	return db, db.Open()
+}
+
+// printList prints values as a numbered list, or one per line under a pipe
+func printList(values []string, is_pipe bool) {
+	lines := make([]string, len(values))
+	for i, v := range values {
+		if is_pipe {
+			lines[i] = v
+		} else {
+			lines[i] = fmt.Sprintf("%d) \"%s\"", i+1, v)
+		}
+	}
+	printLines(lines, is_pipe)
+}
+
+// printLines prints lines, through $PAGER when stdout is a terminal they won't fit on
+func printLines(lines []string, is_pipe bool) {
+	if len(lines) == 0 {
+		return
+	}
+	text := strings.Join(lines, "\n") + "\n"
+	if !is_pipe && len(lines) >= terminalHeight() {
+		if pager := os.Getenv("PAGER"); pager != "" && runPager(pager, text) == nil {
+			return
+		}
+	}
+	fmt.Print(text)
+}
+
+// runPager shows text with the pager command
+var runPager = func(pager, text string) error {
+	cmd := exec.Command("/bin/sh", "-c", pager)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = strings.NewReader(text), os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// terminalHeight returns the number of lines on the terminal from $LINES or stty, or a large number if unknown
+var terminalHeight = func() int {
+	if n, err := strconv.Atoi(os.Getenv("LINES")); err == nil && n > 0 {
+		return n
+	}
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	if out, err := cmd.Output(); err == nil {
+		if size := strings.Fields(string(out)); len(size) == 2 {
+			if n, err := strconv.Atoi(size[0]); err == nil && n > 0 {
+				return n
+			}
+		}
+	}
+	return math.MaxInt32
+}
+
+// stdin is where -x reads values from
+var stdin io.Reader = os.Stdin
+
+// maxPooledBuffer is the largest buffer returned to valuePool, larger ones are left to the garbage collector
+const maxPooledBuffer = 64 * 1024
+
+var valuePool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// readValue reads all of r for -x using a pooled buffer so repeated small values don't allocate a new buffer each
+// time
+func readValue(r io.Reader) (string, error) {
+	buf := valuePool.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			buf.Reset()
+			valuePool.Put(buf)
+		}
+	}()
+	if _, err := buf.ReadFrom(r); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// parsePairs splits lines of field<TAB>value into HSET arguments, returning an error for each line without a tab
+func parsePairs(input string) (pairs []string, errs []error) {
+	for i, line := range strings.Split(strings.TrimSuffix(input, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		field, value, ok := strings.Cut(line, "\t")
+		if !ok {
+			errs = append(errs, fmt.Errorf("line %d: no tab between field and value", i+1))
+			continue
+		}
+		pairs = append(pairs, field, value)
+	}
+	return pairs, errs
+}
+
 func isPipe() bool {
 	fi, _ := os.Stdout.Stat()
 	return (fi.Mode() & os.ModeCharDevice) == 0
 }
 
+// jsonErrors is set by --json, errors are then printed as JSON objects for wrappers to branch on
+var jsonErrors bool
+
+// jsonError is how --json prints an error reply
+type jsonError struct {
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// errorJSON returns the error reply msg as a JSON object. The code is the one msg starts with, looking past an ERR
+// the CLI put in front of a coded error from the store, e.g. "ERR WRONGTYPE Operation ..." has the code WRONGTYPE.
+func errorJSON(msg string) string {
+	code, rest := jkv.SplitCode(msg)
+	if code == "ERR" {
+		if inner, innerRest := jkv.SplitCode(rest); inner != "" {
+			code, rest = inner, innerRest
+		}
+	} else if code == "" {
+		code = "ERR"
+	}
+	data, _ := json.Marshal(jsonError{Type: "error", Code: code, Message: rest})
+	return string(data)
+}
+
 func report(prefix, msg string, is_pipe bool) {
+	if jsonErrors && prefix == "(error)" {
+		fmt.Println(errorJSON(msg))
+		return
+	}
 	if !is_pipe {
 		msg = prefix + " " + msg
 	}
@@ -1,9 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/panduit-joeb/jkv"
 	"github.com/panduit-joeb/jkv/store/fs"
 	"github.com/stretchr/testify/assert"
 )
@@ -20,3 +28,747 @@ func TestHGET(t *testing.T) {
 		assert.Equal(t, "value", rec.Val())
 	})
 }
+
+// newTestDB returns an open fs client in a temporary directory
+func newTestDB(t *testing.T) *fs.Client {
+	db := fs.NewClient(&fs.Options{Addr: t.TempDir()})
+	assert.Nil(t, db.Open())
+	t.Cleanup(db.Close)
+	return db
+}
+
+// capture returns what fn prints to stdout
+func capture(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-out
+}
+
+// fakeEditor points $EDITOR at a script that runs body with the file to edit in $1
+func fakeEditor(t *testing.T, body string) {
+	script := filepath.Join(t.TempDir(), "editor")
+	assert.Nil(t, os.WriteFile(script, []byte("#!/bin/sh\n"+body+"\n"), 0755))
+	t.Setenv("EDITOR", script)
+}
+
+func TestEDIT(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("EDIT an existing key", func(t *testing.T) {
+		db := newTestDB(t)
+		db.Set(ctx, "config", `{"debug":false}`, 0)
+		fakeEditor(t, `printf '{"debug":true}' >"$1"`)
+		ProcessCmd(db, "EDIT config", false, true)
+		assert.Equal(t, `{"debug":true}`, db.Get(ctx, "config").Val())
+	})
+
+	t.Run("EDIT creates a missing key", func(t *testing.T) {
+		db := newTestDB(t)
+		fakeEditor(t, `printf 'new' >"$1"`)
+		ProcessCmd(db, "EDIT config", false, true)
+		assert.Equal(t, "new", db.Get(ctx, "config").Val())
+	})
+
+	t.Run("EDIT aborts when the editor fails", func(t *testing.T) {
+		db := newTestDB(t)
+		db.Set(ctx, "config", "old", 0)
+		fakeEditor(t, `printf 'new' >"$1"; exit 1`)
+		ProcessCmd(db, "EDIT config", false, true)
+		assert.Equal(t, "old", db.Get(ctx, "config").Val())
+	})
+
+	t.Run("EDIT without changes is a no-op", func(t *testing.T) {
+		a := assert.New(t)
+		db := newTestDB(t)
+		fakeEditor(t, "true")
+		db.Set(ctx, "config", "old", 0)
+		changed, err := editValue(ctx, db, "config")
+		a.Nil(err)
+		a.False(changed)
+	})
+
+	t.Run("EDIT keeps the TTL", func(t *testing.T) {
+		a := assert.New(t)
+		db := newTestDB(t)
+		db.Set(ctx, "config", "old", time.Hour)
+		fakeEditor(t, `printf 'new' >"$1"`)
+		ProcessCmd(db, "EDIT config", false, true)
+		a.Equal("new", db.Get(ctx, "config").Val())
+		a.Less(int64(3500), db.TTL(ctx, "config").Val())
+	})
+
+	t.Run("EDIT returns an error reading the key", func(t *testing.T) {
+		a := assert.New(t)
+		db := newTestDB(t)
+		db.Close()
+		fakeEditor(t, `printf 'new' >"$1"`)
+		changed, err := editValue(ctx, db, "config")
+		a.ErrorIs(err, fs.ErrClosed)
+		a.False(changed)
+	})
+}
+
+func TestDEL(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	db.Set(ctx, "one", "1", 0)
+	db.Set(ctx, "two", "2", 0)
+
+	assert.Equal(t, "(integer) 2\n", capture(t, func() { ProcessCmd(db, "DEL one missing two", false, false) }))
+	assert.Equal(t, "0\n", capture(t, func() { ProcessCmd(db, "DEL one", false, true) }))
+	assert.Equal(t, "(error) ERR wrong number of arguments for 'del' command\n", capture(t, func() { ProcessCmd(db, "DEL", false, false) }))
+}
+
+func TestTREE(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	db.Set(ctx, "scalar", "value", 0)
+	db.HSet(ctx, "hash", "c", "3", "a", "1", "b", "2")
+
+	assert.Equal(t, db.GetDBDir()+"\n  hash/\n    a\n    b\n    c\n  scalar\n", capture(t, func() { ProcessCmd(db, "TREE", false, true) }))
+	assert.Equal(t, db.GetDBDir()+"\n  hash/\n    a\n    ... 2 more\n  scalar\n", capture(t, func() { ProcessCmd(db, "TREE 1", false, true) }))
+}
+
+func TestPager(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	for i := 0; i < 30; i++ {
+		db.Set(ctx, fmt.Sprintf("key%02d", i), "value", 0)
+	}
+
+	var paged string
+	defer func(p func(string, string) error, h func() int) { runPager, terminalHeight = p, h }(runPager, terminalHeight)
+	runPager = func(pager, text string) error { paged = text; return nil }
+	terminalHeight = func() int { return 24 }
+	t.Setenv("PAGER", "less")
+
+	out := capture(t, func() { ProcessCmd(db, "KEYS *", false, false) })
+	assert.Equal(t, "", out)
+	assert.Equal(t, 30, strings.Count(paged, "\n"))
+	assert.True(t, strings.HasPrefix(paged, "1) \"key00\"\n"))
+
+	paged = ""
+	out = capture(t, func() { ProcessCmd(db, "SCAN 0 MATCH key0* COUNT 5", false, false) })
+	assert.Equal(t, "", paged)
+	assert.Equal(t, 6, strings.Count(out, "\n"))
+
+	out = capture(t, func() { ProcessCmd(db, "KEYS *", false, true) })
+	assert.Equal(t, "", paged)
+	assert.Equal(t, 30, strings.Count(out, "\n"))
+}
+
+func TestRESET(t *testing.T) {
+	db := newTestDB(t)
+	assert.Equal(t, "RESET\n", capture(t, func() { ProcessCmd(db, "RESET", false, false) }))
+	assert.Equal(t, "(error) ERR wrong number of arguments for 'reset' command\n", capture(t, func() { ProcessCmd(db, "RESET now", false, false) }))
+}
+
+func TestLATENCY(t *testing.T) {
+	db := newTestDB(t)
+	latency.reset()
+
+	ProcessCmd(db, "SET key value", false, true)
+	ProcessCmd(db, "GET key", false, true)
+	ProcessCmd(db, "GET key", false, true)
+	out := capture(t, func() { ProcessCmd(db, "LATENCY", false, true) })
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "get: samples=2 "))
+	assert.True(t, strings.HasPrefix(lines[1], "set: samples=1 "))
+
+	assert.Equal(t, "OK\n", capture(t, func() { ProcessCmd(db, "LATENCY RESET", false, true) }))
+	assert.Equal(t, "\n", capture(t, func() { ProcessCmd(db, "LATENCY", false, true) }))
+}
+
+func TestReadValue(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	defer func(r io.Reader) { stdin = r }(stdin)
+
+	// larger than the 1MB buffer -x used to read into
+	large := strings.Repeat("0123456789", 300*1024)
+	stdin = strings.NewReader(large + "\n")
+	ProcessCmd(db, "SET big", true, true)
+	assert.Equal(t, large, db.Get(ctx, "big").Val())
+
+	stdin = strings.NewReader("small")
+	ProcessCmd(db, "HSET hash field", true, true)
+	assert.Equal(t, "small", db.HGet(ctx, "hash", "field").Val())
+
+	if !raceEnabled {
+		allocs := testing.AllocsPerRun(100, func() { readValue(strings.NewReader("small value")) })
+		assert.LessOrEqual(t, allocs, float64(2))
+	}
+}
+
+// readValueUnpooled is readValue without the pool, for BenchmarkReadValue to compare against
+func readValueUnpooled(r io.Reader) (string, error) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func BenchmarkReadValue(b *testing.B) {
+	for _, bm := range []struct {
+		name string
+		read func(io.Reader) (string, error)
+	}{{"unpooled", readValueUnpooled}, {"pooled", readValue}} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bm.read(strings.NewReader("small value"))
+			}
+		})
+	}
+}
+
+func TestOBJECT(t *testing.T) {
+	db := newTestDB(t)
+
+	ProcessCmd(db, "SET key value", false, true)
+	ProcessCmd(db, "HSET hash field value", false, true)
+	assert.Equal(t, "\"raw\"\n", capture(t, func() { ProcessCmd(db, "OBJECT ENCODING key", false, true) }))
+	assert.Equal(t, "\"hash-dir\"\n", capture(t, func() { ProcessCmd(db, "OBJECT encoding hash", false, true) }))
+	assert.Equal(t, "(nil)\n", capture(t, func() { ProcessCmd(db, "OBJECT ENCODING missing", false, true) }))
+}
+
+func TestHPOP(t *testing.T) {
+	db := newTestDB(t)
+
+	ProcessCmd(db, "HSET hash field value", false, true)
+	assert.Equal(t, "\"value\"\n", capture(t, func() { ProcessCmd(db, "HPOP hash field", false, true) }))
+	assert.Equal(t, "(nil)\n", capture(t, func() { ProcessCmd(db, "HPOP hash field", false, true) }))
+	assert.Equal(t, int64(0), db.Exists(context.Background(), "hash").Val())
+}
+
+func TestMETA(t *testing.T) {
+	db := newTestDB(t)
+
+	assert.Equal(t, "OK\n", capture(t, func() { ProcessCmd(db, "SETMETA doc hello content-type text/plain", false, true) }))
+	assert.Equal(t, "hello\ncontent-type\ntext/plain\n", capture(t, func() { ProcessCmd(db, "GETMETA doc", false, true) }))
+	assert.Equal(t, "\"hello\"\n", capture(t, func() { ProcessCmd(db, "GET doc", false, true) }))
+}
+
+func TestMONITOR(t *testing.T) {
+	db := newTestDB(t)
+	defer func(d time.Duration) { monitorInterval = d }(monitorInterval)
+	monitorInterval = 5 * time.Millisecond
+	defer func(f func() (context.Context, context.CancelFunc)) { monitorContext = f }(monitorContext)
+	monitorContext = func() (context.Context, context.CancelFunc) {
+		return context.WithTimeout(context.Background(), 300*time.Millisecond)
+	}
+
+	ProcessCmd(db, "SET key old", false, true)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		db.Set(context.Background(), "key", "new", 0)
+		time.Sleep(50 * time.Millisecond)
+		db.Del(context.Background(), "key")
+	}()
+	out := capture(t, func() { ProcessCmd(db, "MONITOR KEY key", false, true) })
+	assert.Equal(t, "\"old\"\n\"new\"\n(nil)\n", out)
+}
+
+func TestHSETPairs(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	defer func(r io.Reader) { stdin = r }(stdin)
+	defer func() { exitStatus = 0 }()
+
+	stdin = strings.NewReader("one\t1\ntwo\t2 with spaces\nthree\t\n")
+	assert.Equal(t, "3\n", capture(t, func() { ProcessCmd(db, "HSET hash", true, true) }))
+	assert.Equal(t, 0, exitStatus)
+	assert.Equal(t, "1", db.HGet(ctx, "hash", "one").Val())
+	assert.Equal(t, "2 with spaces", db.HGet(ctx, "hash", "two").Val())
+	assert.True(t, db.HExists(ctx, "hash", "three").Val())
+
+	stdin = strings.NewReader("four\t4\nfive 5\n")
+	out := capture(t, func() { ProcessCmd(db, "HSET hash", true, true) })
+	assert.Equal(t, "ERR line 2: no tab between field and value\n", out)
+	assert.Equal(t, 1, exitStatus)
+	assert.False(t, db.HExists(ctx, "hash", "four").Val())
+}
+
+func TestRENAMEPREFIX(t *testing.T) {
+	db := newTestDB(t)
+
+	ProcessCmd(db, "SET user:1 alice", false, true)
+	ProcessCmd(db, "HSET user:2 name bob", false, true)
+	assert.Equal(t, "2\n", capture(t, func() { ProcessCmd(db, "RENAMEPREFIX user: account:", false, true) }))
+	assert.Equal(t, "\"alice\"\n", capture(t, func() { ProcessCmd(db, "GET account:1", false, true) }))
+	assert.Equal(t, "(nil)\n", capture(t, func() { ProcessCmd(db, "GET user:1", false, true) }))
+}
+
+func TestSCANTYPE(t *testing.T) {
+	db := newTestDB(t)
+
+	ProcessCmd(db, "SET key value", false, true)
+	ProcessCmd(db, "HSET hash field value", false, true)
+	assert.Equal(t, "0\nhash\n", capture(t, func() { ProcessCmd(db, "SCAN 0 TYPE hash", false, true) }))
+	assert.Equal(t, "0\nkey\n", capture(t, func() { ProcessCmd(db, "SCAN 0 type string COUNT 5", false, true) }))
+}
+
+func TestCONFIG(t *testing.T) {
+	db := newTestDB(t)
+
+	assert.Equal(t, "dir\n"+db.GetDBDir()+"\n", capture(t, func() { ProcessCmd(db, "CONFIG GET dir", false, true) }))
+	assert.Equal(t, "1) \"dir\"\n2) \""+db.GetDBDir()+"\"\n", capture(t, func() { ProcessCmd(db, "config get dir", false, false) }))
+}
+
+func TestCONFIGSET(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	assert.Equal(t, "OK\n", capture(t, func() { ProcessCmd(db, "CONFIG SET durable yes", false, true) }))
+	ProcessCmd(db, "SET key value", false, true)
+	assert.Equal(t, int64(1), db.DebugStats(ctx).Fsyncs)
+	assert.Equal(t, "ERR CONFIG SET 'dir' can't be changed without reopening the database\n",
+		capture(t, func() { ProcessCmd(db, "CONFIG SET dir /tmp", false, true) }))
+}
+
+func TestVERSION(t *testing.T) {
+	db := newTestDB(t)
+	assert.Equal(t, "\""+jkv.VERSION+"\"\n", capture(t, func() { ProcessCmd(db, "VERSION", false, true) }))
+}
+
+func TestKEYSALL(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	db2 := fs.NewClient(&fs.Options{Addr: db.Root, DB: 2})
+	assert.Nil(t, db2.Open())
+	defer db2.Close()
+
+	db.Set(ctx, "key", "value", 0)
+	db2.Set(ctx, "key", "value", 0)
+	assert.Equal(t, "0) \"key\"\n2) \"key\"\n", capture(t, func() { ProcessCmd(db, "KEYSALL *", false, false) }))
+	assert.Equal(t, "0\tkey\n2\tkey\n", capture(t, func() { ProcessCmd(db, "KEYSALL k*", false, true) }))
+}
+
+func TestALIAS(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	t.Cleanup(func() { aliases = map[string][]string{} })
+
+	assert.Equal(t, "OK\n", capture(t, func() { ProcessCmd(db, "ALIAS g GET", false, true) }))
+	db.Set(ctx, "key", "value", 0)
+	assert.Equal(t, "\"value\"\n", capture(t, func() { ProcessCmd(db, "g key", false, true) }))
+
+	assert.Equal(t, "ERR alias 'get' refers to itself\n", capture(t, func() { ProcessCmd(db, "ALIAS get GET", false, true) }))
+	assert.Equal(t, "ERR 'g' is an alias, aliases of aliases are not allowed\n",
+		capture(t, func() { ProcessCmd(db, "ALIAS gg g", false, true) }))
+	assert.Equal(t, "ERR alias 'g' already refers to 'GET'\n", capture(t, func() { ProcessCmd(db, "ALIAS GET HGET", false, true) }))
+	assert.Equal(t, "ERR alias 'g' is already defined as 'GET', UNALIAS it first\n",
+		capture(t, func() { ProcessCmd(db, "ALIAS g HGET", false, true) }))
+	assert.Equal(t, "g GET\n", capture(t, func() { ProcessCmd(db, "ALIAS", false, true) }))
+
+	assert.Equal(t, "1\n", capture(t, func() { ProcessCmd(db, "UNALIAS g", false, true) }))
+	assert.Contains(t, capture(t, func() { ProcessCmd(db, "g key", false, true) }), "ERR unknown command 'g'")
+}
+
+func TestLoadAliases(t *testing.T) {
+	t.Cleanup(func() { aliases = map[string][]string{} })
+	rc := t.TempDir() + "/.jkvrc"
+	assert.Nil(t, os.WriteFile(rc, []byte("# shortcuts\n\nalias ll KEYS *\n"), 0644))
+
+	assert.Nil(t, loadAliases(rc))
+	assert.Equal(t, []string{"KEYS", "*", "extra"}, expandAlias([]string{"LL", "extra"}))
+	assert.Nil(t, loadAliases(rc+".missing"))
+
+	assert.Nil(t, os.WriteFile(rc, []byte("GET key\n"), 0644))
+	assert.EqualError(t, loadAliases(rc), rc+":1: expected ALIAS name command")
+}
+
+func TestPF(t *testing.T) {
+	db := newTestDB(t)
+
+	assert.Equal(t, "1\n", capture(t, func() { ProcessCmd(db, "PFADD one a b c", false, true) }))
+	assert.Equal(t, "0\n", capture(t, func() { ProcessCmd(db, "PFADD one a", false, true) }))
+	ProcessCmd(db, "PFADD two c d", false, true)
+	assert.Equal(t, "(integer) 3\n", capture(t, func() { ProcessCmd(db, "PFCOUNT one", false, false) }))
+	assert.Equal(t, "OK\n", capture(t, func() { ProcessCmd(db, "PFMERGE both one two", false, true) }))
+	assert.Equal(t, "4\n", capture(t, func() { ProcessCmd(db, "PFCOUNT both", false, true) }))
+}
+
+func TestDEBUGSETACTIVEEXPIRE(t *testing.T) {
+	db := newTestDB(t)
+
+	assert.Equal(t, "OK\n", capture(t, func() { ProcessCmd(db, "DEBUG SET-ACTIVE-EXPIRE 1", false, true) }))
+	assert.True(t, db.ActiveExpire)
+	assert.Equal(t, "OK\n", capture(t, func() { ProcessCmd(db, "debug set-active-expire 0", false, true) }))
+	assert.False(t, db.ActiveExpire)
+	assert.Equal(t, "ERR value is not an integer or out of range\n",
+		capture(t, func() { ProcessCmd(db, "DEBUG SET-ACTIVE-EXPIRE on", false, true) }))
+}
+
+// limited is an fs client that claims to have no capabilities
+type limited struct{ *fs.Client }
+
+func (limited) Capabilities() jkv.Capabilities { return jkv.Capabilities{} }
+
+func TestCapabilities(t *testing.T) {
+	db := newTestDB(t)
+
+	assert.Equal(t, "0\n", capture(t, func() { ProcessCmd(db, "COMPACT", false, true) }))
+	assert.Contains(t, capture(t, func() { ProcessCmd(db, "CAPABILITIES", false, true) }), "compact\n")
+
+	l := limited{db}
+	assert.Equal(t, "ERR command not supported by this backend\n", capture(t, func() { ProcessCmd(l, "COMPACT", false, true) }))
+	assert.Equal(t, "(error) ERR command not supported by this backend\n",
+		capture(t, func() { ProcessCmd(l, "DEBUG LOCKS", false, false) }))
+	assert.Equal(t, "", capture(t, func() { ProcessCmd(l, "CAPABILITIES", false, true) }))
+	assert.Equal(t, "OK\n", capture(t, func() { ProcessCmd(l, "SET key value", false, true) }))
+}
+
+func TestINFO(t *testing.T) {
+	db := newTestDB(t)
+
+	ProcessCmd(db, "SET key value", false, true)
+	ProcessCmd(db, "GET key", false, true)
+	ProcessCmd(db, "GET key", false, true)
+	assert.Equal(t, "# Commandstats\ncmdstat_get:calls=2\ncmdstat_set:calls=1\n",
+		capture(t, func() { ProcessCmd(db, "INFO commandstats", false, true) }))
+}
+
+func TestHKEYSMATCH(t *testing.T) {
+	db := newTestDB(t)
+	db.SortKeys = true
+
+	ProcessCmd(db, "HSET hash config:a 1 config:b 2 other 3", false, true)
+	assert.Equal(t, "config:a\nconfig:b\n", capture(t, func() { ProcessCmd(db, "HKEYS hash MATCH config:*", false, true) }))
+	assert.Equal(t, "config:a\nconfig:b\nother\n", capture(t, func() { ProcessCmd(db, "HKEYS hash", false, true) }))
+	assert.Equal(t, "(error) ERR wrong number of arguments for 'hkeys' command\n",
+		capture(t, func() { ProcessCmd(db, "HKEYS hash config:*", false, false) }))
+}
+
+func TestTTLKEYS(t *testing.T) {
+	db := newTestDB(t)
+	db.SortKeys = true
+
+	ProcessCmd(db, "SET forever value", false, true)
+	db.Set(context.Background(), "soon", "value", 42*time.Second)
+	ProcessCmd(db, "HSET hash field value", false, true)
+	assert.Equal(t, "forever (no expiry)\nhash (no expiry)\nsoon (ttl: 42s)\n",
+		capture(t, func() { ProcessCmd(db, "TTLKEYS *", false, true) }))
+	assert.Equal(t, "1) \"forever\" (no expiry)\n2) \"hash\" (no expiry)\n3) \"soon\" (ttl: 42s)\n",
+		capture(t, func() { ProcessCmd(db, "TTLKEYS *", false, false) }))
+	assert.Equal(t, "(error) ERR wrong number of arguments for 'ttlkeys' command\n",
+		capture(t, func() { ProcessCmd(db, "TTLKEYS", false, false) }))
+}
+
+func TestSETExpiration(t *testing.T) {
+	db := newTestDB(t)
+	db.SortKeys = true
+	db.DefaultTTL = time.Minute
+
+	ProcessCmd(db, "SET cached value", false, true)
+	ProcessCmd(db, "SET soon value EX 42", false, true)
+	ProcessCmd(db, "SET forever value PERSIST", false, true)
+	ProcessCmd(db, "SET soon other KEEPTTL", false, true)
+	assert.Equal(t, "cached (ttl: 60s)\nforever (no expiry)\nsoon (ttl: 42s)\n",
+		capture(t, func() { ProcessCmd(db, "TTLKEYS *", false, true) }))
+	assert.Equal(t, "(error) ERR syntax error\n",
+		capture(t, func() { ProcessCmd(db, "SET key value EXAT", false, false) }))
+	assert.Equal(t, "(error) ERR invalid expire time in 'set' command\n",
+		capture(t, func() { ProcessCmd(db, "SET key value EX 0", false, false) }))
+}
+
+func TestCHECKSUM(t *testing.T) {
+	one, two := newTestDB(t), newTestDB(t)
+	ProcessCmd(one, "SET key value", false, true)
+	ProcessCmd(two, "SET key value", false, true)
+
+	sum := capture(t, func() { ProcessCmd(one, "CHECKSUM", false, true) })
+	assert.Len(t, sum, 65)
+	assert.Equal(t, sum, capture(t, func() { ProcessCmd(two, "CHECKSUM", false, true) }))
+	ProcessCmd(two, "SET key other", false, true)
+	assert.NotEqual(t, sum, capture(t, func() { ProcessCmd(two, "CHECKSUM", false, true) }))
+	assert.Equal(t, "(error) ERR wrong number of arguments for 'checksum' command\n",
+		capture(t, func() { ProcessCmd(one, "CHECKSUM now", false, false) }))
+}
+
+func TestDefault(t *testing.T) {
+	db := newTestDB(t)
+	ProcessCmd(db, "SET key value", false, true)
+	ProcessCmd(db, "HSET hash field value", false, true)
+
+	assert.Equal(t, "(nil)\n", capture(t, func() { ProcessCmd(db, "GET nosuch", false, true) }))
+
+	value := "fallback"
+	missDefault = &value
+	defer func() { missDefault = nil }()
+	assert.Equal(t, "\"fallback\"\n", capture(t, func() { ProcessCmd(db, "GET nosuch", false, true) }))
+	assert.Equal(t, "\"value\"\n", capture(t, func() { ProcessCmd(db, "GET key", false, true) }))
+	assert.Equal(t, "\"fallback\"\n", capture(t, func() { ProcessCmd(db, "HGET hash nosuch", false, true) }))
+	assert.Equal(t, "\"fallback\"\n", capture(t, func() { ProcessCmd(db, "HGET nosuch field", false, true) }))
+	assert.Equal(t, "\"value\"\n", capture(t, func() { ProcessCmd(db, "HGET hash field", false, true) }))
+	assert.Equal(t, 0, exitStatus)
+}
+
+func TestJSONErrors(t *testing.T) {
+	db := newTestDB(t)
+	ProcessCmd(db, "SET key value", false, true)
+
+	jsonErrors = true
+	defer func() { jsonErrors = false }()
+	assert.Equal(t, `{"type":"error","code":"WRONGTYPE","message":"Operation against a key holding the wrong kind of value"}`+"\n",
+		capture(t, func() { ProcessCmd(db, "PFADD key a", false, false) }))
+	assert.Equal(t, `{"type":"error","code":"ERR","message":"wrong number of arguments for 'pfadd' command"}`+"\n",
+		capture(t, func() { ProcessCmd(db, "PFADD", false, true) }))
+	assert.Equal(t, "OK\n", capture(t, func() { ProcessCmd(db, "SET other value", false, true) }))
+
+	assert.Equal(t, `{"type":"error","code":"READONLY","message":"You can't write against a read only replica."}`,
+		errorJSON("ERR "+jkv.ErrReadOnly.Error()))
+	assert.Equal(t, `{"type":"error","code":"ERR","message":"DB is not open"}`, errorJSON("DB is not open"))
+}
+
+func TestPROFILE(t *testing.T) {
+	db := newTestDB(t)
+
+	ProcessCmd(db, "SET before value", false, true)
+	assert.Equal(t, "OK\n", capture(t, func() { ProcessCmd(db, "PROFILE ON", false, true) }))
+	ProcessCmd(db, "SET key value", false, true)
+	for i := 0; i < 3; i++ {
+		ProcessCmd(db, "GET key", false, true)
+	}
+	assert.Equal(t, "OK\n", capture(t, func() { ProcessCmd(db, "PROFILE OFF", false, true) }))
+	ProcessCmd(db, "SET after value", false, true)
+
+	out := capture(t, func() { ProcessCmd(db, "PROFILE REPORT", false, true) })
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Len(t, lines, 2)
+	counts := map[string]bool{}
+	for _, line := range lines {
+		name, rest, _ := strings.Cut(line, ": ")
+		counts[name+" "+strings.Fields(rest)[0]] = true
+		assert.Contains(t, rest, " total=")
+		assert.Contains(t, rest, " avg=")
+	}
+	assert.Equal(t, map[string]bool{"get count=3": true, "set count=1": true}, counts)
+
+	// turning it on again starts a new profile
+	ProcessCmd(db, "PROFILE ON", false, true)
+	assert.Equal(t, "\n", capture(t, func() { ProcessCmd(db, "PROFILE REPORT", false, true) }))
+	ProcessCmd(db, "PROFILE OFF", false, true)
+	assert.Equal(t, "(error) ERR unknown subcommand or wrong number of arguments for 'profile' command\n",
+		capture(t, func() { ProcessCmd(db, "PROFILE", false, false) }))
+}
+
+func TestHAPPEND(t *testing.T) {
+	db := newTestDB(t)
+	assert.Equal(t, "3\n", capture(t, func() { ProcessCmd(db, "HAPPEND hash field abc", false, true) }))
+	assert.Equal(t, "(integer) 6\n", capture(t, func() { ProcessCmd(db, "HAPPEND hash field def", false, false) }))
+	assert.Equal(t, "\"abcdef\"\n", capture(t, func() { ProcessCmd(db, "HGET hash field", false, true) }))
+	assert.Equal(t, "(error) ERR wrong number of arguments for 'happend' command\n",
+		capture(t, func() { ProcessCmd(db, "HAPPEND hash field", false, false) }))
+}
+
+func TestTYPES(t *testing.T) {
+	db := newTestDB(t)
+	ProcessCmd(db, "SET one value", false, true)
+	ProcessCmd(db, "SET two value", false, true)
+	ProcessCmd(db, "HSET hash field value", false, true)
+	assert.Equal(t, "hash   1\nstring 2\n", capture(t, func() { ProcessCmd(db, "TYPES", false, false) }))
+	assert.Equal(t, "(error) ERR wrong number of arguments for 'types' command\n",
+		capture(t, func() { ProcessCmd(db, "TYPES string", false, false) }))
+}
+
+func TestMIGRATE(t *testing.T) {
+	ctx := context.Background()
+	db, dir := newTestDB(t), t.TempDir()
+	ProcessCmd(db, "HSET hash one 1 two 2", false, true)
+	ProcessCmd(db, "SET scalar value", false, true)
+
+	assert.Equal(t, "OK\n", capture(t, func() { ProcessCmd(db, "MIGRATE fs://"+dir+" hash", false, false) }))
+	assert.Equal(t, "OK\n", capture(t, func() { ProcessCmd(db, "MIGRATE fs://"+dir+" scalar COPY", false, false) }))
+	assert.Equal(t, "NOKEY\n", capture(t, func() { ProcessCmd(db, "MIGRATE fs://"+dir+" hash", false, false) }))
+	assert.Equal(t, "(error) BUSYKEY Target key name already exists.\n",
+		capture(t, func() { ProcessCmd(db, "MIGRATE fs://"+dir+" scalar", false, false) }))
+	assert.Equal(t, "(error) ERR syntax error\n",
+		capture(t, func() { ProcessCmd(db, "MIGRATE fs://"+dir+" scalar MOVE", false, false) }))
+	assert.Empty(t, db.HKeys(ctx, "hash").Val())
+	assert.Equal(t, "value", db.Get(ctx, "scalar").Val())
+
+	dst := fs.NewClient(&fs.Options{Addr: dir})
+	assert.Nil(t, dst.Open())
+	defer dst.Close()
+	assert.Equal(t, map[string]string{"one": "1", "two": "2"}, dst.HGetAllMatch(ctx, "hash", "*").Val())
+	assert.Equal(t, "value", dst.Get(ctx, "scalar").Val())
+}
+
+func TestEnvValues(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	t.Setenv("JKV_TEST_SECRET", "s3cret")
+
+	// without --env a value starting with $ is stored as it is
+	ProcessCmd(db, "HSET config plain $JKV_TEST_SECRET", false, true)
+	assert.Equal(t, "$JKV_TEST_SECRET", db.HGet(ctx, "config", "plain").Val())
+
+	envValues = true
+	defer func() { envValues = false }()
+	assert.Equal(t, "1\n", capture(t, func() { ProcessCmd(db, "HSET config password $JKV_TEST_SECRET", false, true) }))
+	assert.Equal(t, "s3cret", db.HGet(ctx, "config", "password").Val())
+	assert.Equal(t, "(error) ERR environment variable JKV_TEST_MISSING is not set\n",
+		capture(t, func() { ProcessCmd(db, "HSET config user admin token $JKV_TEST_MISSING", false, false) }))
+	assert.False(t, db.HExists(ctx, "config", "user").Val())
+	exitStatus = 0
+}
+
+func TestHGETALL(t *testing.T) {
+	db := newTestDB(t)
+	ProcessCmd(db, "HSET hash b 2 a 1", false, true)
+	assert.Equal(t, "1) \"a\"\n2) \"1\"\n3) \"b\"\n4) \"2\"\n", capture(t, func() { ProcessCmd(db, "HGETALL hash", false, false) }))
+	assert.Equal(t, "a\n1\nb\n2\n", capture(t, func() { ProcessCmd(db, "HGETALL hash", false, true) }))
+	assert.Equal(t, "(empty array) \n", capture(t, func() { ProcessCmd(db, "HGETALL missing", false, false) }))
+	assert.Equal(t, "(error) ERR wrong number of arguments for 'hgetall' command\n",
+		capture(t, func() { ProcessCmd(db, "HGETALL", false, false) }))
+}
+
+func TestSWAP(t *testing.T) {
+	db := newTestDB(t)
+	ProcessCmd(db, "SET one 1", false, true)
+	ProcessCmd(db, "SET two 2", false, true)
+	assert.Equal(t, "OK\n", capture(t, func() { ProcessCmd(db, "SWAP one two", false, false) }))
+	assert.Equal(t, "\"2\"\n", capture(t, func() { ProcessCmd(db, "GET one", false, false) }))
+	assert.Equal(t, "(error) NOKEY No such key\n", capture(t, func() { ProcessCmd(db, "SWAP one three", false, false) }))
+	assert.Equal(t, "(error) ERR wrong number of arguments for 'swap' command\n",
+		capture(t, func() { ProcessCmd(db, "SWAP one", false, false) }))
+}
+
+func TestEXPIRE(t *testing.T) {
+	db := newTestDB(t)
+	ProcessCmd(db, "SET key value", false, true)
+	ProcessCmd(db, "SET forever value", false, true)
+	assert.Equal(t, "(integer) 1\n", capture(t, func() { ProcessCmd(db, "EXPIRE key 100", false, false) }))
+	assert.Equal(t, "(integer) 100\n", capture(t, func() { ProcessCmd(db, "TTL key", false, false) }))
+	assert.Equal(t, "-1\n", capture(t, func() { ProcessCmd(db, "TTL forever", false, true) }))
+	assert.Equal(t, "(integer) 0\n", capture(t, func() { ProcessCmd(db, "EXPIRE missing 100", false, false) }))
+	assert.Equal(t, "(integer) -2\n", capture(t, func() { ProcessCmd(db, "TTL missing", false, false) }))
+	assert.Equal(t, "(integer) 1\n", capture(t, func() { ProcessCmd(db, "EXPIRE key 0", false, false) }))
+	assert.Equal(t, "(integer) 0\n", capture(t, func() { ProcessCmd(db, "EXISTS key", false, false) }))
+	assert.Equal(t, "(error) ERR value is not an integer or out of range\n",
+		capture(t, func() { ProcessCmd(db, "EXPIRE key soon", false, false) }))
+	assert.Equal(t, "(error) ERR wrong number of arguments for 'ttl' command\n",
+		capture(t, func() { ProcessCmd(db, "TTL", false, false) }))
+}
+
+func TestINCR(t *testing.T) {
+	db := newTestDB(t)
+	assert.Equal(t, "(integer) 1\n", capture(t, func() { ProcessCmd(db, "INCR counter", false, false) }))
+	assert.Equal(t, "2\n", capture(t, func() { ProcessCmd(db, "incr counter", false, true) }))
+	assert.Equal(t, "(integer) 1\n", capture(t, func() { ProcessCmd(db, "DECR counter", false, false) }))
+	ProcessCmd(db, "SET text value", false, true)
+	assert.Equal(t, "(error) ERR value is not an integer or out of range\n",
+		capture(t, func() { ProcessCmd(db, "INCR text", false, false) }))
+	assert.Equal(t, "(error) ERR wrong number of arguments for 'decr' command\n",
+		capture(t, func() { ProcessCmd(db, "DECR", false, false) }))
+}
+
+func TestSETBIT(t *testing.T) {
+	db := newTestDB(t)
+	assert.Equal(t, "(integer) 0\n", capture(t, func() { ProcessCmd(db, "SETBIT bits 100 1", false, false) }))
+	assert.Equal(t, "(integer) 1\n", capture(t, func() { ProcessCmd(db, "GETBIT bits 100", false, false) }))
+	for _, cmd := range []string{"SETBIT bits 4294967296 1", "GETBIT bits 4294967296", "GETBIT bits -1"} {
+		assert.Equal(t, "(error) ERR bit offset is not an integer or out of range\n",
+			capture(t, func() { ProcessCmd(db, cmd, false, false) }), cmd)
+	}
+	ProcessCmd(db, "HSET hash field value", false, true)
+	assert.Contains(t, capture(t, func() { ProcessCmd(db, "GETBIT hash 0", false, false) }), "WRONGTYPE")
+}
+
+func TestSplitArgs(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		args []string
+	}{
+		{"SET key value", []string{"SET", "key", "value"}},
+		{"  SET\tkey  value ", []string{"SET", "key", "value"}},
+		{`SET key "multi word value"`, []string{"SET", "key", "multi word value"}},
+		{`SET key 'single quoted'`, []string{"SET", "key", "single quoted"}},
+		{`SET key "say \"hi\""`, []string{"SET", "key", `say "hi"`}},
+		{`SET key 'it\'s'`, []string{"SET", "key", "it's"}},
+		{`SET key "tab\there\x41"`, []string{"SET", "key", "tab\thereA"}},
+		{`SET key ""`, []string{"SET", "key", ""}},
+		{`SET key don't`, []string{"SET", "key", "don't"}},
+		{`SET key a"b"`, []string{"SET", "key", `a"b"`}},
+		// invalid UTF-8 is kept byte for byte rather than turned into U+FFFD
+		{"SET \xff\xfe key\x80", []string{"SET", "\xff\xfe", "key\x80"}},
+		{"SET key \"a\xffb c\xe2\x80\"", []string{"SET", "key", "a\xffb c\xe2\x80"}},
+		{"SET key 'd\xc3'", []string{"SET", "key", "d\xc3"}},
+		{"SET key \"caf\u00e9 \\\u00e9\"\u00a0x", []string{"SET", "key", "caf\u00e9 \u00e9", "x"}},
+	} {
+		args, err := splitArgs(tc.line)
+		assert.Nil(t, err, tc.line)
+		assert.Equal(t, tc.args, args, tc.line)
+	}
+	for _, line := range []string{`SET key "open`, `SET key "closed"trailing`, `SET key 'open\'`} {
+		_, err := splitArgs(line)
+		assert.ErrorIs(t, err, errUnbalancedQuotes, line)
+	}
+}
+
+func TestQuotedValues(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	assert.Equal(t, "OK\n", capture(t, func() { ProcessCmd(db, `SET greeting "hello world"`, false, false) }))
+	assert.Equal(t, "hello world", db.Get(ctx, "greeting").Val())
+	ProcessCmd(db, `HSET h field "a b c" other 'd e'`, false, true)
+	assert.Equal(t, "a b c", db.HGet(ctx, "h", "field").Val())
+	assert.Equal(t, "d e", db.HGet(ctx, "h", "other").Val())
+	assert.Equal(t, "(error) ERR unbalanced quotes in request\n",
+		capture(t, func() { ProcessCmd(db, `SET greeting "hello`, false, false) }))
+}
+
+func TestJoinArgs(t *testing.T) {
+	for _, args := range [][]string{
+		{"SET", "key", "value"},
+		{"SET", "key", "multi word value"},
+		{"SET", "key", ""},
+		{"SET", "key", `"quoted"`},
+		{"SET", "key", `'single' and \ back`},
+		{"SET", "key", `don't`},
+	} {
+		split, err := splitArgs(joinArgs(args))
+		assert.Nil(t, err)
+		assert.Equal(t, args, split)
+	}
+	assert.Equal(t, "SET key value", joinArgs([]string{"SET", "key", "value"}))
+}
+
+func TestHDEL(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	ProcessCmd(db, "HSET hash one 1 two 2 three 3", false, true)
+	assert.Equal(t, "(integer) 1\n", capture(t, func() { ProcessCmd(db, "HDEL hash one", false, false) }))
+	assert.Equal(t, "0\n", capture(t, func() { ProcessCmd(db, "HDEL hash one", false, true) }))
+	assert.Equal(t, "(integer) 2\n", capture(t, func() { ProcessCmd(db, "HDEL hash two three missing", false, false) }))
+	assert.Empty(t, db.HKeys(ctx, "hash").Val())
+	assert.Equal(t, "(error) ERR wrong number of arguments for 'hdel' command\n",
+		capture(t, func() { ProcessCmd(db, "HDEL hash", false, false) }))
+}
+
+func TestKEYSAndEXISTS(t *testing.T) {
+	db := newTestDB(t)
+	db.SortKeys = true
+	ProcessCmd(db, "SET one 1", false, true)
+	ProcessCmd(db, "SET two 2", false, true)
+	assert.Equal(t, "1) \"one\"\n2) \"two\"\n", capture(t, func() { ProcessCmd(db, "KEYS *", false, false) }))
+	assert.Equal(t, "one\n", capture(t, func() { ProcessCmd(db, "KEYS o*", false, true) }))
+	assert.Equal(t, "(integer) 3\n", capture(t, func() { ProcessCmd(db, "EXISTS one two one missing", false, false) }))
+	assert.Equal(t, "0\n", capture(t, func() { ProcessCmd(db, "EXISTS missing", false, true) }))
+	db.Close()
+	assert.Equal(t, "(error) ERR "+fs.ErrClosed.Error()+"\n", capture(t, func() { ProcessCmd(db, "EXISTS one", false, false) }))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// There is no keyspace notification mechanism in jkv, so MONITOR KEY polls the key and prints it when it changes.
+
+// monitorInterval is how often MONITOR KEY reads the key
+var monitorInterval = 100 * time.Millisecond
+
+// monitorContext returns the context MONITOR KEY runs in, it ends on ^C
+var monitorContext = func() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+// monitorKey prints the value of scalar key, then each new value until ctx is done. (nil) is printed when the key
+// is removed.
+func monitorKey(ctx context.Context, db jkv.Client, key string) {
+	ticker := time.NewTicker(monitorInterval)
+	defer ticker.Stop()
+
+	last, lastErr := "", error(nil)
+	for first := true; ; first = false {
+		rec := db.Get(ctx, key)
+		if first || rec.Val() != last || (rec.Err() == nil) != (lastErr == nil) {
+			if rec.Err() != nil {
+				fmt.Println("(nil)")
+			} else {
+				fmt.Printf("\"%s\"\n", rec.Val())
+			}
+			last, lastErr = rec.Val(), rec.Err()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Unlike LATENCY, which always keeps the recent durations of each command, PROFILE records nothing until it is
+// turned on and then keeps a count and total per command, to show which commands dominated a session.
+
+// profiler totals the durations of commands while it is on
+type profiler struct {
+	mu     sync.Mutex
+	on     bool
+	counts map[string]int64
+	totals map[string]time.Duration
+}
+
+var profile = &profiler{}
+
+// start clears the profile and starts recording
+func (p *profiler) start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.on = true
+	p.counts = map[string]int64{}
+	p.totals = map[string]time.Duration{}
+}
+
+// stop stops recording, the profile is kept for report
+func (p *profiler) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.on = false
+}
+
+func (p *profiler) record(cmd string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.on {
+		return
+	}
+	p.counts[cmd]++
+	p.totals[cmd] += d
+}
+
+// report returns a line of count, total and average for each command, the command that took longest in total first
+func (p *profiler) report() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var cmds []string
+	for cmd := range p.counts {
+		cmds = append(cmds, cmd)
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		if p.totals[cmds[i]] != p.totals[cmds[j]] {
+			return p.totals[cmds[i]] > p.totals[cmds[j]]
+		}
+		return cmds[i] < cmds[j]
+	})
+
+	var lines []string
+	for _, cmd := range cmds {
+		n, total := p.counts[cmd], p.totals[cmd]
+		lines = append(lines, fmt.Sprintf("%s: count=%d total=%s avg=%s", strings.ToLower(cmd), n, total,
+			total/time.Duration(n)))
+	}
+	return lines
+}
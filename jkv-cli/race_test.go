@@ -0,0 +1,6 @@
+//go:build race
+
+package main
+
+// raceEnabled is true under go test -race, whose instrumentation allocates and throws allocation counts off
+const raceEnabled = true
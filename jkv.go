@@ -6,9 +6,16 @@ import (
 )
 
 type baseCmd struct {
-	err error
+	err      error
+	duration time.Duration
 }
 
+// Duration returns how long the command took, it is only recorded by stores with RecordDuration set
+func (b *baseCmd) Duration() time.Duration { return b.duration }
+
+// SetDuration is called by a store to record how long the command took
+func (b *baseCmd) SetDuration(d time.Duration) { b.duration = d }
+
 type StatusCmd struct {
 	baseCmd
 	val string
@@ -55,6 +62,66 @@ func NewStringSliceCmd(val []string, err error) *StringSliceCmd {
 	return &StringSliceCmd{baseCmd: baseCmd{err: err}, val: val}
 }
 
+type IntSliceCmd struct {
+	baseCmd
+	val []int64
+}
+
+func NewIntSliceCmd(val []int64, err error) *IntSliceCmd {
+	return &IntSliceCmd{baseCmd: baseCmd{err: err}, val: val}
+}
+
+// Cmd is the result of a command issued with Do, the reply is a string, an int64, a []interface{} or nil
+type Cmd struct {
+	baseCmd
+	val interface{}
+}
+
+func NewCmd(val interface{}, err error) *Cmd {
+	return &Cmd{baseCmd: baseCmd{err: err}, val: val}
+}
+
+func (s *Cmd) Val() interface{} { return s.val }
+func (s *Cmd) Err() error       { return s.err }
+
+type StringStringMapCmd struct {
+	baseCmd
+	val map[string]string
+}
+
+func NewStringStringMapCmd(val map[string]string, err error) *StringStringMapCmd {
+	return &StringStringMapCmd{baseCmd: baseCmd{err: err}, val: val}
+}
+
+func (s *StringStringMapCmd) Val() map[string]string { return s.val }
+func (s *StringStringMapCmd) Err() error             { return s.err }
+
+type StringIntMapCmd struct {
+	baseCmd
+	val map[string]int64
+}
+
+func NewStringIntMapCmd(val map[string]int64, err error) *StringIntMapCmd {
+	return &StringIntMapCmd{baseCmd: baseCmd{err: err}, val: val}
+}
+
+func (s *StringIntMapCmd) Val() map[string]int64 { return s.val }
+func (s *StringIntMapCmd) Err() error            { return s.err }
+
+// ScanCmd is the result of a SCAN, a page of keys and the cursor of the next page, "0" when the scan is complete
+type ScanCmd struct {
+	baseCmd
+	keys   []string
+	cursor string
+}
+
+func NewScanCmd(keys []string, cursor string, err error) *ScanCmd {
+	return &ScanCmd{baseCmd: baseCmd{err: err}, keys: keys, cursor: cursor}
+}
+
+func (s *ScanCmd) Val() (keys []string, cursor string) { return s.keys, s.cursor }
+func (s *ScanCmd) Err() error                          { return s.err }
+
 func (s *StringCmd) Val() string        { return s.val }
 func (s *StringCmd) Err() error         { return s.err }
 func (s *IntCmd) Val() int64            { return s.val }
@@ -62,24 +129,72 @@ func (s *IntCmd) Err() error            { return s.err }
 func (s *BoolCmd) Val() bool            { return s.val }
 func (s *BoolCmd) Err() error           { return s.err }
 func (s *StringSliceCmd) Val() []string { return s.val }
+func (s *IntSliceCmd) Val() []int64     { return s.val }
+func (s *IntSliceCmd) Err() error       { return s.err }
 func (s *StringSliceCmd) Err() error    { return s.err }
 func (s *StatusCmd) Val() string        { return s.val }
 func (s *StatusCmd) Err() error         { return s.err }
 
+// ExpiryOptions change the expiration of a key, e.g. for GETEX. At most one should be set; the zero value leaves
+// the expiration untouched.
+type ExpiryOptions struct {
+	EX, PX     time.Duration
+	EXAT, PXAT time.Time
+	Persist    bool
+}
+
+// Special expirations for Set and SetNX. KeepTTL leaves the expiration of an existing key as it is, like SET ...
+// KEEPTTL, and NoExpiration writes the key with no expiration even when the store has a default TTL, like SET ...
+// PERSIST. An expiration of 0 means the store's default, which is no expiration unless one has been configured.
+const (
+	KeepTTL      time.Duration = -1
+	NoExpiration time.Duration = -2
+)
+
+// BitCount is the byte range counted by BITCOUNT
+type BitCount struct {
+	Start, End int64
+}
+
 type Client interface {
 	Open() error
 	Close()
 	GetDBDir() string
 	FlushDB(ctx context.Context) *StatusCmd
 	Get(ctx context.Context, key string) *StringCmd
+	GetEX(ctx context.Context, key string, opts ExpiryOptions) *StringCmd
 	Set(ctx context.Context, key, value string, expiration time.Duration) *StatusCmd
 	Del(ctx context.Context, keys ...string) *IntCmd
+	SetNX(ctx context.Context, key, value string, expiration time.Duration) *BoolCmd
+	CompareAndDelete(ctx context.Context, key, value string) *BoolCmd
+	Incr(ctx context.Context, key string) *IntCmd
+	Decr(ctx context.Context, key string) *IntCmd
+	SetBit(ctx context.Context, key string, offset int64, value int) *IntCmd
+	GetBit(ctx context.Context, key string, offset int64) *IntCmd
+	BitCount(ctx context.Context, key string, bitCount *BitCount) *IntCmd
+	PFAdd(ctx context.Context, key string, elements ...string) *IntCmd
+	PFCount(ctx context.Context, keys ...string) *IntCmd
+	PFMerge(ctx context.Context, dest string, keys ...string) *StatusCmd
 	Keys(ctx context.Context, pattern string) *StringSliceCmd
+	Scan(ctx context.Context, cursor string, match string, count int64) *ScanCmd
+	ScanType(ctx context.Context, cursor string, match string, count int64, keyType string) *ScanCmd
 	Exists(ctx context.Context, keys ...string) *IntCmd
+	Expire(ctx context.Context, key string, expiration time.Duration) *BoolCmd
+	TTL(ctx context.Context, key string) *IntCmd
 	HGet(ctx context.Context, hash, key string) *StringCmd
 	HSet(ctx context.Context, hash string, values ...string) *IntCmd
 	HDel(ctx context.Context, hash string, values ...string) *IntCmd
 	HKeys(ctx context.Context, hash string) *StringSliceCmd
+	HKeysMatch(ctx context.Context, hash, pattern string) *StringSliceCmd
+	HGetAll(ctx context.Context, hash string) *StringStringMapCmd
+	HGetAllMatch(ctx context.Context, hash, pattern string) *StringStringMapCmd
 	HExists(ctx context.Context, hash, key string) *BoolCmd
+	HExpire(ctx context.Context, hash string, seconds int64, fields ...string) *IntSliceCmd
+	HTTL(ctx context.Context, hash string, fields ...string) *IntSliceCmd
 	Ping(ctx context.Context) *StatusCmd
+	Version(ctx context.Context) *StringCmd
+	Info(ctx context.Context, sections ...string) *StringCmd
+	ConfigGet(ctx context.Context, parameter string) *StringStringMapCmd
+	Do(ctx context.Context, args ...interface{}) *Cmd
+	Capabilities() Capabilities
 }
@@ -0,0 +1,57 @@
+package jkv
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotObtained is returned by Lock when another owner holds the lock
+	ErrNotObtained = errors.New("jkv: lock is held by another owner")
+	// ErrNotHeld is returned by Unlock when the lock expired and may have been taken by another owner
+	ErrNotHeld = errors.New("jkv: lock is no longer held")
+)
+
+// KeyLock is a lock held on a key, see Lock
+type KeyLock struct {
+	client Client
+	key    string
+	token  string
+}
+
+// Lock takes a lock named key on c that is released by Unlock or after ttl, whichever comes first. The key holds a
+// random token, so an owner whose lock expired can't release it once someone else holds it. Lock doesn't wait, it
+// returns ErrNotObtained if the lock is held.
+func Lock(ctx context.Context, c Client, key string, ttl time.Duration) (*KeyLock, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	l := &KeyLock{client: c, key: key, token: hex.EncodeToString(b)}
+	rec := c.SetNX(ctx, key, l.token, ttl)
+	if rec.Err() != nil {
+		return nil, rec.Err()
+	}
+	if !rec.Val() {
+		return nil, ErrNotObtained
+	}
+	return l, nil
+}
+
+// Key returns the key the lock is held on
+func (l *KeyLock) Key() string { return l.key }
+
+// Unlock releases the lock if it is still held, otherwise it returns ErrNotHeld and leaves the key alone
+func (l *KeyLock) Unlock(ctx context.Context) error {
+	rec := l.client.CompareAndDelete(ctx, l.key, l.token)
+	if rec.Err() != nil {
+		return rec.Err()
+	}
+	if !rec.Val() {
+		return ErrNotHeld
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package jkv_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/panduit-joeb/jkv"
+	"github.com/panduit-joeb/jkv/store/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := fs.NewClient(&fs.Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	held := make(chan *jkv.KeyLock)
+	go func() {
+		l, err := jkv.Lock(ctx, c, "lock", time.Minute)
+		a.Nil(err)
+		held <- l
+	}()
+	first := <-held
+	_, err := jkv.Lock(ctx, c, "lock", time.Minute)
+	a.ErrorIs(err, jkv.ErrNotObtained)
+
+	a.Nil(first.Unlock(ctx))
+	a.ErrorIs(first.Unlock(ctx), jkv.ErrNotHeld)
+	second, err := jkv.Lock(ctx, c, "lock", time.Minute)
+	a.Nil(err)
+	a.Nil(second.Unlock(ctx))
+
+	t.Run("Unlock after expiry", func(t *testing.T) {
+		a := assert.New(t)
+		expired, err := jkv.Lock(ctx, c, "lock", 10*time.Millisecond)
+		a.Nil(err)
+		time.Sleep(20 * time.Millisecond)
+		owner, err := jkv.Lock(ctx, c, "lock", time.Minute)
+		a.Nil(err)
+
+		a.ErrorIs(expired.Unlock(ctx), jkv.ErrNotHeld)
+		a.Equal(int64(1), c.Exists(ctx, "lock").Val())
+		a.Nil(owner.Unlock(ctx))
+		a.Equal(int64(0), c.Exists(ctx, "lock").Val())
+	})
+}
@@ -0,0 +1,107 @@
+package jkv
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// MigrateOptions change what Migrate does, like the options of MIGRATE
+type MigrateOptions struct {
+	Copy    bool // leave the key in the source
+	Replace bool // overwrite the key if it exists in the destination
+}
+
+// Migrate moves a scalar or hash from src to dst with its expiration, like MIGRATE between two Redis instances. It
+// returns ErrNoKey if key doesn't exist in src and ErrBusyKey if it exists in dst, unless opts.Replace is set. Two
+// backends can't share a transaction, so the key is written to dst before it is deleted from src: a failure part
+// way leaves it in both rather than neither. Expirations are carried over in whole seconds.
+func Migrate(ctx context.Context, src, dst Client, key string, opts MigrateOptions) error {
+	fields, err := hashFields(ctx, src, key)
+	if err != nil {
+		return err
+	}
+	var value string
+	if fields == nil {
+		rec := src.Get(ctx, key)
+		if errors.Is(rec.Err(), Nil) {
+			return ErrNoKey
+		} else if rec.Err() != nil {
+			return rec.Err()
+		}
+		value = rec.Val()
+	}
+	ttl, err := ttlOf(ctx, src, key)
+	if err != nil {
+		return err
+	}
+
+	if opts.Replace {
+		if err := delKey(ctx, dst, key); err != nil {
+			return err
+		}
+	} else if rec := dst.Exists(ctx, key); rec.Err() != nil {
+		return rec.Err()
+	} else if rec.Val() > 0 {
+		return ErrBusyKey
+	} else if existing, err := hashFields(ctx, dst, key); err != nil {
+		return err
+	} else if existing != nil {
+		return ErrBusyKey
+	}
+
+	if fields == nil {
+		expiration := NoExpiration
+		if ttl > 0 {
+			expiration = ttl
+		}
+		if rec := dst.Set(ctx, key, value, expiration); rec.Err() != nil {
+			return rec.Err()
+		}
+	} else {
+		values := make([]string, 0, 2*len(fields))
+		for field, value := range fields {
+			values = append(values, field, value)
+		}
+		if rec := dst.HSet(ctx, key, values...); rec.Err() != nil {
+			return rec.Err()
+		}
+		if ttl > 0 {
+			if rec := dst.Expire(ctx, key, ttl); rec.Err() != nil {
+				return rec.Err()
+			}
+		}
+	}
+
+	if !opts.Copy {
+		return delKey(ctx, src, key)
+	}
+	return nil
+}
+
+// delKey deletes a scalar or hash, DEL in the fs store only removes scalars so a hash goes with HDEL of its fields
+func delKey(ctx context.Context, c Client, key string) error {
+	if rec := c.HKeys(ctx, key); rec.Err() == nil && len(rec.Val()) > 0 {
+		if rec := c.HDel(ctx, key, rec.Val()...); rec.Err() != nil {
+			return rec.Err()
+		}
+	}
+	return c.Del(ctx, key).Err()
+}
+
+// ttlOf returns the time to live of key in whole seconds, 0 if it has no expiration. A key with less than a second
+// left has a TTL of 0, which is rounded up so it still expires, and ErrNoKey is returned if key has gone.
+func ttlOf(ctx context.Context, c Client, key string) (time.Duration, error) {
+	rec := c.TTL(ctx, key)
+	switch {
+	case rec.Err() != nil:
+		return 0, rec.Err()
+	case rec.Val() == -2:
+		return 0, ErrNoKey
+	case rec.Val() == 0:
+		return time.Second, nil
+	case rec.Val() > 0:
+		return time.Duration(rec.Val()) * time.Second, nil
+	}
+	return 0, nil
+}
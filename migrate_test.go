@@ -0,0 +1,105 @@
+package jkv_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/panduit-joeb/jkv"
+	"github.com/panduit-joeb/jkv/store/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	src := fs.NewClient(&fs.Options{Addr: t.TempDir()})
+	dst := fs.NewClient(&fs.Options{Addr: t.TempDir()})
+	a.Nil(src.Open())
+	a.Nil(dst.Open())
+	defer src.Close()
+	defer dst.Close()
+
+	src.HSet(ctx, "hash", "one", "1", "two", "2")
+	src.Expire(ctx, "hash", time.Minute)
+	src.Set(ctx, "scalar", "value", 0)
+
+	a.Nil(jkv.Migrate(ctx, src, dst, "hash", jkv.MigrateOptions{}))
+	a.Equal(map[string]string{"one": "1", "two": "2"}, dst.HGetAllMatch(ctx, "hash", "*").Val())
+	a.Equal(int64(60), dst.TTL(ctx, "hash").Val())
+	a.Empty(src.HKeys(ctx, "hash").Val())
+
+	a.Nil(jkv.Migrate(ctx, src, dst, "scalar", jkv.MigrateOptions{Copy: true}))
+	a.Equal("value", dst.Get(ctx, "scalar").Val())
+	a.Equal(int64(-1), dst.TTL(ctx, "scalar").Val())
+	a.Equal("value", src.Get(ctx, "scalar").Val())
+
+	a.ErrorIs(jkv.Migrate(ctx, src, dst, "missing", jkv.MigrateOptions{}), jkv.ErrNoKey)
+	a.ErrorIs(jkv.Migrate(ctx, src, dst, "scalar", jkv.MigrateOptions{}), jkv.ErrBusyKey)
+	src.Set(ctx, "scalar", "new", 0)
+	a.Nil(jkv.Migrate(ctx, src, dst, "scalar", jkv.MigrateOptions{Replace: true}))
+	a.Equal("new", dst.Get(ctx, "scalar").Val())
+	a.Equal(int64(0), src.Exists(ctx, "scalar").Val())
+
+	// a hash in the way is busy too, and is replaced as a whole
+	src.Set(ctx, "hash", "value", 0)
+	a.ErrorIs(jkv.Migrate(ctx, src, dst, "hash", jkv.MigrateOptions{}), jkv.ErrBusyKey)
+	a.Nil(jkv.Migrate(ctx, src, dst, "hash", jkv.MigrateOptions{Replace: true}))
+	a.Equal("value", dst.Get(ctx, "hash").Val())
+	a.Empty(dst.HKeys(ctx, "hash").Val())
+}
+
+func TestMigrateHashErrors(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	src := fs.NewClient(&fs.Options{Addr: t.TempDir()})
+	dst := fs.NewClient(&fs.Options{Addr: t.TempDir()})
+	a.Nil(src.Open())
+	a.Nil(dst.Open())
+	defer src.Close()
+	defer dst.Close()
+	src.HSet(ctx, "hash", "one", "1", "two", "2")
+
+	// a field that can't be read stops the move and the hash stays in the source
+	failing := hgetErrors{src, map[string]error{"two": os.ErrPermission}}
+	a.ErrorIs(jkv.Migrate(ctx, failing, dst, "hash", jkv.MigrateOptions{}), os.ErrPermission)
+	a.Empty(dst.HKeys(ctx, "hash").Val())
+	a.Equal([]string{"one", "two"}, src.HKeys(ctx, "hash").Val())
+
+	// one that is gone by the time it is read is left out
+	a.Nil(jkv.Migrate(ctx, hgetErrors{src, map[string]error{"two": jkv.Nil}}, dst, "hash", jkv.MigrateOptions{}))
+	a.Equal(map[string]string{"one": "1"}, dst.HGetAllMatch(ctx, "hash", "*").Val())
+}
+
+// ttlReply answers TTL with ttl whatever the key
+type ttlReply struct {
+	jkv.Client
+	ttl int64
+}
+
+func (c ttlReply) TTL(ctx context.Context, key string) *jkv.IntCmd { return jkv.NewIntCmd(c.ttl, nil) }
+
+func TestMigrateTTL(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	src := fs.NewClient(&fs.Options{Addr: t.TempDir()})
+	dst := fs.NewClient(&fs.Options{Addr: t.TempDir()})
+	a.Nil(src.Open())
+	a.Nil(dst.Open())
+	defer src.Close()
+	defer dst.Close()
+	src.Set(ctx, "key", "value", 0)
+
+	// a key that goes between reading it and its TTL isn't there to move
+	a.ErrorIs(jkv.Migrate(ctx, ttlReply{src, -2}, dst, "key", jkv.MigrateOptions{}), jkv.ErrNoKey)
+	a.Equal(int64(0), dst.Exists(ctx, "key").Val())
+	a.Equal("value", src.Get(ctx, "key").Val())
+
+	// less than a second left still expires
+	a.Nil(jkv.Migrate(ctx, ttlReply{src, 0}, dst, "key", jkv.MigrateOptions{}))
+	a.Equal(int64(1), dst.TTL(ctx, "key").Val())
+}
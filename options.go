@@ -0,0 +1,29 @@
+package jkv
+
+import "log"
+
+// Settings are the options common to every backend. Backends build Settings from their Options struct and then
+// apply any Option functions passed to their constructor.
+type Settings struct {
+	Addr, Password string
+	DB             int
+	ReadOnly       bool
+	Logger         *log.Logger
+}
+
+// Option changes a Setting, e.g. fs.NewClient(nil, jkv.WithAddr("/tmp/db"), jkv.WithReadOnly(true))
+type Option func(*Settings)
+
+func WithAddr(addr string) Option          { return func(s *Settings) { s.Addr = addr } }
+func WithPassword(password string) Option  { return func(s *Settings) { s.Password = password } }
+func WithDB(db int) Option                 { return func(s *Settings) { s.DB = db } }
+func WithReadOnly(readOnly bool) Option    { return func(s *Settings) { s.ReadOnly = readOnly } }
+func WithLogger(logger *log.Logger) Option { return func(s *Settings) { s.Logger = logger } }
+
+// Apply the options to s in order and return the result
+func (s Settings) Apply(options ...Option) Settings {
+	for _, option := range options {
+		option(&s)
+	}
+	return s
+}
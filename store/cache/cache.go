@@ -0,0 +1,273 @@
+// Package cache wraps a jkv.Client so values read with GET and HGET are served from memory until they are written
+// through the wrapper or grow older than a TTL
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// DEFAULT_TTL bounds how stale a value can be when it is written by another client
+const DEFAULT_TTL = time.Second
+
+// Options controls how long values are cached
+type Options struct {
+	TTL time.Duration // how long a value read is served from the cache, DEFAULT_TTL if 0
+}
+
+// Client is a jkv.Client that caches the values read from Inner
+type Client struct {
+	Inner   jkv.Client
+	Options Options
+
+	mu      sync.RWMutex
+	gen     uint64 // bumped by every invalidation, so a read racing one doesn't cache what it read
+	scalars map[string]entry
+	fields  map[string]map[string]entry
+}
+
+type entry struct {
+	val     string
+	expires time.Time
+}
+
+// New returns inner wrapped so GET and HGET are cached. Writes made through the wrapper, FLUSHDB among them,
+// invalidate what they change; writes made by anything else are seen once the cached value is opts.TTL old.
+func New(inner jkv.Client, opts Options) *Client {
+	if opts.TTL <= 0 {
+		opts.TTL = DEFAULT_TTL
+	}
+	return &Client{Inner: inner, Options: opts, scalars: map[string]entry{}, fields: map[string]map[string]entry{}}
+}
+
+// invalidate drops the cached values of keys, or all of them if all is set
+func (c *Client) invalidate(all bool, keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	if all {
+		c.scalars = map[string]entry{}
+		c.fields = map[string]map[string]entry{}
+		return
+	}
+	for _, key := range keys {
+		delete(c.scalars, key)
+		delete(c.fields, key)
+	}
+}
+
+// write runs a write that changes keys, or everything if all is set. The cached values are dropped before it starts
+// and again once it is done, so a read made while it runs can't leave a value it replaced in the cache.
+func write[T interface{}](c *Client, all bool, keys []string, fn func() T) T {
+	c.invalidate(all, keys...)
+	defer c.invalidate(all, keys...)
+	return fn()
+}
+
+// lookup returns the cached value of field in hash, or of the scalar hash if field is nil, and the generation to
+// pass to store if it isn't cached
+func (c *Client) lookup(hash string, field *string) (string, bool, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.scalars[hash]
+	if field != nil {
+		e, ok = c.fields[hash][*field]
+	}
+	if ok && time.Now().Before(e.expires) {
+		return e.val, true, c.gen
+	}
+	return "", false, c.gen
+}
+
+// store caches a value read at generation gen, unless it has been invalidated since
+func (c *Client) store(gen uint64, hash string, field *string, val string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	e := entry{val: val, expires: time.Now().Add(c.Options.TTL)}
+	if field == nil {
+		c.scalars[hash] = e
+		return
+	}
+	if c.fields[hash] == nil {
+		c.fields[hash] = map[string]entry{}
+	}
+	c.fields[hash][*field] = e
+}
+
+func (c *Client) Open() error {
+	return write(c, true, nil, c.Inner.Open)
+}
+
+func (c *Client) Close() {
+	c.Inner.Close()
+	c.invalidate(true)
+}
+
+func (c *Client) GetDBDir() string { return c.Inner.GetDBDir() }
+
+func (c *Client) Capabilities() jkv.Capabilities { return c.Inner.Capabilities() }
+
+func (c *Client) FlushDB(ctx context.Context) *jkv.StatusCmd {
+	return write(c, true, nil, func() *jkv.StatusCmd { return c.Inner.FlushDB(ctx) })
+}
+
+func (c *Client) Get(ctx context.Context, key string) *jkv.StringCmd {
+	val, ok, gen := c.lookup(key, nil)
+	if ok {
+		return jkv.NewStringCmd(val, nil)
+	}
+	rec := c.Inner.Get(ctx, key)
+	if rec.Err() == nil {
+		c.store(gen, key, nil, rec.Val())
+	}
+	return rec
+}
+
+func (c *Client) HGet(ctx context.Context, hash, key string) *jkv.StringCmd {
+	val, ok, gen := c.lookup(hash, &key)
+	if ok {
+		return jkv.NewStringCmd(val, nil)
+	}
+	rec := c.Inner.HGet(ctx, hash, key)
+	if rec.Err() == nil {
+		c.store(gen, hash, &key, rec.Val())
+	}
+	return rec
+}
+
+func (c *Client) GetEX(ctx context.Context, key string, opts jkv.ExpiryOptions) *jkv.StringCmd {
+	return write(c, false, []string{key}, func() *jkv.StringCmd { return c.Inner.GetEX(ctx, key, opts) })
+}
+
+func (c *Client) Set(ctx context.Context, key, value string, expiration time.Duration) *jkv.StatusCmd {
+	return write(c, false, []string{key}, func() *jkv.StatusCmd { return c.Inner.Set(ctx, key, value, expiration) })
+}
+
+func (c *Client) Del(ctx context.Context, keys ...string) *jkv.IntCmd {
+	return write(c, false, keys, func() *jkv.IntCmd { return c.Inner.Del(ctx, keys...) })
+}
+
+func (c *Client) SetNX(ctx context.Context, key, value string, expiration time.Duration) *jkv.BoolCmd {
+	return write(c, false, []string{key}, func() *jkv.BoolCmd { return c.Inner.SetNX(ctx, key, value, expiration) })
+}
+
+func (c *Client) CompareAndDelete(ctx context.Context, key, value string) *jkv.BoolCmd {
+	return write(c, false, []string{key}, func() *jkv.BoolCmd { return c.Inner.CompareAndDelete(ctx, key, value) })
+}
+
+func (c *Client) Incr(ctx context.Context, key string) *jkv.IntCmd {
+	return write(c, false, []string{key}, func() *jkv.IntCmd { return c.Inner.Incr(ctx, key) })
+}
+
+func (c *Client) Decr(ctx context.Context, key string) *jkv.IntCmd {
+	return write(c, false, []string{key}, func() *jkv.IntCmd { return c.Inner.Decr(ctx, key) })
+}
+
+func (c *Client) SetBit(ctx context.Context, key string, offset int64, value int) *jkv.IntCmd {
+	return write(c, false, []string{key}, func() *jkv.IntCmd { return c.Inner.SetBit(ctx, key, offset, value) })
+}
+
+func (c *Client) GetBit(ctx context.Context, key string, offset int64) *jkv.IntCmd {
+	return c.Inner.GetBit(ctx, key, offset)
+}
+
+func (c *Client) BitCount(ctx context.Context, key string, bitCount *jkv.BitCount) *jkv.IntCmd {
+	return c.Inner.BitCount(ctx, key, bitCount)
+}
+
+func (c *Client) PFAdd(ctx context.Context, key string, elements ...string) *jkv.IntCmd {
+	return write(c, false, []string{key}, func() *jkv.IntCmd { return c.Inner.PFAdd(ctx, key, elements...) })
+}
+
+func (c *Client) PFCount(ctx context.Context, keys ...string) *jkv.IntCmd {
+	return c.Inner.PFCount(ctx, keys...)
+}
+
+func (c *Client) PFMerge(ctx context.Context, dest string, keys ...string) *jkv.StatusCmd {
+	return write(c, false, []string{dest}, func() *jkv.StatusCmd { return c.Inner.PFMerge(ctx, dest, keys...) })
+}
+
+func (c *Client) Keys(ctx context.Context, pattern string) *jkv.StringSliceCmd {
+	return c.Inner.Keys(ctx, pattern)
+}
+
+func (c *Client) Scan(ctx context.Context, cursor string, match string, count int64) *jkv.ScanCmd {
+	return c.Inner.Scan(ctx, cursor, match, count)
+}
+
+func (c *Client) ScanType(ctx context.Context, cursor string, match string, count int64, keyType string) *jkv.ScanCmd {
+	return c.Inner.ScanType(ctx, cursor, match, count, keyType)
+}
+
+func (c *Client) Exists(ctx context.Context, keys ...string) *jkv.IntCmd {
+	return c.Inner.Exists(ctx, keys...)
+}
+
+func (c *Client) Expire(ctx context.Context, key string, expiration time.Duration) *jkv.BoolCmd {
+	return write(c, false, []string{key}, func() *jkv.BoolCmd { return c.Inner.Expire(ctx, key, expiration) })
+}
+
+func (c *Client) TTL(ctx context.Context, key string) *jkv.IntCmd { return c.Inner.TTL(ctx, key) }
+
+func (c *Client) HSet(ctx context.Context, hash string, values ...string) *jkv.IntCmd {
+	return write(c, false, []string{hash}, func() *jkv.IntCmd { return c.Inner.HSet(ctx, hash, values...) })
+}
+
+func (c *Client) HDel(ctx context.Context, hash string, values ...string) *jkv.IntCmd {
+	return write(c, false, []string{hash}, func() *jkv.IntCmd { return c.Inner.HDel(ctx, hash, values...) })
+}
+
+func (c *Client) HKeys(ctx context.Context, hash string) *jkv.StringSliceCmd {
+	return c.Inner.HKeys(ctx, hash)
+}
+
+func (c *Client) HKeysMatch(ctx context.Context, hash, pattern string) *jkv.StringSliceCmd {
+	return c.Inner.HKeysMatch(ctx, hash, pattern)
+}
+
+func (c *Client) HGetAll(ctx context.Context, hash string) *jkv.StringStringMapCmd {
+	return c.Inner.HGetAll(ctx, hash)
+}
+
+func (c *Client) HGetAllMatch(ctx context.Context, hash, pattern string) *jkv.StringStringMapCmd {
+	return c.Inner.HGetAllMatch(ctx, hash, pattern)
+}
+
+func (c *Client) HExists(ctx context.Context, hash, key string) *jkv.BoolCmd {
+	return c.Inner.HExists(ctx, hash, key)
+}
+
+func (c *Client) HExpire(ctx context.Context, hash string, seconds int64, fields ...string) *jkv.IntSliceCmd {
+	return write(c, false, []string{hash}, func() *jkv.IntSliceCmd {
+		return c.Inner.HExpire(ctx, hash, seconds, fields...)
+	})
+}
+
+func (c *Client) HTTL(ctx context.Context, hash string, fields ...string) *jkv.IntSliceCmd {
+	return c.Inner.HTTL(ctx, hash, fields...)
+}
+
+func (c *Client) Ping(ctx context.Context) *jkv.StatusCmd { return c.Inner.Ping(ctx) }
+
+func (c *Client) Version(ctx context.Context) *jkv.StringCmd { return c.Inner.Version(ctx) }
+
+func (c *Client) Info(ctx context.Context, sections ...string) *jkv.StringCmd {
+	return c.Inner.Info(ctx, sections...)
+}
+
+func (c *Client) ConfigGet(ctx context.Context, parameter string) *jkv.StringStringMapCmd {
+	return c.Inner.ConfigGet(ctx, parameter)
+}
+
+// Do may run any command, so it invalidates everything
+func (c *Client) Do(ctx context.Context, args ...interface{}) *jkv.Cmd {
+	return write(c, true, nil, func() *jkv.Cmd { return c.Inner.Do(ctx, args...) })
+}
+
+var _ jkv.Client = (*Client)(nil)
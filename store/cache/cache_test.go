@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/panduit-joeb/jkv"
+	"github.com/panduit-joeb/jkv/store/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCache(t *testing.T, ttl time.Duration) (*Client, *fs.Client) {
+	inner := fs.NewClient(&fs.Options{Addr: t.TempDir()})
+	c := New(inner, Options{TTL: ttl})
+	assert.Nil(t, c.Open())
+	t.Cleanup(c.Close)
+	return c, inner
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Serves cached values", func(t *testing.T) {
+		a := assert.New(t)
+		c, inner := newCache(t, time.Hour)
+		c.Set(ctx, "key", "value", 0)
+		c.HSet(ctx, "hash", "field", "value")
+		a.Equal("value", c.Get(ctx, "key").Val())
+		a.Equal("value", c.HGet(ctx, "hash", "field").Val())
+
+		// written behind the cache's back
+		inner.Set(ctx, "key", "changed", 0)
+		inner.HSet(ctx, "hash", "field", "changed")
+		a.Equal("value", c.Get(ctx, "key").Val())
+		a.Equal("value", c.HGet(ctx, "hash", "field").Val())
+	})
+
+	t.Run("Writes invalidate", func(t *testing.T) {
+		a := assert.New(t)
+		c, _ := newCache(t, time.Hour)
+		c.Set(ctx, "key", "value", 0)
+		c.HSet(ctx, "hash", "field", "value")
+		c.Get(ctx, "key")
+		c.HGet(ctx, "hash", "field")
+
+		c.Set(ctx, "key", "changed", 0)
+		c.HSet(ctx, "hash", "field", "changed")
+		a.Equal("changed", c.Get(ctx, "key").Val())
+		a.Equal("changed", c.HGet(ctx, "hash", "field").Val())
+		c.Del(ctx, "key")
+		a.ErrorIs(c.Get(ctx, "key").Err(), jkv.ErrKeyNotFound)
+	})
+
+	t.Run("FLUSHDB invalidates", func(t *testing.T) {
+		a := assert.New(t)
+		c, _ := newCache(t, time.Hour)
+		c.Set(ctx, "key", "value", 0)
+		c.HSet(ctx, "hash", "field", "value")
+		a.Equal("value", c.Get(ctx, "key").Val())
+		a.Equal("value", c.HGet(ctx, "hash", "field").Val())
+
+		a.Nil(c.FlushDB(ctx).Err())
+		a.ErrorIs(c.Get(ctx, "key").Err(), jkv.ErrKeyNotFound)
+		a.ErrorIs(c.HGet(ctx, "hash", "field").Err(), jkv.ErrKeyNotFound)
+	})
+
+	t.Run("Values expire", func(t *testing.T) {
+		a := assert.New(t)
+		c, inner := newCache(t, time.Millisecond)
+		c.Set(ctx, "key", "value", 0)
+		c.Get(ctx, "key")
+		inner.Set(ctx, "key", "changed", 0)
+		time.Sleep(2 * time.Millisecond)
+		a.Equal("changed", c.Get(ctx, "key").Val())
+	})
+
+	t.Run("A read racing an invalidation isn't cached", func(t *testing.T) {
+		a := assert.New(t)
+		c, _ := newCache(t, time.Hour)
+		c.Set(ctx, "key", "value", 0)
+		_, _, gen := c.lookup("key", nil)
+		c.invalidate(true)
+		c.store(gen, "key", nil, "value")
+		_, ok, _ := c.lookup("key", nil)
+		a.False(ok)
+	})
+}
@@ -0,0 +1,72 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// AuditRecord is a line of the audit log, written as JSON for each mutating command and, with AuditReads, for
+// each read
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	Op        string    `json:"op"`
+	Key       string    `json:"key,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose commands are recorded in the audit log with id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// syncer is implemented by writers such as *os.File that can flush to stable storage
+type syncer interface {
+	Sync() error
+}
+
+// audit counts the command op for INFO and appends a record for each key to AuditLog, or one without a key if there
+// are none, and syncs it
+func (c *Client) audit(ctx context.Context, op string, keys ...string) {
+	c.count(op)
+	c.record(ctx, op, keys...)
+}
+
+// record writes the audit log records of a command
+func (c *Client) record(ctx context.Context, op string, keys ...string) {
+	if c.AuditLog == nil {
+		return
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	now := time.Now()
+	if len(keys) == 0 {
+		keys = []string{""}
+	}
+	var data []byte
+	for _, key := range keys {
+		line, _ := json.Marshal(AuditRecord{Time: now, Op: op, Key: key, RequestID: id})
+		data = append(append(data, line...), '\n')
+	}
+
+	c.auditMu.Lock()
+	defer c.auditMu.Unlock()
+	if _, err := c.AuditLog.Write(data); err != nil {
+		c.Logger.Println("writing audit log failed, err", err.Error())
+		return
+	}
+	if s, ok := c.AuditLog.(syncer); ok {
+		if err := s.Sync(); err != nil {
+			c.Logger.Println("syncing audit log failed, err", err.Error())
+		}
+	}
+}
+
+// auditRead counts a read, and records it if AuditReads is set
+func (c *Client) auditRead(ctx context.Context, op string, keys ...string) {
+	c.count(op)
+	if c.auditReads() {
+		c.record(ctx, op, keys...)
+	}
+}
@@ -0,0 +1,177 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/bits"
+	"os"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+var (
+	errBitValue  = errors.New("ERR bit is not an integer or out of range")
+	errBitOffset = errors.New("ERR bit offset is not an integer or out of range")
+)
+
+// maxBitOffset is the last bit of a 512MB string, the most Redis will set
+const maxBitOffset = 1<<32 - 1
+
+// SETBIT sets or clears the bit at offset in a scalar, growing it with zero bytes as needed, and returns the bit's
+// previous value. Bit 0 is the most significant bit of the first byte, as in Redis.
+func (c *Client) SetBit(ctx context.Context, key string, offset int64, value int) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewIntCmd(0, jkv.ErrReadOnly)
+		}
+		if value != 0 && value != 1 {
+			return jkv.NewIntCmd(0, errBitValue)
+		}
+		if offset < 0 || offset > maxBitOffset {
+			return jkv.NewIntCmd(0, errBitOffset)
+		}
+		if err := c.checkNames(key); err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "SETBIT", key)
+		c.expire(key)
+		if _, err := os.Stat(c.hashPath(key)); err == nil {
+			return jkv.NewIntCmd(0, jkv.ErrWrongType)
+		}
+		// bits are set in place, so a compressed value is stored plain from now on
+		if compressed(c.scalarPath(key)) {
+			data, err := c.readFile(c.scalarPath(key))
+			if err == nil {
+				err = c.writeFile(c.scalarPath(key), data, 0660)
+			}
+			if err != nil {
+				return jkv.NewIntCmd(0, err)
+			}
+		}
+
+		f, err := os.OpenFile(c.scalarPath(key), os.O_RDWR|os.O_CREATE, 0660)
+		if err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		defer f.Close()
+
+		b := make([]byte, 1)
+		if _, err := f.ReadAt(b, offset/8); err != nil && err != io.EOF {
+			return jkv.NewIntCmd(0, err)
+		}
+		mask := byte(0x80) >> (offset % 8)
+		old := int64(0)
+		if b[0]&mask != 0 {
+			old = 1
+		}
+		if value == 1 {
+			b[0] |= mask
+		} else {
+			b[0] &^= mask
+		}
+		// writing past the end fills the gap with zero bytes
+		if _, err := f.WriteAt(b, offset/8); err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		if err := c.sync(f); err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		return jkv.NewIntCmd(old, nil)
+	}
+	return jkv.NewIntCmd(0, c.notOpen())
+}
+
+// GETBIT returns the bit at offset in a scalar, 0 past the end or for a missing key, or jkv.ErrWrongType for a hash
+func (c *Client) GetBit(ctx context.Context, key string, offset int64) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.auditRead(ctx, "GETBIT", key)
+		c.settle()
+		if offset < 0 || offset > maxBitOffset {
+			return jkv.NewIntCmd(0, errBitOffset)
+		}
+		if c.lazyExpire(key) {
+			return jkv.NewIntCmd(0, nil)
+		}
+		f, err := os.Open(c.scalarPath(key))
+		if os.IsNotExist(err) {
+			if _, err := os.Stat(c.hashPath(key)); err == nil {
+				return jkv.NewIntCmd(0, jkv.ErrWrongType)
+			}
+			return jkv.NewIntCmd(0, nil)
+		} else if err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		defer f.Close()
+		var r io.ReaderAt = f
+		if compressed(c.scalarPath(key)) {
+			data, err := c.readFile(c.scalarPath(key))
+			if err != nil {
+				return jkv.NewIntCmd(0, err)
+			}
+			r = bytes.NewReader(data)
+		}
+
+		b := make([]byte, 1)
+		if _, err := r.ReadAt(b, offset/8); err == io.EOF {
+			return jkv.NewIntCmd(0, nil)
+		} else if err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		if b[0]&(byte(0x80)>>(offset%8)) != 0 {
+			return jkv.NewIntCmd(1, nil)
+		}
+		return jkv.NewIntCmd(0, nil)
+	}
+	return jkv.NewIntCmd(0, c.notOpen())
+}
+
+// BITCOUNT counts the set bits of a scalar, in the byte range Start..End if bitCount is not nil. Negative offsets
+// count back from the last byte.
+func (c *Client) BitCount(ctx context.Context, key string, bitCount *jkv.BitCount) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.auditRead(ctx, "BITCOUNT", key)
+		c.settle()
+		if c.lazyExpire(key) {
+			return jkv.NewIntCmd(0, nil)
+		}
+		data, err := c.readFile(c.scalarPath(key))
+		if os.IsNotExist(err) {
+			return jkv.NewIntCmd(0, nil)
+		} else if err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		if bitCount != nil {
+			start, end := bitCount.Start, bitCount.End
+			size := int64(len(data))
+			if start < 0 {
+				start += size
+			}
+			if end < 0 {
+				end += size
+			}
+			if start < 0 {
+				start = 0
+			}
+			if end >= size {
+				end = size - 1
+			}
+			if start > end {
+				return jkv.NewIntCmd(0, nil)
+			}
+			data = data[start : end+1]
+		}
+		n := 0
+		for _, b := range data {
+			n += bits.OnesCount8(b)
+		}
+		return jkv.NewIntCmd(int64(n), nil)
+	}
+	return jkv.NewIntCmd(0, c.notOpen())
+}
@@ -0,0 +1,115 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+)
+
+// In bulk mode SET without an expiration only buffers the value, the buffer is written out once it holds BulkBatch
+// values, before any other write and before a read that could see it. A buffered value is lost if the process dies
+// before it is written out, and like any write a value is only fsynced if Durable is set. Only use it for imports
+// that can be rerun.
+
+// DEFAULT_BULK_BATCH is the number of values buffered in bulk mode when Options.BulkBatch is 0
+const DEFAULT_BULK_BATCH = 1000
+
+// BeginBulk starts buffering SETs, see EndBulk
+func (c *Client) BeginBulk(ctx context.Context) error {
+	if !c.IsOpen() {
+		return c.notOpen()
+	}
+	c.lock()
+	defer c.unlock()
+	c.pending, c.order = map[string]string{}, nil
+	c.bulkErr = nil
+	atomic.StoreInt32(&c.bulk, 1)
+	return nil
+}
+
+// EndBulk writes the buffered values and returns to normal writes, fsyncing the scalars directory if Durable is set.
+// The error is the first one met writing since BeginBulk.
+func (c *Client) EndBulk(ctx context.Context) error {
+	if !c.IsOpen() {
+		return c.notOpen()
+	}
+	c.lock()
+	defer c.unlock()
+	atomic.StoreInt32(&c.bulk, 0)
+	err := c.bulkErr
+	if c.durable() {
+		if serr := c.syncFile(c.ScalarDir()); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	c.pending, c.order, c.bulkErr = nil, nil, nil
+	return err
+}
+
+// syncFile opens path to fsync it
+func (c *Client) syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.sync(f)
+}
+
+// inBulk returns true between BeginBulk and EndBulk
+func (c *Client) inBulk() bool { return atomic.LoadInt32(&c.bulk) != 0 }
+
+// settle writes out buffered bulk writes before a read
+func (c *Client) settle() {
+	if c.inBulk() {
+		c.lock()
+		c.unlock()
+	}
+}
+
+// bulkSet buffers a SET, writing the buffer out when it is full. It returns false without buffering anything if
+// EndBulk ran since the caller checked inBulk, and the caller should write the value itself.
+func (c *Client) bulkSet(ctx context.Context, key, value string) bool {
+	c.acquire()
+	defer c.unlock()
+	if !c.inBulk() {
+		return false
+	}
+	c.audit(ctx, "SET", key)
+	if _, ok := c.pending[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.pending[key] = value
+	if len(c.pending) >= c.bulkBatch() {
+		c.flush()
+	}
+	return true
+}
+
+// flush writes the buffered values with the lock held. Sidecars are listed once rather than removed for each key,
+// which together with skipping the lock per key is where bulk mode saves time.
+func (c *Client) flush() {
+	sidecars := map[string]bool{}
+	for _, dir := range []string{c.ExpireDir(), c.MetaDir()} {
+		entries, _ := readDir(dir)
+		for _, entry := range entries {
+			if key, ok := c.nameOf(entry.Name()); ok {
+				sidecars[key] = true
+			}
+		}
+	}
+	for _, key := range c.order {
+		if err := c.writeValue(c.scalarPath(key), []byte(c.pending[key]), 0660); err != nil {
+			if c.bulkErr == nil {
+				c.bulkErr = err
+			}
+			c.Logger.Println("bulk write of", key, "failed, err", err.Error())
+			continue
+		}
+		if sidecars[key] {
+			c.clearDeadline(key)
+			c.clearMeta(key)
+		}
+	}
+	c.pending, c.order = map[string]string{}, c.order[:0]
+}
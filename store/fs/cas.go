@@ -0,0 +1,137 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// CompareAndSet sets key to new if its value is old, returning true if it did. A missing key matches no value, not
+// even "", use SetNX to create a key only if it doesn't exist. Like SET, a swap clears the expiration of key.
+func (c *Client) CompareAndSet(ctx context.Context, key, old, new string) (res *jkv.BoolCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewBoolCmd(false, jkv.ErrReadOnly)
+		}
+		if err := c.checkNames(key); err != nil {
+			return jkv.NewBoolCmd(false, err)
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "CAS", key)
+		c.expire(key)
+		if _, err := os.Stat(c.hashPath(key)); err == nil {
+			return jkv.NewBoolCmd(false, jkv.ErrWrongType)
+		}
+		data, err := c.readFile(c.scalarPath(key))
+		if os.IsNotExist(err) {
+			return jkv.NewBoolCmd(false, nil)
+		} else if err != nil {
+			return jkv.NewBoolCmd(false, err)
+		}
+		if string(data) != old {
+			return jkv.NewBoolCmd(false, nil)
+		}
+		if err := c.writeValue(c.scalarPath(key), []byte(new), 0660); err != nil {
+			return jkv.NewBoolCmd(false, err)
+		}
+		c.clearMeta(key)
+		c.clearDeadline(key)
+		return jkv.NewBoolCmd(true, nil)
+	}
+	return jkv.NewBoolCmd(false, c.notOpen())
+}
+
+// SETNX sets key to value, expiring after expiration if it is positive, only if key doesn't exist. It returns true
+// if key was set.
+func (c *Client) SetNX(ctx context.Context, key, value string, expiration time.Duration) (res *jkv.BoolCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewBoolCmd(false, jkv.ErrReadOnly)
+		}
+		if err := c.checkNames(key); err != nil {
+			return jkv.NewBoolCmd(false, err)
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "SETNX", key)
+		c.expire(key)
+		if c.exists(key) {
+			return jkv.NewBoolCmd(false, nil)
+		}
+		if err := c.writeValue(c.scalarPath(key), []byte(value), 0660); err != nil {
+			return jkv.NewBoolCmd(false, err)
+		}
+		c.clearMeta(key)
+		return jkv.NewBoolCmd(true, c.expireAfter(key, c.expiration(expiration)))
+	}
+	return jkv.NewBoolCmd(false, c.notOpen())
+}
+
+// CompareAndDelete deletes the scalar key if its value is value, returning true if it did
+func (c *Client) CompareAndDelete(ctx context.Context, key, value string) (res *jkv.BoolCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewBoolCmd(false, jkv.ErrReadOnly)
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "CAD", key)
+		c.expire(key)
+		data, err := c.readFile(c.scalarPath(key))
+		if os.IsNotExist(err) || err == nil && string(data) != value {
+			return jkv.NewBoolCmd(false, nil)
+		} else if err != nil {
+			return jkv.NewBoolCmd(false, err)
+		}
+		if err := os.Remove(c.scalarPath(key)); err != nil {
+			return jkv.NewBoolCmd(false, err)
+		}
+		c.clearDeadline(key)
+		c.clearMeta(key)
+		return jkv.NewBoolCmd(true, nil)
+	}
+	return jkv.NewBoolCmd(false, c.notOpen())
+}
+
+// HAPPEND appends value to a hash field while holding the lock, creating the hash and field if they don't exist, and
+// returns the length of the new value. The field keeps any expiration it has.
+func (c *Client) HAppend(ctx context.Context, hash, field, value string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewIntCmd(0, jkv.ErrReadOnly)
+		}
+		if err := c.checkNames(hash, field); err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "HAPPEND", hash)
+		c.expireField(hash, field)
+		if c.existsStat([]string{hash}) > 0 {
+			return jkv.NewIntCmd(0, jkv.ErrWrongType)
+		}
+		if err := os.MkdirAll(c.hashPath(hash), 0775); err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		data, err := c.readFile(c.fieldPath(hash, field))
+		if err != nil && !os.IsNotExist(err) {
+			return jkv.NewIntCmd(0, err)
+		}
+		data = append(data, value...)
+		if err := c.writeValue(c.fieldPath(hash, field), data, 0664); err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		if c.DefaultTTL > 0 {
+			return jkv.NewIntCmd(int64(len(data)), c.setDeadline(hash, c.Clock.Now().Add(c.DefaultTTL)))
+		}
+		return jkv.NewIntCmd(int64(len(data)), nil)
+	}
+	return jkv.NewIntCmd(0, c.notOpen())
+}
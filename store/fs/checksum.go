@@ -0,0 +1,71 @@
+package fs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"os"
+	"sort"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// writeLen adds n to h as 8 bytes
+func writeLen(h hash.Hash, n int) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(n))
+	h.Write(b[:])
+}
+
+// writeField adds s to h prefixed by its length, so the boundaries between names and values can't shift
+func writeField(h hash.Hash, s string) {
+	writeLen(h, len(s))
+	h.Write([]byte(s))
+}
+
+// CHECKSUM returns a SHA-256 digest of the keys, hash fields and values in the database. Keys and fields are hashed
+// in sorted order, so two databases with the same contents have the same checksum however their files are laid out.
+// Deadlines and metadata aren't included. The writer lock is held throughout so the digest is of one moment.
+func (c *Client) Checksum(ctx context.Context) (res *jkv.StringCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.lock()
+		defer c.unlock()
+		c.auditRead(ctx, "CHECKSUM")
+		names, err := c.names()
+		if err != nil {
+			return jkv.NewStringCmd("", err)
+		}
+		h := sha256.New()
+		for _, name := range names {
+			if data, err := c.readFile(c.scalarPath(name)); err == nil {
+				h.Write([]byte{'s'})
+				writeField(h, name)
+				writeField(h, string(data))
+				continue
+			} else if !os.IsNotExist(err) {
+				return jkv.NewStringCmd("", err)
+			}
+			fields, err := c.fields(name, "")
+			if err != nil {
+				return jkv.NewStringCmd("", err)
+			}
+			sort.Strings(fields)
+			h.Write([]byte{'h'})
+			writeField(h, name)
+			writeLen(h, len(fields))
+			for _, field := range fields {
+				data, err := c.readFile(c.fieldPath(name, field))
+				if err != nil {
+					return jkv.NewStringCmd("", err)
+				}
+				writeField(h, field)
+				writeField(h, string(data))
+			}
+		}
+		return jkv.NewStringCmd(hex.EncodeToString(h.Sum(nil)), nil)
+	}
+	return jkv.NewStringCmd("", c.notOpen())
+}
@@ -0,0 +1,13 @@
+package fs
+
+import "time"
+
+// Clock tells the time for expiry, so tests can move it forward instead of sleeping
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock used unless Options.Clock is set
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
@@ -0,0 +1,86 @@
+package fs
+
+import (
+	"context"
+	"os"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// COMPACT removes what churn leaves behind: empty hash directories and sidecar files whose key or field is gone. It
+// returns the bytes reclaimed, counting the size the filesystem reports for each file and directory removed. Readers
+// don't take the lock, so they are never blocked by it.
+func (c *Client) Compact(ctx context.Context) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewIntCmd(0, jkv.ErrReadOnly)
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "COMPACT")
+
+		reclaimed := int64(0)
+		remove := func(path string, info os.FileInfo) {
+			if os.Remove(path) == nil {
+				reclaimed += info.Size()
+			}
+		}
+
+		// empty hashes, including those left on purpose by KeepEmptyHashes
+		hashes, err := readDir(c.HashDir())
+		if err != nil && !os.IsNotExist(err) {
+			return jkv.NewIntCmd(0, err)
+		}
+		for _, entry := range hashes {
+			hash, ok := c.nameOf(entry.Name())
+			if info, err := entry.Info(); err == nil && ok && entry.IsDir() && c.emptyHash(hash) {
+				remove(c.HashDir()+entry.Name(), info)
+			}
+		}
+
+		// deadlines and metadata of keys that no longer exist
+		for _, dir := range []string{c.ExpireDir(), c.MetaDir()} {
+			entries, _ := readDir(dir)
+			for _, entry := range entries {
+				key, ok := c.nameOf(entry.Name())
+				if !ok {
+					continue
+				}
+				if _, err := os.Stat(c.scalarPath(key)); err == nil {
+					continue
+				}
+				if dir == c.ExpireDir() {
+					if info, err := os.Stat(c.hashPath(key)); err == nil && info.IsDir() {
+						continue
+					}
+				}
+				if info, err := entry.Info(); err == nil {
+					remove(dir+entry.Name(), info)
+				}
+			}
+		}
+
+		// field deadlines of fields that no longer exist, and then the directories they leave empty. Both sides are
+		// named by diskName, so the names listed here are compared as they are.
+		hexpires, _ := readDir(c.FieldExpireDir())
+		for _, hash := range hexpires {
+			fields, _ := readDir(c.FieldExpireDir() + hash.Name())
+			left := len(fields)
+			for _, field := range fields {
+				if _, err := os.Stat(c.HashDir() + hash.Name() + "/" + field.Name()); err == nil {
+					continue
+				}
+				if info, err := field.Info(); err == nil {
+					remove(c.FieldExpireDir()+hash.Name()+"/"+field.Name(), info)
+					left--
+				}
+			}
+			if info, err := hash.Info(); err == nil && left == 0 {
+				remove(c.FieldExpireDir()+hash.Name(), info)
+			}
+		}
+		return jkv.NewIntCmd(reclaimed, nil)
+	}
+	return jkv.NewIntCmd(0, c.notOpen())
+}
@@ -0,0 +1,57 @@
+package fs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+// A value of at least CompressMinBytes is stored gzip compressed behind compressMagic. readFile looks for the magic
+// and decompresses, so compressed and plain values live side by side and changing CompressMinBytes needs no
+// migration of the values already stored.
+const compressMagic = "JKVGZ1\n"
+
+// compress returns value as it is to be stored. A value that starts with compressMagic is compressed whatever its
+// size, so it can't be mistaken for a compressed one when it is read back.
+func (c *Client) compress(value []byte) []byte {
+	if (c.CompressMinBytes <= 0 || len(value) < c.CompressMinBytes) && !bytes.HasPrefix(value, []byte(compressMagic)) {
+		return value
+	}
+	var b bytes.Buffer
+	b.WriteString(compressMagic)
+	w := gzip.NewWriter(&b)
+	w.Write(value) // writes to a bytes.Buffer don't fail
+	w.Close()
+	return b.Bytes()
+}
+
+// decompress returns the value stored as data
+func decompress(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(compressMagic)) {
+		return data, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data[len(compressMagic):]))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// compressed returns true if the file at path holds a compressed value
+func compressed(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, len(compressMagic))
+	_, err = io.ReadFull(f, magic)
+	return err == nil && string(magic) == compressMagic
+}
+
+// writeValue writes value to the file at path, compressed if it is large enough
+func (c *Client) writeValue(path string, value []byte, perm os.FileMode) error {
+	return c.writeFile(path, c.compress(value), perm)
+}
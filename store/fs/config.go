@@ -0,0 +1,147 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// config returns the settings of the client by their CONFIG GET names
+func (c *Client) config() map[string]string {
+	return map[string]string{
+		"backend":           "fs",
+		"dir":               c.GetDBDir(),
+		"db":                strconv.Itoa(c.DB),
+		"readonly":          yesNo(c.readOnly()),
+		"max-key-len":       strconv.Itoa(c.maxKeyLen()),
+		"sort-keys":         yesNo(c.sortKeys()),
+		"file-naming":       c.FileNaming.String(),
+		"include-expired":   yesNo(c.includeExpired()),
+		"audit-log":         yesNo(c.AuditLog != nil),
+		"audit-reads":       yesNo(c.auditReads()),
+		"record-duration":   yesNo(c.recordDuration()),
+		"bulk-batch":        strconv.Itoa(c.bulkBatch()),
+		"durable":           yesNo(c.durable()),
+		"keep-empty-hashes": yesNo(c.keepEmptyHashes()),
+		"active-expire":     yesNo(c.activeExpire()),
+	}
+}
+
+// SetOption can change these settings while other goroutines use the client, so they are read under c.optMu
+
+func (c *Client) readOnly() bool        { return c.option(&c.ReadOnly) }
+func (c *Client) sortKeys() bool        { return c.option(&c.SortKeys) }
+func (c *Client) includeExpired() bool  { return c.option(&c.IncludeExpired) }
+func (c *Client) auditReads() bool      { return c.option(&c.AuditReads) }
+func (c *Client) recordDuration() bool  { return c.option(&c.RecordDuration) }
+func (c *Client) durable() bool         { return c.option(&c.Durable) }
+func (c *Client) keepEmptyHashes() bool { return c.option(&c.KeepEmptyHashes) }
+
+func (c *Client) option(flag *bool) bool {
+	c.optMu.RLock()
+	defer c.optMu.RUnlock()
+	return *flag
+}
+
+func (c *Client) maxKeyLen() int { return c.number(&c.MaxKeyLen) }
+func (c *Client) bulkBatch() int { return c.number(&c.BulkBatch) }
+
+func (c *Client) number(n *int) int {
+	c.optMu.RLock()
+	defer c.optMu.RUnlock()
+	return *n
+}
+
+// activeExpire is read under c.reaperMu, which SetActiveExpire holds to change it
+func (c *Client) activeExpire() bool {
+	c.reaperMu.Lock()
+	defer c.reaperMu.Unlock()
+	return c.ActiveExpire
+}
+
+// VERSION returns the version of jkv
+func (c *Client) Version(ctx context.Context) (res *jkv.StringCmd) {
+	defer timed(c.start(), &res)
+	return jkv.NewStringCmd(jkv.BuildVersion(), nil)
+}
+
+// CONFIG GET returns the settings whose names match the glob pattern parameter
+func (c *Client) ConfigGet(ctx context.Context, parameter string) (res *jkv.StringStringMapCmd) {
+	defer timed(c.start(), &res)
+	if _, err := filepath.Match(parameter, ""); err != nil {
+		return jkv.NewStringStringMapCmd(map[string]string{}, err)
+	}
+	settings := map[string]string{}
+	for name, value := range c.config() {
+		if ok, _ := filepath.Match(parameter, name); ok {
+			settings[name] = value
+		}
+	}
+	return jkv.NewStringStringMapCmd(settings, nil)
+}
+
+// SetOption changes a setting by its CONFIG GET name while the client is in use, like CONFIG SET. Settings that need
+// the database to be reopened, such as dir, are rejected. Once the client is in use, only change its settings here.
+func (c *Client) SetOption(ctx context.Context, name, value string) error {
+	invalid := fmt.Errorf("ERR Invalid argument '%s' for CONFIG SET '%s'", value, name)
+	var flag *bool
+	switch name {
+	case "readonly":
+		flag = &c.ReadOnly
+	case "sort-keys":
+		flag = &c.SortKeys
+	case "include-expired":
+		flag = &c.IncludeExpired
+	case "audit-reads":
+		flag = &c.AuditReads
+	case "record-duration":
+		flag = &c.RecordDuration
+	case "durable":
+		flag = &c.Durable
+	case "keep-empty-hashes":
+		flag = &c.KeepEmptyHashes
+	case "active-expire":
+		if value != "yes" && value != "no" {
+			return invalid
+		}
+		c.SetActiveExpire(value == "yes")
+		return nil
+	case "max-key-len", "bulk-batch":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return invalid
+		}
+		c.optMu.Lock()
+		defer c.optMu.Unlock()
+		if name == "max-key-len" {
+			c.MaxKeyLen = n
+		} else {
+			c.BulkBatch = n
+		}
+		return nil
+	case "backend", "dir", "db", "file-naming", "audit-log":
+		return fmt.Errorf("ERR CONFIG SET '%s' can't be changed without reopening the database", name)
+	default:
+		return fmt.Errorf("ERR Unknown option or number of arguments for CONFIG SET - '%s'", name)
+	}
+	if value != "yes" && value != "no" {
+		return invalid
+	}
+	// writers in progress finish under the old setting
+	c.lock()
+	defer c.unlock()
+	c.optMu.Lock()
+	defer c.optMu.Unlock()
+	*flag = value == "yes"
+	return nil
+}
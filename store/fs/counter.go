@@ -0,0 +1,98 @@
+package fs
+
+import (
+	"context"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// readInt returns the integer value of a scalar with the lock held, a missing key is 0
+func (c *Client) readInt(key string) (int64, error) {
+	c.expire(key)
+	data, err := c.readFile(c.scalarPath(key))
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, jkv.ErrNotInteger
+	}
+	return n, nil
+}
+
+// writeInt stores n in a scalar with the lock held, leaving its expiration alone
+func (c *Client) writeInt(key string, n int64) error {
+	return c.writeFile(c.scalarPath(key), []byte(strconv.FormatInt(n, 10)), 0660)
+}
+
+// INCR adds 1 to the integer value of key, a missing key counting as 0, and returns the new value
+func (c *Client) Incr(ctx context.Context, key string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	return c.incrBy(ctx, "INCR", key, 1)
+}
+
+// DECR subtracts 1 from the integer value of key, a missing key counting as 0, and returns the new value
+func (c *Client) Decr(ctx context.Context, key string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	return c.incrBy(ctx, "DECR", key, -1)
+}
+
+// incrBy adds delta to the integer value of key while holding the lock, leaving its expiration alone like Redis does
+func (c *Client) incrBy(ctx context.Context, cmd, key string, delta int64) *jkv.IntCmd {
+	if !c.IsOpen() {
+		return jkv.NewIntCmd(0, c.notOpen())
+	}
+	if c.readOnly() {
+		return jkv.NewIntCmd(0, jkv.ErrReadOnly)
+	}
+	if err := c.checkNames(key); err != nil {
+		return jkv.NewIntCmd(0, err)
+	}
+	c.lock()
+	defer c.unlock()
+	c.audit(ctx, cmd, key)
+
+	n, err := c.readInt(key)
+	if err != nil {
+		return jkv.NewIntCmd(0, err)
+	}
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		return jkv.NewIntCmd(0, jkv.ErrOverflow)
+	}
+	return jkv.NewIntCmd(n+delta, c.writeInt(key, n+delta))
+}
+
+// IncrIfBelow atomically increments key only if its current value is below limit, returning the new value and true,
+// or the unchanged value and false if the limit has been reached
+func (c *Client) IncrIfBelow(ctx context.Context, key string, limit int64) (int64, bool, error) {
+	if !c.IsOpen() {
+		return 0, false, c.notOpen()
+	}
+	if c.readOnly() {
+		return 0, false, jkv.ErrReadOnly
+	}
+	if err := c.checkNames(key); err != nil {
+		return 0, false, err
+	}
+	c.lock()
+	defer c.unlock()
+	c.audit(ctx, "INCR", key)
+
+	n, err := c.readInt(key)
+	if err != nil {
+		return 0, false, err
+	}
+	if n >= limit {
+		return n, false, nil
+	}
+	if err := c.writeInt(key, n+1); err != nil {
+		return n, false, err
+	}
+	return n + 1, true, nil
+}
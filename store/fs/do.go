@@ -0,0 +1,143 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// command runs a command issued with Do, args don't include the command name
+type command struct {
+	arity int // number of args, or the negated minimum like redis
+	run   func(ctx context.Context, c *Client, args []string) *jkv.Cmd
+}
+
+// commands are the commands Do understands, replying with the types redis would
+var commands = map[string]command{
+	"PING":    {0, func(ctx context.Context, c *Client, args []string) *jkv.Cmd { return status(c.Ping(ctx)) }},
+	"FLUSHDB": {0, func(ctx context.Context, c *Client, args []string) *jkv.Cmd { return status(c.FlushDB(ctx)) }},
+	"GET": {1, func(ctx context.Context, c *Client, args []string) *jkv.Cmd {
+		rec := c.Get(ctx, args[0])
+		return jkv.NewCmd(rec.Val(), rec.Err())
+	}},
+	"SET": {-2, func(ctx context.Context, c *Client, args []string) *jkv.Cmd {
+		var expiration time.Duration
+		if len(args) == 3 {
+			switch strings.ToUpper(args[2]) {
+			case "KEEPTTL":
+				expiration = jkv.KeepTTL
+			case "PERSIST":
+				expiration = jkv.NoExpiration
+			default:
+				return jkv.NewCmd(nil, errSyntax)
+			}
+		} else if len(args) == 4 {
+			n, err := strconv.ParseInt(args[3], 10, 64)
+			if err != nil || n <= 0 {
+				return jkv.NewCmd(nil, jkv.ErrNotInteger)
+			}
+			switch strings.ToUpper(args[2]) {
+			case "EX":
+				expiration = time.Duration(n) * time.Second
+			case "PX":
+				expiration = time.Duration(n) * time.Millisecond
+			default:
+				return jkv.NewCmd(nil, errSyntax)
+			}
+		} else if len(args) != 2 {
+			return jkv.NewCmd(nil, errSyntax)
+		}
+		return status(c.Set(ctx, args[0], args[1], expiration))
+	}},
+	"DEL":    {-1, func(ctx context.Context, c *Client, args []string) *jkv.Cmd { return integer(c.Del(ctx, args...)) }},
+	"EXISTS": {-1, func(ctx context.Context, c *Client, args []string) *jkv.Cmd { return integer(c.Exists(ctx, args...)) }},
+	"KEYS":   {1, func(ctx context.Context, c *Client, args []string) *jkv.Cmd { return list(c.Keys(ctx, args[0])) }},
+	"EXPIRE": {2, func(ctx context.Context, c *Client, args []string) *jkv.Cmd {
+		n, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return jkv.NewCmd(nil, jkv.ErrNotInteger)
+		}
+		return boolean(c.Expire(ctx, args[0], time.Duration(n)*time.Second))
+	}},
+	"SWAP": {2, func(ctx context.Context, c *Client, args []string) *jkv.Cmd {
+		return status(c.Swap(ctx, args[0], args[1]))
+	}},
+	"INCR": {1, func(ctx context.Context, c *Client, args []string) *jkv.Cmd { return integer(c.Incr(ctx, args[0])) }},
+	"DECR": {1, func(ctx context.Context, c *Client, args []string) *jkv.Cmd { return integer(c.Decr(ctx, args[0])) }},
+	"TTL":  {1, func(ctx context.Context, c *Client, args []string) *jkv.Cmd { return integer(c.TTL(ctx, args[0])) }},
+	"HGET": {2, func(ctx context.Context, c *Client, args []string) *jkv.Cmd {
+		rec := c.HGet(ctx, args[0], args[1])
+		return jkv.NewCmd(rec.Val(), rec.Err())
+	}},
+	"HSET": {-3, func(ctx context.Context, c *Client, args []string) *jkv.Cmd {
+		if len(args)%2 == 0 {
+			return jkv.NewCmd(nil, errors.New("ERR wrong number of arguments for 'hset' command"))
+		}
+		return integer(c.HSet(ctx, args[0], args[1:]...))
+	}},
+	"HAPPEND": {3, func(ctx context.Context, c *Client, args []string) *jkv.Cmd {
+		return integer(c.HAppend(ctx, args[0], args[1], args[2]))
+	}},
+	"HDEL": {-2, func(ctx context.Context, c *Client, args []string) *jkv.Cmd {
+		return integer(c.HDel(ctx, args[0], args[1:]...))
+	}},
+	"PFADD": {-1, func(ctx context.Context, c *Client, args []string) *jkv.Cmd {
+		return integer(c.PFAdd(ctx, args[0], args[1:]...))
+	}},
+	"PFCOUNT": {-1, func(ctx context.Context, c *Client, args []string) *jkv.Cmd { return integer(c.PFCount(ctx, args...)) }},
+	"PFMERGE": {-1, func(ctx context.Context, c *Client, args []string) *jkv.Cmd {
+		return status(c.PFMerge(ctx, args[0], args[1:]...))
+	}},
+	"HKEYS": {1, func(ctx context.Context, c *Client, args []string) *jkv.Cmd { return list(c.HKeys(ctx, args[0])) }},
+	"HEXISTS": {2, func(ctx context.Context, c *Client, args []string) *jkv.Cmd {
+		return boolean(c.HExists(ctx, args[0], args[1]))
+	}},
+}
+
+var errSyntax = errors.New("ERR syntax error")
+
+func status(rec *jkv.StatusCmd) *jkv.Cmd { return jkv.NewCmd(rec.Val(), rec.Err()) }
+func integer(rec *jkv.IntCmd) *jkv.Cmd   { return jkv.NewCmd(rec.Val(), rec.Err()) }
+
+// boolean replies 1 or 0 like redis does for true and false
+func boolean(rec *jkv.BoolCmd) *jkv.Cmd {
+	if rec.Val() {
+		return jkv.NewCmd(int64(1), rec.Err())
+	}
+	return jkv.NewCmd(int64(0), rec.Err())
+}
+
+func list(rec *jkv.StringSliceCmd) *jkv.Cmd {
+	values := make([]interface{}, len(rec.Val()))
+	for i, v := range rec.Val() {
+		values[i] = v
+	}
+	return jkv.NewCmd(values, rec.Err())
+}
+
+// Do runs the command in args, e.g. Do(ctx, "hset", "hash", "field", "value"), for callers that build commands
+// at run time. Only the commands in the commands table are supported.
+func (c *Client) Do(ctx context.Context, args ...interface{}) (res *jkv.Cmd) {
+	defer timed(c.start(), &res)
+	if len(args) == 0 {
+		return jkv.NewCmd(nil, errors.New("ERR no command"))
+	}
+	name := fmt.Sprint(args[0])
+	cmd, ok := commands[strings.ToUpper(name)]
+	if !ok {
+		return jkv.NewCmd(nil, fmt.Errorf("ERR unknown command '%s'", name))
+	}
+	if n := len(args) - 1; cmd.arity >= 0 && n != cmd.arity || cmd.arity < 0 && n < -cmd.arity {
+		return jkv.NewCmd(nil, fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+	}
+	strs := make([]string, len(args)-1)
+	for i, arg := range args[1:] {
+		strs[i] = fmt.Sprint(arg)
+	}
+	return cmd.run(ctx, c, strs)
+}
@@ -0,0 +1,60 @@
+package fs
+
+import (
+	"os"
+	"sync/atomic"
+)
+
+// readFile is readRaw, or a read through the handle cache if there is one, counting the bytes read for INFO and
+// decompressing a compressed value
+func (c *Client) readFile(name string) ([]byte, error) {
+	read := readRaw
+	if c.handles != nil {
+		read = c.handles.read
+	}
+	data, err := read(name)
+	atomic.AddInt64(&c.stats.bytesRead, int64(len(data)))
+	if err != nil {
+		return data, err
+	}
+	return decompress(data)
+}
+
+// writeFile writes data to a temporary file that is renamed over name, so a reader sees the old contents or the new
+// ones and never an empty or partly written file. With Durable set the temporary file is fsynced before the rename.
+func (c *Client) writeFile(name string, data []byte, perm os.FileMode) error {
+	atomic.AddInt64(&c.stats.bytesWritten, int64(len(data)))
+	if c.handles != nil {
+		c.handles.drop(name)
+	}
+	// the temporary file is kept out of the key and field directories so KEYS and HKEYS never list it
+	f, err := retryEINTR(func() (*os.File, error) { return sysCreateTemp(c.GetDBDir(), ".write-*") })
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = c.sync(f)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// sync flushes f to stable storage if Durable is set
+func (c *Client) sync(f *os.File) error {
+	if !c.durable() {
+		return nil
+	}
+	atomic.AddInt64(&c.stats.fsyncs, 1)
+	return f.Sync()
+}
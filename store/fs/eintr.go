@@ -0,0 +1,49 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// A syscall interrupted by a signal fails with EINTR. The os package retries reads and writes itself but not every
+// call on every platform, e.g. an open or getdents on a network or FUSE filesystem, so the store's file reads and
+// writes go through these wrappers, which retry EINTR before giving up.
+
+// eintrRetries is how many times a call that failed with EINTR is retried before its error is returned
+const eintrRetries = 8
+
+// the calls the wrappers make, tests swap them for ones that fail
+var (
+	sysOpen       = os.Open
+	sysReadDir    = os.ReadDir
+	sysReadFile   = os.ReadFile
+	sysWriteFile  = os.WriteFile
+	sysCreateTemp = os.CreateTemp
+)
+
+// retryEINTR calls fn until it doesn't fail with EINTR or has been retried eintrRetries times
+func retryEINTR[T any](fn func() (T, error)) (v T, err error) {
+	for i := 0; i <= eintrRetries; i++ {
+		if v, err = fn(); !errors.Is(err, syscall.EINTR) {
+			break
+		}
+	}
+	return v, err
+}
+
+// readDir is os.ReadDir, retried on EINTR
+func readDir(name string) ([]os.DirEntry, error) {
+	return retryEINTR(func() ([]os.DirEntry, error) { return sysReadDir(name) })
+}
+
+// readRaw is os.ReadFile, retried on EINTR, it returns the file as it is stored
+func readRaw(name string) ([]byte, error) {
+	return retryEINTR(func() ([]byte, error) { return sysReadFile(name) })
+}
+
+// writeRaw is os.WriteFile, retried on EINTR
+func writeRaw(name string, data []byte, perm os.FileMode) error {
+	_, err := retryEINTR(func() (struct{}, error) { return struct{}{}, sysWriteFile(name, data, perm) })
+	return err
+}
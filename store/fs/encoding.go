@@ -0,0 +1,38 @@
+package fs
+
+import (
+	"context"
+	"os"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// Encodings reported by Encoding
+const (
+	EncodingRaw     = "raw"      // a scalar stored as is in one file
+	EncodingGzip    = "gzip"     // a scalar stored gzip compressed in one file, see Options.CompressMinBytes
+	EncodingHashDir = "hash-dir" // a hash stored as a directory with a file per field
+)
+
+// Encoding returns how key is stored on disk, like OBJECT ENCODING. A missing key is an error.
+func (c *Client) Encoding(ctx context.Context, key string) (res *jkv.StringCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.settle()
+		if c.lazyExpire(key) {
+			return jkv.NewStringCmd("", os.ErrNotExist)
+		}
+		if _, err := os.Stat(c.scalarPath(key)); err == nil {
+			if compressed(c.scalarPath(key)) {
+				return jkv.NewStringCmd(EncodingGzip, nil)
+			}
+			return jkv.NewStringCmd(EncodingRaw, nil)
+		}
+		_, err := os.Stat(c.hashPath(key))
+		if err != nil {
+			return jkv.NewStringCmd("", err)
+		}
+		return jkv.NewStringCmd(EncodingHashDir, nil)
+	}
+	return jkv.NewStringCmd("", c.notOpen())
+}
@@ -0,0 +1,61 @@
+package fs
+
+import "os"
+
+// EXISTS can stat each key or read the scalars directory once and look the keys up in the listing. Reading an entry
+// costs roughly an eighth of a stat, so listing wins once there are more than an eighth as many keys as files. The
+// number of files is estimated from the size of the directory, assuming about 32 bytes per entry.
+
+const (
+	statCostRatio = 8
+	dirEntrySize  = 32
+)
+
+// listFaster returns true if checking n keys is likely faster by listing the scalars directory
+func (c *Client) listFaster(n int) bool {
+	if n < 2 {
+		return false
+	}
+	info, err := os.Stat(c.ScalarDir())
+	if err != nil {
+		return false
+	}
+	return int64(n)*statCostRatio >= info.Size()/dirEntrySize
+}
+
+// existsStat counts the keys that are scalars and haven't expired with a stat each
+func (c *Client) existsStat(keys []string) int64 {
+	n := int64(0)
+	for _, key := range keys {
+		if _, err := os.Stat(c.scalarPath(key)); err == nil && !c.hasExpired(key) {
+			n++
+		}
+	}
+	return n
+}
+
+// existsListed counts the keys that are scalars and haven't expired by reading the scalars directory once. The
+// expirations directory is read once too, so only keys with a deadline have it read.
+func (c *Client) existsListed(keys []string) (int64, error) {
+	entries, err := readDir(c.ScalarDir())
+	if err != nil {
+		return 0, err
+	}
+	files := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		files[entry.Name()] = true
+	}
+	sidecars := map[string]bool{}
+	if entries, err := readDir(c.ExpireDir()); err == nil {
+		for _, entry := range entries {
+			sidecars[entry.Name()] = true
+		}
+	}
+	n := int64(0)
+	for _, key := range keys {
+		if files[c.fileName(key)] && !(sidecars[c.diskName(key)] && c.hasExpired(key)) {
+			n++
+		}
+	}
+	return n, nil
+}
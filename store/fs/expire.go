@@ -0,0 +1,315 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// Expirations are kept in sidecar files under ExpireDir, one per key, holding the deadline in Unix milliseconds so
+// the value files are never touched. Hash field expirations are kept the same way under FieldExpireDir/<hash>/.
+
+func (c *Client) ExpireDir() string      { return c.GetDBDir() + "/expires/" }
+func (c *Client) FieldExpireDir() string { return c.GetDBDir() + "/hexpires/" }
+
+func readDeadline(f string) (t time.Time, ok bool) {
+	data, err := readRaw(f)
+	if err != nil {
+		return t, false
+	}
+	ms, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return t, false
+	}
+	return time.UnixMilli(ms), true
+}
+
+func writeDeadline(f string, t time.Time) error {
+	return writeRaw(f, []byte(strconv.FormatInt(t.UnixMilli(), 10)), 0664)
+}
+
+// deadline returns the time key expires, ok is false if it has no expiration
+func (c *Client) deadline(key string) (t time.Time, ok bool) {
+	return readDeadline(c.ExpireDir() + c.diskName(key))
+}
+
+func (c *Client) setDeadline(key string, t time.Time) error {
+	return writeDeadline(c.ExpireDir()+c.diskName(key), t)
+}
+
+func (c *Client) clearDeadline(key string) {
+	os.Remove(c.ExpireDir() + c.diskName(key))
+	os.RemoveAll(c.FieldExpireDir() + c.diskName(key))
+}
+
+// expiration returns the expiration a write with expiration gives its key, DefaultTTL in place of 0
+func (c *Client) expiration(expiration time.Duration) time.Duration {
+	if expiration == 0 && c.DefaultTTL > 0 {
+		return c.DefaultTTL
+	}
+	return expiration
+}
+
+// expireAfter sets key to expire after expiration if it is positive, leaves its deadline alone for jkv.KeepTTL and
+// clears it otherwise
+func (c *Client) expireAfter(key string, expiration time.Duration) error {
+	switch {
+	case expiration > 0:
+		return c.setDeadline(key, c.Clock.Now().Add(expiration))
+	case expiration == jkv.KeepTTL:
+		return nil
+	}
+	c.clearDeadline(key)
+	return nil
+}
+
+// fieldDeadline returns the time a hash field expires, ok is false if it has no expiration
+func (c *Client) fieldDeadline(hash, field string) (t time.Time, ok bool) {
+	return readDeadline(c.FieldExpireDir() + c.diskName(hash) + "/" + c.diskName(field))
+}
+
+func (c *Client) setFieldDeadline(hash, field string, t time.Time) error {
+	if err := os.MkdirAll(c.FieldExpireDir()+c.diskName(hash), 0775); err != nil {
+		return err
+	}
+	return writeDeadline(c.FieldExpireDir()+c.diskName(hash)+"/"+c.diskName(field), t)
+}
+
+func (c *Client) clearFieldDeadline(hash, field string) {
+	os.Remove(c.FieldExpireDir() + c.diskName(hash) + "/" + c.diskName(field))
+	os.Remove(c.FieldExpireDir() + c.diskName(hash))
+}
+
+// expireField removes a hash field and its sidecar if its deadline, or that of the whole hash, has passed, returning
+// true if it did. The lock must be held.
+func (c *Client) expireField(hash, field string) bool {
+	if c.expire(hash) {
+		return true
+	}
+	t, ok := c.fieldDeadline(hash, field)
+	if !ok || c.Clock.Now().Before(t) {
+		return false
+	}
+	os.Remove(c.fieldPath(hash, field))
+	c.clearFieldDeadline(hash, field)
+	if !c.keepEmptyHashes() {
+		os.Remove(c.hashPath(hash)) // only succeeds once the hash is empty
+	}
+	return true
+}
+
+// expire removes key and its sidecar if its deadline has passed, returning true if it did. The lock must be held.
+func (c *Client) expire(key string) bool {
+	if !c.hasExpired(key) {
+		return false
+	}
+	os.Remove(c.scalarPath(key))
+	os.RemoveAll(c.hashPath(key))
+	c.clearDeadline(key)
+	c.clearMeta(key)
+	return true
+}
+
+// hasExpired returns true if the deadline of key has passed, without removing anything
+func (c *Client) hasExpired(key string) bool {
+	t, ok := c.deadline(key)
+	return ok && !c.Clock.Now().Before(t)
+}
+
+// fieldHasExpired returns true if the deadline of a hash field, or that of the whole hash, has passed, without
+// removing anything
+func (c *Client) fieldHasExpired(hash, field string) bool {
+	if c.hasExpired(hash) {
+		return true
+	}
+	t, ok := c.fieldDeadline(hash, field)
+	return ok && !c.Clock.Now().Before(t)
+}
+
+// lazyExpire is expire for readers, which don't hold the lock. It returns true if the deadline of key has passed, so
+// the caller treats key as missing, and reaps it under the lock unless the client is read only. The deadline is read
+// again once the lock is held, as a writer may have set key again in the meantime.
+func (c *Client) lazyExpire(key string) bool {
+	if !c.hasExpired(key) {
+		return false
+	}
+	if !c.readOnly() {
+		c.lock()
+		c.expire(key)
+		c.unlock()
+	}
+	return true
+}
+
+// lazyExpireField is lazyExpire for a hash field
+func (c *Client) lazyExpireField(hash, field string) bool {
+	if !c.fieldHasExpired(hash, field) {
+		return false
+	}
+	if !c.readOnly() {
+		c.lock()
+		c.expireField(hash, field)
+		c.unlock()
+	}
+	return true
+}
+
+// expired returns the keys whose deadline has passed so listings can leave them out, without removing them, so it
+// may be called with or without the lock. It returns nothing when IncludeExpired is set.
+func (c *Client) expired() map[string]bool {
+	if c.includeExpired() {
+		return nil
+	}
+	entries, err := readDir(c.ExpireDir())
+	if err != nil {
+		return nil
+	}
+	expired := map[string]bool{}
+	for _, entry := range entries {
+		if key, ok := c.nameOf(entry.Name()); ok && c.hasExpired(key) {
+			expired[key] = true
+		}
+	}
+	return expired
+}
+
+// reapKeys reaps keys a listing found expired under the lock, unless the client is read only
+func (c *Client) reapKeys(keys map[string]bool) {
+	if c.readOnly() || len(keys) == 0 {
+		return
+	}
+	c.lock()
+	defer c.unlock()
+	for key := range keys {
+		c.expire(key)
+	}
+}
+
+// applyExpiry sets or clears the deadline of key according to opts
+func (c *Client) applyExpiry(key string, opts jkv.ExpiryOptions) error {
+	now := c.Clock.Now()
+	switch {
+	case opts.Persist:
+		c.clearDeadline(key)
+		return nil
+	case opts.EX > 0:
+		return c.setDeadline(key, now.Add(opts.EX))
+	case opts.PX > 0:
+		return c.setDeadline(key, now.Add(opts.PX))
+	case !opts.EXAT.IsZero():
+		return c.setDeadline(key, opts.EXAT)
+	case !opts.PXAT.IsZero():
+		return c.setDeadline(key, opts.PXAT)
+	}
+	return nil
+}
+
+// EXPIRE sets key to expire after expiration, returning false if it doesn't exist. Like Redis, a key given an
+// expiration that isn't positive is deleted.
+func (c *Client) Expire(ctx context.Context, key string, expiration time.Duration) (res *jkv.BoolCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewBoolCmd(false, jkv.ErrReadOnly)
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "EXPIRE", key)
+		c.expire(key)
+		if c.keyType(key) == "none" {
+			return jkv.NewBoolCmd(false, nil)
+		}
+		if expiration <= 0 {
+			os.Remove(c.scalarPath(key))
+			os.RemoveAll(c.hashPath(key))
+			c.clearDeadline(key)
+			c.clearMeta(key)
+			return jkv.NewBoolCmd(true, nil)
+		}
+		return jkv.NewBoolCmd(true, c.setDeadline(key, c.Clock.Now().Add(expiration)))
+	}
+	return jkv.NewBoolCmd(false, c.notOpen())
+}
+
+// HEXPIRE sets the time to live of hash fields in seconds, returning for each field -2 if it doesn't exist, 2 if it
+// was deleted because seconds is 0, otherwise 1
+func (c *Client) HExpire(ctx context.Context, hash string, seconds int64, fields ...string) (res *jkv.IntSliceCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewIntSliceCmd([]int64{}, jkv.ErrReadOnly)
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "HEXPIRE", hash)
+
+		results := make([]int64, len(fields))
+		for i, field := range fields {
+			c.expireField(hash, field)
+			if _, err := os.Stat(c.fieldPath(hash, field)); err != nil {
+				results[i] = -2
+			} else if seconds <= 0 {
+				if _, err := c.hdel(hash, []string{field}); err != nil {
+					return jkv.NewIntSliceCmd(results, err)
+				}
+				c.clearFieldDeadline(hash, field)
+				results[i] = 2
+			} else if err := c.setFieldDeadline(hash, field, c.Clock.Now().Add(time.Duration(seconds)*time.Second)); err != nil {
+				return jkv.NewIntSliceCmd(results, err)
+			} else {
+				results[i] = 1
+			}
+		}
+		return jkv.NewIntSliceCmd(results, nil)
+	}
+	return jkv.NewIntSliceCmd([]int64{}, c.notOpen())
+}
+
+// TTL returns the remaining time to live of key in seconds, -2 if it doesn't exist and -1 if it has no expiration
+func (c *Client) TTL(ctx context.Context, key string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.auditRead(ctx, "TTL", key)
+		c.settle()
+		if c.lazyExpire(key) {
+			return jkv.NewIntCmd(-2, nil)
+		}
+		if c.existsStat([]string{key}) == 0 {
+			if info, err := os.Stat(c.hashPath(key)); err != nil || !info.IsDir() {
+				return jkv.NewIntCmd(-2, nil)
+			}
+		}
+		if t, ok := c.deadline(key); ok {
+			return jkv.NewIntCmd(int64((t.Sub(c.Clock.Now())+time.Second-1)/time.Second), nil)
+		}
+		return jkv.NewIntCmd(-1, nil)
+	}
+	return jkv.NewIntCmd(0, c.notOpen())
+}
+
+// HTTL returns the remaining time to live of hash fields in seconds, -2 if a field doesn't exist and -1 if it has no
+// expiration
+func (c *Client) HTTL(ctx context.Context, hash string, fields ...string) (res *jkv.IntSliceCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.auditRead(ctx, "HTTL", hash)
+		results := make([]int64, len(fields))
+		for i, field := range fields {
+			if c.lazyExpireField(hash, field) {
+				results[i] = -2
+			} else if _, err := os.Stat(c.fieldPath(hash, field)); err != nil {
+				results[i] = -2
+			} else if t, ok := c.fieldDeadline(hash, field); ok {
+				results[i] = int64((t.Sub(c.Clock.Now()) + time.Second - 1) / time.Second)
+			} else {
+				results[i] = -1
+			}
+		}
+		return jkv.NewIntSliceCmd(results, nil)
+	}
+	return jkv.NewIntSliceCmd([]int64{}, c.notOpen())
+}
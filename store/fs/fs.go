@@ -4,7 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/panduit-joeb/jkv"
@@ -12,239 +18,731 @@ import (
 
 type Options struct {
 	Addr, Password string
-	DB             int
+	DB             int // numbered DB, kept in a db<n> directory under Addr unless 0
+	ReadOnly       bool
+	Logger         *log.Logger
+	MaxKeyLen      int   // longest key or field name accepted, DEFAULT_MAX_KEY_LEN if 0
+	SortKeys       *bool // sort KEYS and HKEYS results, on unless set to false
+	FileNaming     FileNaming
+	IncludeExpired bool      // list keys that have expired but not been removed yet in KEYS and SCAN, for diagnostics
+	AuditLog       io.Writer // if set, every mutating command is appended to it as an AuditRecord
+	AuditReads     bool      // record reads in AuditLog too
+	RecordDuration bool      // set the Duration of each command result
+	BulkBatch      int       // values buffered between BeginBulk and EndBulk, DEFAULT_BULK_BATCH if 0
+	Durable        bool      // fsync values before a write returns
+	Clock          Clock     // tells the time for expiry, SystemClock if nil
+	ActiveExpire   bool      // remove expired keys in the background while open, see SetActiveExpire
+	// ActiveExpireInterval is the time between sweeps of the reaper, DEFAULT_ACTIVE_EXPIRE_INTERVAL if 0
+	ActiveExpireInterval time.Duration
+	// KeepEmptyHashes leaves the directory of a hash in place when its last field is deleted, which saves removing
+	// and creating it again when a hash keeps emptying and filling up. An empty hash is still left out of KEYS.
+	KeepEmptyHashes bool
+	// CompressMinBytes stores values of at least this many bytes gzip compressed, values are never compressed if 0.
+	// Reads handle compressed and plain values alike whatever it is set to.
+	CompressMinBytes int
+	// DefaultTTL is the expiration of keys written by Set, SetNX and HSet with an expiration of 0, so the store
+	// behaves as a TTL cache. jkv.KeepTTL and jkv.NoExpiration override it. Keys never expire by default if 0.
+	DefaultTTL time.Duration
+	// HandleCacheSize keeps up to this many value files open, the least recently read are closed first, so reading
+	// the same keys over and over doesn't open and close their files each time. Files are never kept open if 0.
+	HandleCacheSize int
 }
 
 type Client struct {
-	DBDir  string
-	IsOpen bool
+	DBDir                string
+	Root                 string // Addr the client was created with, DBDir of DB 0
+	DB                   int
+	ReadOnly             bool
+	Logger               *log.Logger
+	MaxKeyLen            int
+	SortKeys             bool
+	FileNaming           FileNaming
+	IncludeExpired       bool
+	AuditLog             io.Writer
+	AuditReads           bool
+	RecordDuration       bool
+	BulkBatch            int
+	Durable              bool
+	KeepEmptyHashes      bool
+	Clock                Clock
+	ActiveExpire         bool
+	ActiveExpireInterval time.Duration
+	CompressMinBytes     int
+	DefaultTTL           time.Duration
+
+	mu      sync.Mutex   // serializes writers
+	dirMu   sync.RWMutex // held to change DBDir and Root, see GetDBDir
+	optMu   sync.RWMutex // held to change the settings SetOption can change, see readOnly
+	stats   stats
+	auditMu sync.Mutex // serializes audit log writes, which readers make too
+
+	openMu sync.Mutex // serializes Open and Close
+	opened time.Time  // when Open was called, for the uptime in INFO
+	state  int32      // stateNotOpened, stateOpen or stateClosed, changed under openMu and read with atomic loads by commands
+
+	reaperMu   sync.Mutex    // serializes starting and stopping the reaper
+	reaperStop chan struct{} // closed to stop the reaper, nil while it isn't running
+	reaperDone chan struct{} // closed by the reaper when it stops
+
+	bulk    int32             // set between BeginBulk and EndBulk, read without the lock
+	pending map[string]string // buffered bulk SETs
+	order   []string          // keys of pending in the order they were set
+	bulkErr error             // first error writing pending
+
+	handles *handleCache // open value files, nil unless Options.HandleCacheSize is set
+
+	hashMu sync.RWMutex // held to read a hash whole, and by HLoadFile to swap a hash in
 }
 
 var _ jkv.Client = (*Client)(nil)
 
 var DEFAULT_DB = GetDBDir()
 
+// DEFAULT_MAX_KEY_LEN keeps names safely below the 255 byte filename limit of most filesystems
+const DEFAULT_MAX_KEY_LEN = 200
+
 func GetDBDir() (dir string) {
 	return os.TempDir() + "/jkv_db"
 }
 
-func (c *Client) ScalarDir() string { return c.DBDir + "/scalars/" }
-func (c *Client) HashDir() string   { return c.DBDir + "/hashes/" }
-func notOpen() error                { return errors.New("DB is not open") }
+func (c *Client) ScalarDir() string { return c.GetDBDir() + "/scalars/" }
+func (c *Client) HashDir() string   { return c.GetDBDir() + "/hashes/" }
+
+var (
+	// ErrNotOpen is returned by operations on a client that hasn't been opened
+	ErrNotOpen = errors.New("DB is not open")
+	// ErrClosed is returned by operations on a client after Close
+	ErrClosed = errors.New("DB is closed")
+)
+
+// The states of a client, see IsOpen
+const (
+	stateNotOpened int32 = iota
+	stateOpen
+	stateClosed
+)
+
+// IsOpen returns true between Open and Close. It is safe to call while another goroutine opens or closes the client.
+func (c *Client) IsOpen() bool { return atomic.LoadInt32(&c.state) == stateOpen }
+
+// notOpen returns the error for an operation on a database that isn't open, telling one that was closed apart from
+// one that never was
+func (c *Client) notOpen() error {
+	if atomic.LoadInt32(&c.state) == stateClosed {
+		return ErrClosed
+	}
+	return ErrNotOpen
+}
+
+// notFound turns the error from reading a missing key or field into jkv.ErrKeyNotFound
+func notFound(err error) error {
+	if os.IsNotExist(err) {
+		return jkv.ErrKeyNotFound
+	}
+	return err
+}
 
+// GetDBDir returns the directory of the database, which Reopen can change while the client is in use
 func (c *Client) GetDBDir() string {
+	c.dirMu.RLock()
+	defer c.dirMu.RUnlock()
 	return c.DBDir
 }
 
-func NewClient(opts *Options) (db *Client) {
-	return &Client{DBDir: opts.Addr, IsOpen: false}
+// root returns Root, which Reopen can change like DBDir
+func (c *Client) root() string {
+	c.dirMu.RLock()
+	defer c.dirMu.RUnlock()
+	return c.Root
+}
+
+// Capabilities returns the features of the fs store beyond jkv.Client
+func (c *Client) Capabilities() jkv.Capabilities {
+	return jkv.NewCapabilities(jkv.CapPop, jkv.CapMeta, jkv.CapRenamePrefix, jkv.CapCompact, jkv.CapFsck, jkv.CapEncoding,
+		jkv.CapMultiDBScan, jkv.CapStream, jkv.CapActiveExpire, jkv.CapLockStats, jkv.CapConfigSet, jkv.CapChecksum,
+		jkv.CapHAppend, jkv.CapTypes, jkv.CapSwap)
 }
 
-// Open a database by creating the directories required if they don't exist and mark the database open
+// NewClient returns a closed client configured by opts, which may be nil, followed by any functional options
+func NewClient(opts *Options, options ...jkv.Option) (db *Client) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	s := jkv.Settings{Addr: opts.Addr, Password: opts.Password, DB: opts.DB, ReadOnly: opts.ReadOnly, Logger: opts.Logger}.Apply(options...)
+	if s.Logger == nil {
+		s.Logger = log.New(os.Stdout, "", 0)
+	}
+	maxKeyLen := opts.MaxKeyLen
+	if maxKeyLen <= 0 {
+		maxKeyLen = DEFAULT_MAX_KEY_LEN
+	}
+	sortKeys := opts.SortKeys == nil || *opts.SortKeys
+	clock := opts.Clock
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	activeExpireInterval := opts.ActiveExpireInterval
+	if activeExpireInterval <= 0 {
+		activeExpireInterval = DEFAULT_ACTIVE_EXPIRE_INTERVAL
+	}
+	bulkBatch := opts.BulkBatch
+	if bulkBatch <= 0 {
+		bulkBatch = DEFAULT_BULK_BATCH
+	}
+	db = &Client{DBDir: dbDir(s.Addr, s.DB), Root: s.Addr, DB: s.DB, ReadOnly: s.ReadOnly, Logger: s.Logger, MaxKeyLen: maxKeyLen, SortKeys: sortKeys, FileNaming: opts.FileNaming,
+		IncludeExpired: opts.IncludeExpired, AuditLog: opts.AuditLog, AuditReads: opts.AuditReads,
+		RecordDuration: opts.RecordDuration, BulkBatch: bulkBatch, Durable: opts.Durable,
+		KeepEmptyHashes: opts.KeepEmptyHashes, Clock: clock, ActiveExpire: opts.ActiveExpire,
+		ActiveExpireInterval: activeExpireInterval, CompressMinBytes: opts.CompressMinBytes,
+		DefaultTTL: opts.DefaultTTL}
+	if opts.HandleCacheSize > 0 {
+		db.handles = newHandleCache(opts.HandleCacheSize)
+	}
+	return db
+}
+
+// checkNames returns jkv.ErrNameTooLong if any of the key or field names are longer than MaxKeyLen on disk, where
+// NamingEncoded can make a name up to three times as long
+func (c *Client) checkNames(names ...string) error {
+	limit := c.maxKeyLen()
+	for _, name := range names {
+		if n := len(c.diskName(name)); n > limit {
+			return fmt.Errorf("%w: %d bytes on disk, the limit is %d", jkv.ErrNameTooLong, n, limit)
+		}
+	}
+	return nil
+}
+
+// Open a database by creating the directories required if they don't exist and mark the database open. It is safe
+// to call from several goroutines. Opening an open database only puts back directories that went missing, and an
+// Open that fails leaves the database closed.
 func (c *Client) Open() error {
-	c.IsOpen = false
-	for _, dir := range []string{c.ScalarDir(), c.HashDir()} {
-		if err := os.MkdirAll(dir, 0775); err != nil {
+	c.openMu.Lock()
+	defer c.openMu.Unlock()
+	if err := c.prepare(c.GetDBDir()); err != nil {
+		c.close()
+		return err
+	}
+	c.open()
+	return nil
+}
+
+// prepare creates the directories a database in dbDir needs
+func (c *Client) prepare(dbDir string) error {
+	if info, err := os.Stat(dbDir); err == nil && !info.IsDir() {
+		return fmt.Errorf("DBDir %s exists and is not a directory", dbDir)
+	}
+	for _, dir := range c.managedDirs() {
+		if err := os.MkdirAll(dbDir+strings.TrimPrefix(dir, c.GetDBDir()), 0775); err != nil {
 			return err
 		}
 	}
-	c.IsOpen = true
 	return nil
 }
 
-// Close a database, basically just mark it closed
-func (c *Client) Close() { c.IsOpen = false }
+// open marks the database open and starts the reaper if it isn't already, c.openMu must be held
+func (c *Client) open() {
+	if c.IsOpen() {
+		return
+	}
+	c.opened = c.Clock.Now()
+	atomic.StoreInt32(&c.state, stateOpen)
+	c.reaperMu.Lock()
+	if c.ActiveExpire {
+		c.startReaper()
+	}
+	c.reaperMu.Unlock()
+}
+
+// Reopen switches the client to the database in dbDir, e.g. after the directories have been swapped underneath it.
+// The new directory is prepared first, so if it is unusable the error is returned and the current database stays
+// as it was. Otherwise writers in progress finish and pending bulk writes go to the current database before the
+// switch, and the new database is left open.
+func (c *Client) Reopen(ctx context.Context, dbDir string) error {
+	c.openMu.Lock()
+	defer c.openMu.Unlock()
+	if err := c.prepare(dbDir); err != nil {
+		return err
+	}
+	c.lock()
+	c.audit(ctx, "REOPEN", dbDir)
+	c.dirMu.Lock()
+	if c.DB == 0 {
+		c.Root = dbDir
+	}
+	c.DBDir = dbDir
+	c.dirMu.Unlock()
+	c.unlock()
+	c.open()
+	return nil
+}
+
+// Close a database, basically just mark it closed and stop the reaper
+func (c *Client) Close() {
+	c.openMu.Lock()
+	defer c.openMu.Unlock()
+	c.close()
+}
+
+// close marks the database closed and stops the reaper, c.openMu must be held. Closing a database that isn't open
+// does nothing.
+func (c *Client) close() {
+	if !c.IsOpen() {
+		return
+	}
+	c.reaperMu.Lock()
+	c.stopReaper()
+	c.reaperMu.Unlock()
+	atomic.StoreInt32(&c.state, stateClosed)
+	if c.handles != nil {
+		c.handles.purge()
+	}
+}
 
-// FLUSHDB a database by removing the j.dbDir and everything underneath, ignore errors for now
-func (j *Client) FlushDB(ctx context.Context) *jkv.StatusCmd {
-	os.RemoveAll(j.DBDir)
+// managedDirs are the directories under DBDir that hold keys and their sidecar files
+func (c *Client) managedDirs() []string {
+	return []string{c.ScalarDir(), c.HashDir(), c.ExpireDir(), c.FieldExpireDir(), c.MetaDir()}
+}
+
+// FLUSHDB a database by emptying the directories jkv manages, anything else in j.dbDir is left alone
+func (j *Client) FlushDB(ctx context.Context) (res *jkv.StatusCmd) {
+	defer timed(j.start(), &res)
+	if !j.IsOpen() {
+		return jkv.NewStatusCmd("", j.notOpen())
+	}
+	if j.readOnly() {
+		return jkv.NewStatusCmd("", jkv.ErrReadOnly)
+	}
+	j.lock()
+	defer j.unlock()
+	j.audit(ctx, "FLUSHDB")
+	for _, dir := range j.managedDirs() {
+		if err := os.RemoveAll(dir); err != nil {
+			return jkv.NewStatusCmd("", err)
+		}
+		if err := os.MkdirAll(dir, 0775); err != nil {
+			return jkv.NewStatusCmd("", err)
+		}
+	}
 	return jkv.NewStatusCmd("OK", nil)
 }
 
 // Return data in scalar key data, error is file is missing or inaccessible
-func (c *Client) Get(ctx context.Context, key string) *jkv.StringCmd {
-	if c.IsOpen {
-		data, err := os.ReadFile(c.ScalarDir() + key)
-		return jkv.NewStringCmd(string(data), err)
+func (c *Client) Get(ctx context.Context, key string) (res *jkv.StringCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.auditRead(ctx, "GET", key)
+		c.settle()
+		if c.lazyExpire(key) {
+			return jkv.NewStringCmd("", jkv.ErrKeyNotFound)
+		}
+		data, err := c.readFile(c.scalarPath(key))
+		return jkv.NewStringCmd(string(data), notFound(err))
 	}
-	return jkv.NewStringCmd("", notOpen())
+	return jkv.NewStringCmd("", c.notOpen())
 }
 
 // Set a scalar key to a value
-func (c *Client) Set(ctx context.Context, key, value string, expiration time.Duration) *jkv.StatusCmd {
-	if c.IsOpen {
-		return jkv.NewStatusCmd("OK", os.WriteFile(c.ScalarDir()+key, []byte(value), 0660))
+func (c *Client) Set(ctx context.Context, key, value string, expiration time.Duration) (res *jkv.StatusCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewStatusCmd("", jkv.ErrReadOnly)
+		}
+		if err := c.checkNames(key); err != nil {
+			return jkv.NewStatusCmd("", err)
+		}
+		expiration = c.expiration(expiration)
+		if (expiration == 0 || expiration == jkv.NoExpiration) && c.inBulk() && c.bulkSet(ctx, key, value) {
+			return jkv.NewStatusCmd("OK", nil)
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "SET", key)
+		if err := c.writeValue(c.scalarPath(key), []byte(value), 0660); err != nil {
+			return jkv.NewStatusCmd("OK", err)
+		}
+		c.clearMeta(key)
+		return jkv.NewStatusCmd("OK", c.expireAfter(key, expiration))
 	}
-	return jkv.NewStatusCmd("(nil)", notOpen())
+	return jkv.NewStatusCmd("(nil)", c.notOpen())
 }
 
-// Delete a key by removing the scalar file
-func (c *Client) Del(ctx context.Context, keys ...string) *jkv.IntCmd {
-	if c.IsOpen {
-		n := 0
+// GETEX returns the value of key and sets or clears its expiration, with no options it is the same as GET. The value
+// is read and the expiration applied under the same lock.
+func (c *Client) GetEX(ctx context.Context, key string, opts jkv.ExpiryOptions) (res *jkv.StringCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if opts == (jkv.ExpiryOptions{}) {
+			return c.Get(ctx, key)
+		}
+		if c.readOnly() {
+			return jkv.NewStringCmd("", jkv.ErrReadOnly)
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "GETEX", key)
+		c.expire(key)
+		data, err := c.readFile(c.scalarPath(key))
+		if err != nil {
+			return jkv.NewStringCmd("", notFound(err))
+		}
+		return jkv.NewStringCmd(string(data), c.applyExpiry(key, opts))
+	}
+	return jkv.NewStringCmd("", c.notOpen())
+}
+
+// DEL removes the scalar keys, returning how many of them existed. A missing key is skipped, only an error removing
+// one that exists is returned.
+func (c *Client) Del(ctx context.Context, keys ...string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	return c.DelBatch(ctx, keys, nil)
+}
+
+// DelBatch removes the scalar keys and the fields of each hash in fields under a single lock, returning the total
+// number of keys and fields removed
+func (c *Client) DelBatch(ctx context.Context, keys []string, fields map[string][]string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewIntCmd(0, jkv.ErrReadOnly)
+		}
+		c.lock()
+		defer c.unlock()
+		if len(keys) > 0 {
+			c.audit(ctx, "DEL", keys...)
+		}
+		for hash := range fields {
+			c.audit(ctx, "HDEL", hash)
+		}
+
+		n := int64(0)
 		for _, key := range keys {
-			if os.Remove(c.ScalarDir()+key) == nil {
+			c.expire(key)
+			if err := os.Remove(c.scalarPath(key)); err == nil {
+				c.clearDeadline(key)
+				c.clearMeta(key)
 				n++
+			} else if !os.IsNotExist(err) {
+				return jkv.NewIntCmd(n, err)
 			}
 		}
-		return jkv.NewIntCmd(int64(n), nil)
+		for hash, f := range fields {
+			m, err := c.hdel(hash, f)
+			n += m
+			if err != nil {
+				return jkv.NewIntCmd(n, err)
+			}
+		}
+		return jkv.NewIntCmd(n, nil)
 	}
-	return jkv.NewIntCmd(0, notOpen())
+	return jkv.NewIntCmd(0, c.notOpen())
 }
 
-// KEYS returns the scalar and hash keys
-func (c *Client) Keys(ctx context.Context, pattern string) *jkv.StringSliceCmd {
-	var files []string
+// KEYS returns the scalar and hash keys matching the glob pattern
+func (c *Client) Keys(ctx context.Context, pattern string) (res *jkv.StringSliceCmd) {
+	defer timed(c.start(), &res)
+	c.auditRead(ctx, "KEYS")
+	if _, err := globMatch(pattern, ""); err != nil {
+		return jkv.NewStringSliceCmd([]string{}, err)
+	}
+	c.settle()
+	files := []string{}
+	expired := c.expired()
 	for _, dir := range []string{c.HashDir(), c.ScalarDir()} {
-		entries, err := os.ReadDir(dir)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return jkv.NewStringSliceCmd([]string{}, nil)
-			}
+		entries, err := readDir(dir)
+		if err != nil && !os.IsNotExist(err) {
 			return jkv.NewStringSliceCmd([]string{}, err)
 		}
+		// a missing directory just has no keys of its kind
 		for _, file := range entries {
-			files = append(files, file.Name())
+			key, ok := file.Name(), true
+			if dir == c.ScalarDir() {
+				key, ok = c.keyName(key)
+			} else if key, ok = c.nameOf(key); ok && c.keepEmptyHashes() {
+				ok = !c.emptyHash(key)
+			}
+			ok = ok && !expired[key]
+			if ok {
+				ok, _ = globMatch(pattern, key)
+			}
+			if ok {
+				files = append(files, key)
+			}
 		}
 	}
+	c.reapKeys(expired)
+	if c.sortKeys() {
+		sort.Strings(files)
+	}
 	return jkv.NewStringSliceCmd(files, nil)
 }
 
 // Return true if scalar key file exists, false otherwise
-func (c *Client) Exists(ctx context.Context, keys ...string) *jkv.IntCmd {
-	if c.IsOpen {
-		n := int64(0)
-		for _, key := range keys {
-			if _, err := os.Stat(c.ScalarDir() + key); err == nil {
-				n++
+func (c *Client) Exists(ctx context.Context, keys ...string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.auditRead(ctx, "EXISTS", keys...)
+		c.settle()
+		if c.listFaster(len(keys)) {
+			if n, err := c.existsListed(keys); err == nil {
+				return jkv.NewIntCmd(n, nil)
 			}
 		}
-		return jkv.NewIntCmd(n, nil)
+		return jkv.NewIntCmd(c.existsStat(keys), nil)
 	}
-	return jkv.NewIntCmd(0, notOpen())
+	return jkv.NewIntCmd(0, c.notOpen())
 }
 
 // Return data in hashed key data, error is file is missing or inaccessible
-func (c *Client) HGet(ctx context.Context, hash, key string) *jkv.StringCmd {
-	if c.IsOpen {
-		data, err := os.ReadFile(c.HashDir() + hash + "/" + key)
+func (c *Client) HGet(ctx context.Context, hash, key string) (res *jkv.StringCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.auditRead(ctx, "HGET", hash)
+		if c.lazyExpireField(hash, key) {
+			return jkv.NewStringCmd("", jkv.ErrKeyNotFound)
+		}
+		data, err := c.readFile(c.fieldPath(hash, key))
 		if err != nil {
-			return jkv.NewStringCmd("", err)
+			return jkv.NewStringCmd("", notFound(err))
 		}
 		return jkv.NewStringCmd(string(data), nil)
 	}
-	return jkv.NewStringCmd("", notOpen())
+	return jkv.NewStringCmd("", c.notOpen())
 }
 
 // Create a hash directory and store the data in a key file
 // todo: reject a hash if a scalar key exists
-func (c *Client) HSet(ctx context.Context, hash string, values ...string) *jkv.IntCmd {
-	if c.IsOpen {
-		rec := c.Exists(ctx, hash)
-		if rec.Err() != nil {
-			return jkv.NewIntCmd(0, rec.Err())
+func (c *Client) HSet(ctx context.Context, hash string, values ...string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewIntCmd(0, jkv.ErrReadOnly)
 		}
-
-		if rec.Val() > 0 {
+		if err := c.checkNames(hash); err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		for i := 0; i < len(values); i += 2 {
+			if err := c.checkNames(values[i]); err != nil {
+				return jkv.NewIntCmd(0, err)
+			}
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "HSET", hash)
+		if c.existsStat([]string{hash}) > 0 {
 			return jkv.NewIntCmd(0, fmt.Errorf("key \"%s\" exists as a scalar, cannot be a hash", hash))
 		}
 
-		if err := os.MkdirAll(c.HashDir()+hash, 0775); err != nil {
-			return jkv.NewIntCmd(0, rec.Err())
+		c.expire(hash)
+		if info, err := os.Stat(c.hashPath(hash)); err == nil && !info.IsDir() {
+			return jkv.NewIntCmd(0, fmt.Errorf("%w, %s is a file not a hash directory, run FSCK REPAIR", jkv.ErrWrongType, c.hashPath(hash)))
+		}
+		if err := os.MkdirAll(c.hashPath(hash), 0775); err != nil {
+			return jkv.NewIntCmd(0, err)
 		}
 
 		n := 0
 		for i := 0; i < len(values); i++ {
 			key := values[i]
-			f := c.HashDir() + hash + "/" + key
+			f := c.fieldPath(hash, key)
+			c.expireField(hash, key)
 			info, err := os.Stat(f)
 			if info == nil && os.IsNotExist(err) {
 				n++
 			}
-			if err := os.WriteFile(f, []byte(values[i+1]), 0664); err != nil {
-				fmt.Println("write file failed")
-				return jkv.NewIntCmd(0, rec.Err())
+			if err := c.writeValue(f, []byte(values[i+1]), 0664); err != nil {
+				c.Logger.Println("write file failed")
+				return jkv.NewIntCmd(0, err)
 			}
+			c.clearFieldDeadline(hash, key)
 			i++
 		}
+		if c.DefaultTTL > 0 {
+			return jkv.NewIntCmd(int64(n), c.setDeadline(hash, c.Clock.Now().Add(c.DefaultTTL)))
+		}
 		return jkv.NewIntCmd(int64(n), nil)
 	}
-	return jkv.NewIntCmd(0, notOpen())
+	return jkv.NewIntCmd(0, c.notOpen())
 }
 
 // Delete a hashed key by removing the file, if no keys exist after the operation remove the hash directory
-func (c *Client) HDel(ctx context.Context, hash string, keys ...string) *jkv.IntCmd {
-	if c.IsOpen {
-		rec := c.Exists(ctx, hash)
-		if rec.Err() != nil {
-			return jkv.NewIntCmd(0, rec.Err())
+func (c *Client) HDel(ctx context.Context, hash string, keys ...string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewIntCmd(0, jkv.ErrReadOnly)
 		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "HDEL", hash)
+		n, err := c.hdel(hash, keys)
+		return jkv.NewIntCmd(n, err)
+	}
+	return jkv.NewIntCmd(0, c.notOpen())
+}
 
-		if rec.Val() > 0 {
-			return jkv.NewIntCmd(0, fmt.Errorf("key \"%s\" exists as a scalar, cannot be a hash", hash))
-		}
+// hdel removes fields from hash with the lock held
+func (c *Client) hdel(hash string, keys []string) (int64, error) {
+	if _, err := os.Stat(c.scalarPath(hash)); err == nil {
+		return 0, fmt.Errorf("key \"%s\" exists as a scalar, cannot be a hash", hash)
+	}
 
-		n := int64(0)
-		for _, key := range keys {
-			f := c.HashDir() + hash + "/" + key
-			info, err := os.Stat(f)
-			if info == nil && os.IsNotExist(err) {
-				continue
-			}
-			if err := os.Remove(f); err == nil {
-				n++
-			} else {
-				return jkv.NewIntCmd(0, err)
-			}
+	n := int64(0)
+	for _, key := range keys {
+		f := c.fieldPath(hash, key)
+		c.expireField(hash, key)
+		info, err := os.Stat(f)
+		if info == nil && os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Remove(f); err == nil {
+			c.clearFieldDeadline(hash, key)
+			n++
+		} else {
+			return n, err
 		}
-		// remove the hash if no more keys exist
-		if files, err := os.ReadDir(c.HashDir() + hash); err == nil {
-			if len(files) == 0 {
-				if err = os.Remove(c.HashDir() + hash); err != nil {
-					fmt.Println("removing", c.HashDir()+hash, "failed, err", err.Error())
-				}
+	}
+	// remove the hash if no more keys exist
+	if c.keepEmptyHashes() {
+		return n, nil
+	}
+	if files, err := readDir(c.hashPath(hash)); err == nil {
+		if len(files) == 0 {
+			if err = os.Remove(c.hashPath(hash)); err != nil {
+				c.Logger.Println("removing", c.hashPath(hash), "failed, err", err.Error())
 			}
 		}
-		return jkv.NewIntCmd(n, nil)
 	}
-	return jkv.NewIntCmd(0, notOpen())
+	return n, nil
+}
+
+// fields returns the fields of hash that haven't expired and whose names match the glob pattern, all of them if
+// pattern is empty
+func (c *Client) fields(hash, pattern string) ([]string, error) {
+	entries, err := readDir(c.hashPath(hash))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return []string{}, err
+	}
+	files := []string{}
+	for _, file := range entries {
+		field, ok := c.nameOf(file.Name())
+		if ok && pattern != "" {
+			ok, _ = globMatch(pattern, field)
+		}
+		if ok && !c.fieldHasExpired(hash, field) {
+			files = append(files, field)
+		}
+	}
+	if c.sortKeys() {
+		sort.Strings(files)
+	}
+	return files, nil
 }
 
 // HKEYS returns the hash keys
-func (c *Client) HKeys(ctx context.Context, hash string) *jkv.StringSliceCmd {
-	var err error
-	if c.IsOpen {
-		if _, err = os.Stat(c.HashDir() + hash); err == nil {
-			entries, err := os.ReadDir(c.HashDir() + hash)
-			if err != nil {
-				return jkv.NewStringSliceCmd([]string{}, err)
-			}
-			var files []string
-			for _, file := range entries {
-				files = append(files, file.Name())
-			}
-			return jkv.NewStringSliceCmd(files, nil)
+func (c *Client) HKeys(ctx context.Context, hash string) (res *jkv.StringSliceCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.auditRead(ctx, "HKEYS", hash)
+		return jkv.NewStringSliceCmd(c.fields(hash, ""))
+	}
+	return jkv.NewStringSliceCmd([]string{}, c.notOpen())
+}
+
+// HKeysMatch returns the fields of hash whose names match the glob pattern
+func (c *Client) HKeysMatch(ctx context.Context, hash, pattern string) (res *jkv.StringSliceCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.auditRead(ctx, "HKEYS", hash)
+		if _, err := globMatch(pattern, ""); err != nil {
+			return jkv.NewStringSliceCmd([]string{}, err)
 		}
-		return jkv.NewStringSliceCmd([]string{}, err)
+		return jkv.NewStringSliceCmd(c.fields(hash, pattern))
+	}
+	return jkv.NewStringSliceCmd([]string{}, c.notOpen())
+}
+
+// HGETALL returns the fields of hash and their values, a missing hash has none. A map has no order, HKeys gives the
+// fields sorted when SortKeys is set and the CLI prints them sorted.
+func (c *Client) HGetAll(ctx context.Context, hash string) (res *jkv.StringStringMapCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.auditRead(ctx, "HGETALL", hash)
+		return jkv.NewStringStringMapCmd(c.hgetall(hash, ""))
+	}
+	return jkv.NewStringStringMapCmd(map[string]string{}, c.notOpen())
+}
+
+// HGetAllMatch returns the fields of hash whose names match the glob pattern and their values. Fields are filtered
+// by name before any value is read, so the values of the others are never touched.
+func (c *Client) HGetAllMatch(ctx context.Context, hash, pattern string) (res *jkv.StringStringMapCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.auditRead(ctx, "HGETALL", hash)
+		if _, err := globMatch(pattern, ""); err != nil {
+			return jkv.NewStringStringMapCmd(map[string]string{}, err)
+		}
+		return jkv.NewStringStringMapCmd(c.hgetall(hash, pattern))
 	}
-	return jkv.NewStringSliceCmd([]string{}, notOpen())
+	return jkv.NewStringStringMapCmd(map[string]string{}, c.notOpen())
+}
+
+// hgetall returns the fields of hash matching pattern, all of them if it is empty, and their values
+func (c *Client) hgetall(hash, pattern string) (map[string]string, error) {
+	c.hashMu.RLock()
+	defer c.hashMu.RUnlock()
+	fields, err := c.fields(hash, pattern)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	values := make(map[string]string, len(fields))
+	for _, field := range fields {
+		data, err := c.readFile(c.fieldPath(hash, field))
+		if os.IsNotExist(err) {
+			continue // removed since the directory was listed
+		} else if err != nil {
+			return map[string]string{}, err
+		}
+		values[field] = string(data)
+	}
+	return values, nil
 }
 
 // Return true if hashed key file exists, false otherwise
-func (c *Client) HExists(ctx context.Context, hash, key string) *jkv.BoolCmd {
-	if c.IsOpen {
+func (c *Client) HExists(ctx context.Context, hash, key string) (res *jkv.BoolCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.auditRead(ctx, "HEXISTS", hash)
 		var err error
-		if _, err = os.Stat(c.HashDir() + hash + "/" + key); err != nil {
+		if c.lazyExpireField(hash, key) {
+			return jkv.NewBoolCmd(false, &os.PathError{Op: "stat", Path: c.fieldPath(hash, key), Err: os.ErrNotExist})
+		}
+		if _, err = os.Stat(c.fieldPath(hash, key)); err != nil {
 			return jkv.NewBoolCmd(false, err)
 		}
 		return jkv.NewBoolCmd(true, nil)
 	}
-	return jkv.NewBoolCmd(false, notOpen())
+	return jkv.NewBoolCmd(false, c.notOpen())
 }
 
-func (c *Client) Ping(ctx context.Context) *jkv.StatusCmd {
-	if c.IsOpen {
+func (c *Client) Ping(ctx context.Context) (res *jkv.StatusCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
 		return jkv.NewStatusCmd("PONG", nil)
 	}
-	return jkv.NewStatusCmd("", notOpen())
+	return jkv.NewStatusCmd("", c.notOpen())
+}
+
+// emptyHash returns true if the directory of hash has no fields, which only happens with KeepEmptyHashes
+func (c *Client) emptyHash(hash string) bool {
+	f, err := os.Open(c.hashPath(hash))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	names, _ := f.Readdirnames(1)
+	return len(names) == 0
 }
@@ -1,10 +1,26 @@
 package fs
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/panduit-joeb/jkv"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -234,3 +250,2467 @@ func TestHash(t *testing.T) {
 		a.Nil(c.HDel(ctx, hash, key).Err())
 	})
 }
+
+func TestOptions(t *testing.T) {
+	t.Run("Functional options", func(t *testing.T) {
+		ctx := context.Background()
+		a := assert.New(t)
+
+		dir := t.TempDir()
+		var buf bytes.Buffer
+		logger := log.New(&buf, "", 0)
+		c := NewClient(nil, jkv.WithAddr(dir), jkv.WithReadOnly(true), jkv.WithLogger(logger))
+		defer c.Close()
+
+		a.Equal(dir, c.GetDBDir())
+		a.True(c.ReadOnly)
+		a.Equal(logger, c.Logger)
+
+		a.Nil(c.Open())
+		a.ErrorIs(c.Set(ctx, "key", "value", 0).Err(), jkv.ErrReadOnly)
+		a.ErrorIs(c.HSet(ctx, "hash", "key", "value").Err(), jkv.ErrReadOnly)
+		a.Equal(int64(0), c.Exists(ctx, "key").Val())
+	})
+
+	t.Run("Functional options override Options", func(t *testing.T) {
+		dir := t.TempDir()
+		c := NewClient(&Options{Addr: DEFAULT_DB, ReadOnly: true}, jkv.WithAddr(dir), jkv.WithReadOnly(false))
+		assert.Equal(t, dir, c.GetDBDir())
+		assert.False(t, c.ReadOnly)
+	})
+}
+
+func TestGetEX(t *testing.T) {
+	ctx := context.Background()
+
+	newClient := func(t *testing.T) *Client {
+		c := NewClient(&Options{Addr: t.TempDir()})
+		assert.Nil(t, c.Open())
+		t.Cleanup(c.Close)
+		return c
+	}
+
+	t.Run("GETEX EX sets the TTL", func(t *testing.T) {
+		a := assert.New(t)
+		c := newClient(t)
+		c.Set(ctx, "key", "value", 0)
+
+		rec := c.GetEX(ctx, "key", jkv.ExpiryOptions{EX: time.Minute})
+		a.Nil(rec.Err())
+		a.Equal("value", rec.Val())
+		deadline, ok := c.deadline("key")
+		a.True(ok)
+		a.WithinDuration(time.Now().Add(time.Minute), deadline, time.Second)
+	})
+
+	t.Run("GETEX PERSIST clears the TTL", func(t *testing.T) {
+		a := assert.New(t)
+		c := newClient(t)
+		c.Set(ctx, "key", "value", time.Minute)
+
+		rec := c.GetEX(ctx, "key", jkv.ExpiryOptions{Persist: true})
+		a.Nil(rec.Err())
+		a.Equal("value", rec.Val())
+		_, ok := c.deadline("key")
+		a.False(ok)
+	})
+
+	t.Run("GETEX without options leaves the TTL alone", func(t *testing.T) {
+		a := assert.New(t)
+		c := newClient(t)
+		c.Set(ctx, "key", "value", time.Minute)
+		before, _ := c.deadline("key")
+
+		rec := c.GetEX(ctx, "key", jkv.ExpiryOptions{})
+		a.Nil(rec.Err())
+		a.Equal("value", rec.Val())
+		after, ok := c.deadline("key")
+		a.True(ok)
+		a.Equal(before, after)
+	})
+
+	t.Run("GETEX of an expired key", func(t *testing.T) {
+		c := newClient(t)
+		c.Set(ctx, "key", "value", time.Millisecond)
+		time.Sleep(5 * time.Millisecond)
+		assert.NotNil(t, c.GetEX(ctx, "key", jkv.ExpiryOptions{}).Err())
+		assert.Equal(t, jkv.ErrKeyNotFound, c.GetEX(ctx, "key", jkv.ExpiryOptions{EX: time.Minute}).Err())
+		_, ok := c.deadline("key")
+		assert.False(t, ok)
+	})
+}
+
+func TestLazyExpire(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	dir := t.TempDir()
+	c := NewClient(&Options{Addr: dir, Clock: clock})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	// a read only client treats an expired key as missing but leaves it on disk
+	ro := NewClient(&Options{Addr: dir, ReadOnly: true, Clock: clock})
+	a.Nil(ro.Open())
+	defer ro.Close()
+	c.Set(ctx, "key", "value", time.Millisecond)
+	c.HSet(ctx, "hash", "field", "value")
+	c.HExpire(ctx, "hash", 1, "field")
+	clock.Advance(time.Second)
+	a.Equal(jkv.ErrKeyNotFound, ro.Get(ctx, "key").Err())
+	a.Equal(int64(-2), ro.TTL(ctx, "key").Val())
+	a.Equal(jkv.ErrKeyNotFound, ro.HGet(ctx, "hash", "field").Err())
+	a.False(ro.HExists(ctx, "hash", "field").Val())
+	a.Equal([]string{}, ro.Keys(ctx, "key").Val())
+	_, err := os.Stat(c.scalarPath("key"))
+	a.Nil(err)
+	_, err = os.Stat(c.fieldPath("hash", "field"))
+	a.Nil(err)
+
+	// a writable client reaps it
+	a.Equal(jkv.ErrKeyNotFound, c.Get(ctx, "key").Err())
+	a.Equal(jkv.ErrKeyNotFound, c.HGet(ctx, "hash", "field").Err())
+	_, err = os.Stat(c.scalarPath("key"))
+	a.True(os.IsNotExist(err))
+	_, err = os.Stat(c.fieldPath("hash", "field"))
+	a.True(os.IsNotExist(err))
+
+	// a reader that finds the key expired never removes a value set again since
+	for i := 0; i < 100; i++ {
+		c.Set(ctx, "key", "old", time.Millisecond)
+		clock.Advance(time.Second)
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			c.Get(ctx, "key")
+		}()
+		go func() {
+			defer wg.Done()
+			c.Set(ctx, "key", "new", 0)
+		}()
+		wg.Wait()
+		a.Equal("new", c.Get(ctx, "key").Val())
+	}
+}
+
+func TestMaxKeyLen(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	long := strings.Repeat("k", DEFAULT_MAX_KEY_LEN+1)
+	a.ErrorIs(c.Set(ctx, long, "value", 0).Err(), jkv.ErrNameTooLong)
+	a.ErrorIs(c.HSet(ctx, long, "field", "value").Err(), jkv.ErrNameTooLong)
+	a.ErrorIs(c.HSet(ctx, "hash", "field", "value", long, "value").Err(), jkv.ErrNameTooLong)
+	a.False(c.HExists(ctx, "hash", "field").Val())
+	a.Nil(c.Set(ctx, long[1:], "value", 0).Err())
+
+	c = NewClient(&Options{Addr: t.TempDir(), MaxKeyLen: 4})
+	a.Nil(c.Open())
+	a.ErrorIs(c.Set(ctx, "tooLong", "value", 0).Err(), jkv.ErrNameTooLong)
+	a.Nil(c.Set(ctx, "ok", "value", 0).Err())
+
+	// encoded names are measured as they are stored, each \xff takes three bytes on disk
+	c = NewClient(&Options{Addr: t.TempDir(), FileNaming: NamingEncoded})
+	a.Nil(c.Open())
+	binary := strings.Repeat("\xff", 100)
+	a.ErrorIs(c.Set(ctx, binary, "value", 0).Err(), jkv.ErrNameTooLong)
+	a.ErrorIs(c.HSet(ctx, binary, "field", "value").Err(), jkv.ErrNameTooLong)
+	a.ErrorIs(c.HSet(ctx, "hash", binary, "value").Err(), jkv.ErrNameTooLong)
+	a.Nil(c.Set(ctx, binary[:DEFAULT_MAX_KEY_LEN/3], "value", 0).Err())
+	a.Nil(c.Set(ctx, long[1:], "value", 0).Err())
+}
+
+func TestDelBatch(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "one", "1", 0)
+	c.Set(ctx, "two", "2", 0)
+	c.HSet(ctx, "hash", "a", "1", "b", "2", "c", "3")
+	c.HSet(ctx, "other", "a", "1")
+
+	rec := c.DelBatch(ctx, []string{"one", "two", "missing"}, map[string][]string{"hash": {"a", "b", "missing"}, "other": {"a"}})
+	a.Nil(rec.Err())
+	a.Equal(int64(5), rec.Val())
+	a.Equal(int64(0), c.Exists(ctx, "one", "two").Val())
+	a.Equal([]string{"c"}, c.HKeys(ctx, "hash").Val())
+	_, err := os.Stat(c.HashDir() + "other")
+	a.True(os.IsNotExist(err))
+}
+
+func benchmarkDel(b *testing.B, batch bool) {
+	ctx := context.Background()
+	c := NewClient(&Options{Addr: b.TempDir()})
+	c.Open()
+	defer c.Close()
+
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for _, key := range keys {
+			c.Set(ctx, key, "value", 0)
+		}
+		b.StartTimer()
+		if batch {
+			c.DelBatch(ctx, keys, nil)
+		} else {
+			for _, key := range keys {
+				c.Del(ctx, key)
+			}
+		}
+	}
+}
+
+func BenchmarkDelIndividually(b *testing.B) { benchmarkDel(b, false) }
+func BenchmarkDelBatch(b *testing.B)        { benchmarkDel(b, true) }
+
+func TestSortKeys(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	for _, key := range []string{"pear", "apple", "fig"} {
+		c.Set(ctx, key, "fruit", 0)
+	}
+	c.HSet(ctx, "banana", "z", "1", "b", "2", "m", "3")
+	a.Equal([]string{"apple", "banana", "fig", "pear"}, c.Keys(ctx, "*").Val())
+	a.Equal([]string{"b", "m", "z"}, c.HKeys(ctx, "banana").Val())
+
+	sortKeys := false
+	c = NewClient(&Options{Addr: c.GetDBDir(), SortKeys: &sortKeys})
+	a.False(c.SortKeys)
+}
+
+func TestHKeysSorted(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	orders := [][]string{{"a", "b", "c", "d"}, {"d", "c", "b", "a"}, {"c", "a", "d", "b"}}
+	for i, order := range orders {
+		hash := fmt.Sprintf("hash%d", i)
+		for _, field := range order {
+			a.Nil(c.HSet(ctx, hash, field, "v").Err())
+		}
+		a.Equal([]string{"a", "b", "c", "d"}, c.HKeys(ctx, hash).Val())
+		a.Len(c.HGetAll(ctx, hash).Val(), 4)
+	}
+}
+
+func TestLockStats(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	a.Equal(int64(0), c.DebugStats(ctx).LockWaits)
+
+	// hold the lock so every writer below has to wait for it
+	c.lock()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.Set(ctx, fmt.Sprintf("key%d", i), "value", 0)
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	c.unlock()
+	wg.Wait()
+
+	stats := c.DebugStats(ctx)
+	a.NotZero(stats.LockWaits)
+	a.NotZero(stats.LockWaitTime)
+}
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	c := NewClient(&Options{Addr: dir})
+	a.Nil(c.Open())
+	for i := 0; i < 10; i++ {
+		c.Set(ctx, fmt.Sprintf("key%d", i), "value", 0)
+	}
+	c.HSet(ctx, "hash", "field", "value")
+	c.Close()
+
+	// scan the first half with one client and resume with another using only the cursor
+	first := NewClient(&Options{Addr: dir})
+	a.Nil(first.Open())
+	keys, cursor := first.Scan(ctx, "0", "key*", 6).Val()
+	a.NotEqual("0", cursor)
+	first.Close()
+
+	second := NewClient(&Options{Addr: dir})
+	a.Nil(second.Open())
+	defer second.Close()
+	for cursor != "0" {
+		rec := second.Scan(ctx, cursor, "key*", 6)
+		a.Nil(rec.Err())
+		var page []string
+		page, cursor = rec.Val()
+		keys = append(keys, page...)
+	}
+	a.Equal([]string{"key0", "key1", "key2", "key3", "key4", "key5", "key6", "key7", "key8", "key9"}, keys)
+
+	a.NotNil(second.Scan(ctx, "bogus", "*", 10).Err())
+	a.NotNil(second.Scan(ctx, "0", "[", 10).Err())
+}
+
+func TestHExpire(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.HSet(ctx, "hash", "short", "1", "long", "2", "forever", "3")
+	a.Equal([]int64{1, 1, -2}, c.HExpire(ctx, "hash", 60, "short", "long", "missing").Val())
+	a.Equal([]int64{60, 60, -1, -2}, c.HTTL(ctx, "hash", "short", "long", "forever", "missing").Val())
+
+	// expire "short" by moving its deadline into the past
+	a.Nil(c.setFieldDeadline("hash", "short", time.Now().Add(-time.Second)))
+	a.NotNil(c.HGet(ctx, "hash", "short").Err())
+	a.False(c.HExists(ctx, "hash", "short").Val())
+	a.Equal([]string{"forever", "long"}, c.HKeys(ctx, "hash").Val())
+	a.Equal("2", c.HGet(ctx, "hash", "long").Val())
+
+	// HSET clears the field's TTL, HEXPIRE 0 deletes it
+	c.HSet(ctx, "hash", "long", "again")
+	a.Equal([]int64{-1}, c.HTTL(ctx, "hash", "long").Val())
+	a.Equal([]int64{2, 2}, c.HExpire(ctx, "hash", 0, "long", "forever").Val())
+	a.Equal(0, len(c.HKeys(ctx, "hash").Val()))
+}
+
+func TestIncrIfBelow(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	var allowed int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, err := c.IncrIfBelow(ctx, "requests", 5); err == nil && ok {
+				atomic.AddInt64(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	a.Equal(int64(5), allowed)
+	a.Equal("5", c.Get(ctx, "requests").Val())
+
+	n, ok, err := c.IncrIfBelow(ctx, "requests", 5)
+	a.Nil(err)
+	a.False(ok)
+	a.Equal(int64(5), n)
+
+	c.Set(ctx, "text", "abc", 0)
+	_, _, err = c.IncrIfBelow(ctx, "text", 5)
+	a.ErrorIs(err, jkv.ErrNotInteger)
+}
+
+func TestHSetCount(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	rec := c.HSet(ctx, "hash", "one", "1", "two", "2")
+	a.Nil(rec.Err())
+	a.Equal(int64(2), rec.Val())
+	a.Equal(int64(0), c.HSet(ctx, "hash", "one", "uno").Val())
+	a.Equal(int64(1), c.HSet(ctx, "hash", "two", "dos", "three", "3").Val())
+	a.Equal("uno", c.HGet(ctx, "hash", "one").Val())
+}
+
+func TestHSetOverFile(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	a.Nil(os.WriteFile(c.HashDir()+"leftover", []byte("junk"), 0664))
+	err := c.HSet(ctx, "leftover", "field", "value").Err()
+	a.ErrorIs(err, jkv.ErrWrongType)
+	a.Contains(err.Error(), "FSCK")
+
+	problems, err := c.Fsck(ctx, false)
+	a.Nil(err)
+	a.Len(problems, 1)
+
+	// a read only client reports but doesn't repair
+	ro := NewClient(&Options{Addr: c.DBDir, ReadOnly: true})
+	a.Nil(ro.Open())
+	problems, err = ro.Fsck(ctx, false)
+	a.Nil(err)
+	a.Len(problems, 1)
+	_, err = ro.Fsck(ctx, true)
+	a.ErrorIs(err, jkv.ErrReadOnly)
+	a.FileExists(c.HashDir() + "leftover")
+
+	problems, err = c.Fsck(ctx, true)
+	a.Nil(err)
+	a.Len(problems, 1)
+	a.FileExists(c.LostDir() + "leftover")
+
+	rec := c.HSet(ctx, "leftover", "field", "value")
+	a.Nil(rec.Err())
+	a.Equal(int64(1), rec.Val())
+	problems, _ = c.Fsck(ctx, false)
+	a.Len(problems, 0)
+}
+
+func TestBits(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	for _, offset := range []int64{0, 7, 100, 1000} {
+		rec := c.SetBit(ctx, "flags", offset, 1)
+		a.Nil(rec.Err())
+		a.Equal(int64(0), rec.Val())
+	}
+	a.Equal(int64(1), c.SetBit(ctx, "flags", 7, 1).Val())
+
+	for _, offset := range []int64{0, 7, 100, 1000} {
+		a.Equal(int64(1), c.GetBit(ctx, "flags", offset).Val())
+	}
+	a.Equal(int64(0), c.GetBit(ctx, "flags", 1).Val())
+	a.Equal(int64(0), c.GetBit(ctx, "flags", 100000).Val())
+	a.Equal(int64(0), c.GetBit(ctx, "missing", 3).Val())
+
+	// offset 1000 is in byte 125 so the value grew with zero bytes
+	a.Equal(126, len(c.Get(ctx, "flags").Val()))
+	a.Equal("\x81", c.Get(ctx, "flags").Val()[:1])
+
+	a.Equal(int64(4), c.BitCount(ctx, "flags", nil).Val())
+	a.Equal(int64(2), c.BitCount(ctx, "flags", &jkv.BitCount{Start: 0, End: 0}).Val())
+	a.Equal(int64(2), c.BitCount(ctx, "flags", &jkv.BitCount{Start: 1, End: -1}).Val())
+	a.Equal(int64(1), c.BitCount(ctx, "flags", &jkv.BitCount{Start: -1, End: -1}).Val())
+
+	a.Equal(int64(1), c.SetBit(ctx, "flags", 0, 0).Val())
+	a.Equal(int64(3), c.BitCount(ctx, "flags", nil).Val())
+	a.Equal(errBitValue, c.SetBit(ctx, "flags", 0, 2).Err())
+
+	// offsets are limited to a 512MB string like in Redis
+	for _, offset := range []int64{-1, 1 << 32, math.MaxInt64} {
+		a.Equal(errBitOffset, c.SetBit(ctx, "flags", offset, 1).Err())
+		a.Equal(errBitOffset, c.GetBit(ctx, "flags", offset).Err())
+	}
+	a.EqualError(errBitOffset, "ERR bit offset is not an integer or out of range")
+	a.Equal(int64(0), c.GetBit(ctx, "flags", 1<<32-1).Val())
+	a.Equal(126, len(c.Get(ctx, "flags").Val()))
+
+	c.HSet(ctx, "hash", "field", "value")
+	a.ErrorIs(c.SetBit(ctx, "hash", 0, 1).Err(), jkv.ErrWrongType)
+	a.ErrorIs(c.GetBit(ctx, "hash", 0).Err(), jkv.ErrWrongType)
+}
+
+func TestFileNaming(t *testing.T) {
+	ctx := context.Background()
+
+	for _, naming := range []FileNaming{NamingPlain, NamingExtension, NamingEncoded} {
+		t.Run(naming.String(), func(t *testing.T) {
+			a := assert.New(t)
+			c := NewClient(&Options{Addr: t.TempDir(), FileNaming: naming})
+			a.Nil(c.Open())
+			defer c.Close()
+
+			keys := []string{"one", "two.words"}
+			if naming == NamingEncoded {
+				keys = append(keys, "a/b c", ".hidden")
+			}
+			for _, key := range keys {
+				a.Nil(c.Set(ctx, key, "value of "+key, 0).Err())
+			}
+			for _, key := range keys {
+				a.Equal("value of "+key, c.Get(ctx, key).Val())
+				a.Equal(int64(1), c.Exists(ctx, key).Val())
+			}
+			a.Nil(c.HSet(ctx, "hash", "field", "value").Err())
+
+			expected := append([]string{"hash"}, keys...)
+			sort.Strings(expected)
+			a.Equal(expected, c.Keys(ctx, "*").Val())
+
+			files, _ := os.ReadDir(c.ScalarDir())
+			a.Len(files, len(keys))
+			for _, file := range files {
+				_, err := os.Stat(c.ScalarDir() + file.Name())
+				a.Nil(err)
+				if naming == NamingExtension {
+					a.True(strings.HasSuffix(file.Name(), ScalarExt))
+				}
+			}
+
+			for _, key := range keys {
+				a.Equal(int64(1), c.Del(ctx, key).Val())
+				a.Equal(int64(0), c.Exists(ctx, key).Val())
+			}
+			a.Equal([]string{"hash"}, c.Keys(ctx, "*").Val())
+		})
+	}
+}
+
+func TestBinaryKeys(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: now}
+	c := NewClient(&Options{Addr: t.TempDir(), FileNaming: NamingEncoded, Clock: clock})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	keys := []string{"a\x00b", "x/y", "\xff\xfe", "../up", "%41", "."}
+	for _, key := range keys {
+		a.Nil(c.Set(ctx, key, "value of "+key, 0).Err())
+		a.Nil(c.HSet(ctx, "h"+key, key, "field of "+key, "plain", "value").Err())
+	}
+	expected := []string{}
+	for _, key := range keys {
+		expected = append(expected, key, "h"+key)
+	}
+	sort.Strings(expected)
+	a.Equal(expected, c.Keys(ctx, "*").Val())
+
+	for _, key := range keys {
+		a.Equal("value of "+key, c.Get(ctx, key).Val())
+		a.Equal("field of "+key, c.HGet(ctx, "h"+key, key).Val())
+		fields := c.HKeys(ctx, "h"+key).Val()
+		sort.Strings(fields)
+		want := []string{key, "plain"}
+		sort.Strings(want)
+		a.Equal(want, fields)
+	}
+	// nothing escapes the database directory
+	entries, _ := os.ReadDir(c.DBDir + "/..")
+	a.Len(entries, 1)
+
+	// deadlines on binary keys and fields expire them
+	a.Nil(c.Set(ctx, keys[0], "short lived", time.Second).Err())
+	a.Equal([]int64{1}, c.HExpire(ctx, "h"+keys[1], 1, keys[1]).Val())
+	clock.Advance(2 * time.Second)
+	a.ErrorIs(c.Get(ctx, keys[0]).Err(), jkv.Nil)
+	a.Equal([]string{"plain"}, c.HKeys(ctx, "h"+keys[1]).Val())
+	a.Equal(len(expected)-1, len(c.Keys(ctx, "*").Val()))
+	problems, err := c.Fsck(ctx, false)
+	a.Nil(err)
+	a.Empty(problems)
+}
+
+func TestFlushDBKeepsUnrelatedFiles(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	c := NewClient(&Options{Addr: dir})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	a.Nil(os.WriteFile(dir+"/README", []byte("not a key"), 0664))
+	c.Set(ctx, "key", "value", time.Hour)
+	c.HSet(ctx, "hash", "field", "value")
+
+	a.Nil(c.FlushDB(ctx).Err())
+	a.Len(c.Keys(ctx, "*").Val(), 0)
+	data, err := os.ReadFile(dir + "/README")
+	a.Nil(err)
+	a.Equal("not a key", string(data))
+
+	// the store is still usable without reopening it
+	a.Nil(c.Set(ctx, "key", "again", 0).Err())
+	a.Equal("again", c.Get(ctx, "key").Val())
+}
+
+func TestEncoding(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "key", "value", 0)
+	c.HSet(ctx, "hash", "field", "value")
+	a.Equal(EncodingRaw, c.Encoding(ctx, "key").Val())
+	a.Equal(EncodingHashDir, c.Encoding(ctx, "hash").Val())
+	a.ErrorIs(c.Encoding(ctx, "missing").Err(), os.ErrNotExist)
+}
+
+func TestPop(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "key", "value", time.Hour)
+	rec := c.PopScalar(ctx, "key")
+	a.Nil(rec.Err())
+	a.Equal("value", rec.Val())
+	a.Equal(int64(0), c.Exists(ctx, "key").Val())
+	a.NotNil(c.PopScalar(ctx, "key").Err())
+
+	c.HSet(ctx, "hash", "one", "1", "two", "2")
+	rec = c.HPop(ctx, "hash", "one")
+	a.Nil(rec.Err())
+	a.Equal("1", rec.Val())
+	a.False(c.HExists(ctx, "hash", "one").Val())
+	a.NotNil(c.HPop(ctx, "hash", "one").Err())
+
+	a.Equal("2", c.HPop(ctx, "hash", "two").Val())
+	_, err := os.Stat(c.HashDir() + "hash")
+	a.True(os.IsNotExist(err))
+}
+
+func TestMeta(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	a.Nil(c.SetWithMeta(ctx, "doc", `{"a":1}`, map[string]string{"content-type": "application/json"}).Err())
+	value, meta, err := c.GetWithMeta(ctx, "doc")
+	a.Nil(err)
+	a.Equal(`{"a":1}`, value)
+	a.Equal(map[string]string{"content-type": "application/json"}, meta)
+	a.Equal(`{"a":1}`, c.Get(ctx, "doc").Val())
+
+	// a plain SET replaces the value and its metadata
+	a.Nil(c.Set(ctx, "doc", "text", 0).Err())
+	value, meta, err = c.GetWithMeta(ctx, "doc")
+	a.Nil(err)
+	a.Equal("text", value)
+	a.Len(meta, 0)
+
+	c.SetWithMeta(ctx, "doc", "x", map[string]string{"content-type": "text/plain"})
+	c.Del(ctx, "doc")
+	_, err = os.Stat(c.MetaDir() + "doc")
+	a.True(os.IsNotExist(err))
+	_, _, err = c.GetWithMeta(ctx, "doc")
+	a.NotNil(err)
+}
+
+func TestKeysSkipExpired(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	c := NewClient(&Options{Addr: dir})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "live", "value", time.Hour)
+	c.Set(ctx, "gone", "value", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	// the diagnostic view still lists the expired key
+	diag := NewClient(&Options{Addr: dir, IncludeExpired: true})
+	a.Nil(diag.Open())
+	a.Equal([]string{"gone", "live"}, diag.Keys(ctx, "*").Val())
+	keys, _ := diag.Scan(ctx, "0", "*", 10).Val()
+	a.Equal([]string{"gone", "live"}, keys)
+
+	a.Equal([]string{"live"}, c.Keys(ctx, "*").Val())
+	keys, _ = c.Scan(ctx, "0", "*", 10).Val()
+	a.Equal([]string{"live"}, keys)
+
+	// KEYS reaped it
+	_, err := os.Stat(c.scalarPath("gone"))
+	a.True(os.IsNotExist(err))
+	a.Equal([]string{"live"}, diag.Keys(ctx, "*").Val())
+
+	// a read only client leaves expired keys on disk, they are still left out whether or not they match
+	c.Set(ctx, "gone", "value", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	ro := NewClient(&Options{Addr: dir, ReadOnly: true})
+	a.Nil(ro.Open())
+	a.Equal([]string{"live"}, ro.Keys(ctx, "l*").Val())
+	a.Equal([]string{"live"}, ro.Keys(ctx, "*").Val())
+	a.Equal([]string{}, ro.Keys(ctx, "g*").Val())
+	_, err = os.Stat(c.scalarPath("gone"))
+	a.Nil(err)
+}
+
+func TestOpenRegularFile(t *testing.T) {
+	a := assert.New(t)
+
+	path := t.TempDir() + "/db"
+	a.Nil(os.WriteFile(path, []byte("not a database"), 0664))
+	c := NewClient(&Options{Addr: path})
+	err := c.Open()
+	a.EqualError(err, "DBDir "+path+" exists and is not a directory")
+	a.False(c.IsOpen())
+}
+
+func TestDo(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	rec := c.Do(ctx, "set", "key", "value", "EX", 60)
+	a.Nil(rec.Err())
+	a.Equal("OK", rec.Val())
+	a.Equal("value", c.Do(ctx, "GET", "key").Val())
+	a.Equal(int64(2), c.Do(ctx, "hset", "hash", "one", 1, "two", 2).Val())
+	a.Equal("1", c.Do(ctx, "hget", "hash", "one").Val())
+	a.Equal([]interface{}{"hash", "key"}, c.Do(ctx, "keys", "*").Val())
+	a.Equal(int64(1), c.Do(ctx, "hexists", "hash", "two").Val())
+	a.Equal(int64(1), c.Do(ctx, "del", "key").Val())
+
+	a.EqualError(c.Do(ctx, "nosuch", "key").Err(), "ERR unknown command 'nosuch'")
+	a.EqualError(c.Do(ctx, "get").Err(), "ERR wrong number of arguments for 'get' command")
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "req-1")
+	a := assert.New(t)
+
+	var log bytes.Buffer
+	c := NewClient(&Options{Addr: t.TempDir(), AuditLog: &log})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "key", "value", 0)
+	c.Get(ctx, "key")
+	c.Del(context.Background(), "key")
+
+	var records []AuditRecord
+	for _, line := range strings.Split(strings.TrimSpace(log.String()), "\n") {
+		var record AuditRecord
+		a.Nil(json.Unmarshal([]byte(line), &record))
+		a.False(record.Time.IsZero())
+		record.Time = time.Time{}
+		records = append(records, record)
+	}
+	a.Equal([]AuditRecord{
+		{Op: "SET", Key: "key", RequestID: "req-1"},
+		{Op: "DEL", Key: "key"},
+	}, records)
+
+	log.Reset()
+	c.AuditReads = true
+	c.Get(ctx, "key")
+	a.Contains(log.String(), `"op":"GET","key":"key","request_id":"req-1"`)
+}
+
+func TestRenamePrefix(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "user:1", "alice", 0)
+	c.Set(ctx, "user:2", "bob", time.Hour)
+	c.HSet(ctx, "user:3", "name", "carol")
+	c.Set(ctx, "other", "x", 0)
+
+	rec := c.RenamePrefix(ctx, "user:", "account:")
+	a.Nil(rec.Err())
+	a.Equal(int64(3), rec.Val())
+	a.Equal([]string{"account:1", "account:2", "account:3", "other"}, c.Keys(ctx, "*").Val())
+	a.Equal("alice", c.Get(ctx, "account:1").Val())
+	a.Equal("bob", c.Get(ctx, "account:2").Val())
+	_, ok := c.deadline("account:2")
+	a.True(ok)
+	a.Equal("carol", c.HGet(ctx, "account:3", "name").Val())
+
+	// nothing moves if a new name is taken
+	c.Set(ctx, "user:1", "dave", 0)
+	c.Set(ctx, "user:9", "eve", 0)
+	a.ErrorIs(c.RenamePrefix(ctx, "user:", "account:").Err(), errTargetExists)
+	a.Equal("dave", c.Get(ctx, "user:1").Val())
+	a.Equal("eve", c.Get(ctx, "user:9").Val())
+}
+
+func TestRenamePrefixEncoded(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir(), FileNaming: NamingEncoded})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	// names that encode to something else on disk
+	c.SetWithMeta(ctx, "old/a b", "value", map[string]string{"owner": "alice"})
+	c.Expire(ctx, "old/a b", time.Hour)
+	c.HSet(ctx, "old/h%", "field", "value")
+	c.HExpire(ctx, "old/h%", 3600, "field")
+
+	rec := c.RenamePrefix(ctx, "old/", "new/")
+	a.Nil(rec.Err())
+	a.Equal(int64(2), rec.Val())
+	a.Equal([]string{"new/a b", "new/h%"}, c.Keys(ctx, "*").Val())
+	a.Equal(int64(3600), c.TTL(ctx, "new/a b").Val())
+	value, meta, err := c.GetWithMeta(ctx, "new/a b")
+	a.Nil(err)
+	a.Equal("value", value)
+	a.Equal(map[string]string{"owner": "alice"}, meta)
+	a.Equal([]int64{3600}, c.HTTL(ctx, "new/h%", "field").Val())
+
+	// no sidecar is left under the old names
+	for _, dir := range []string{c.ExpireDir(), c.FieldExpireDir(), c.MetaDir()} {
+		entries, err := os.ReadDir(dir)
+		a.Nil(err)
+		for _, entry := range entries {
+			name, ok := c.nameOf(entry.Name())
+			a.True(ok)
+			a.True(strings.HasPrefix(name, "new/"), dir+entry.Name())
+		}
+	}
+}
+
+func TestRecordDuration(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+	a.Zero(c.Set(ctx, "key", "value", 0).Duration())
+	a.Zero(c.Get(ctx, "key").Duration())
+
+	c.RecordDuration = true
+	a.Positive(c.Set(ctx, "key", "value", 0).Duration())
+	a.Positive(c.Get(ctx, "key").Duration())
+	a.Positive(c.Do(ctx, "get", "key").Duration())
+	a.Positive(c.Get(ctx, "missing").Duration())
+}
+
+func TestScanType(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	for i := 0; i < 10; i++ {
+		c.Set(ctx, fmt.Sprintf("key%d", i), "value", 0)
+		if i%3 == 0 {
+			c.HSet(ctx, fmt.Sprintf("hash%d", i), "field", "value")
+		}
+	}
+
+	var hashes []string
+	cursor, pages := "0", 0
+	for {
+		rec := c.ScanType(ctx, cursor, "*", 3, "hash")
+		a.Nil(rec.Err())
+		keys, next := rec.Val()
+		hashes = append(hashes, keys...)
+		pages++
+		if cursor = next; cursor == "0" {
+			break
+		}
+	}
+	a.Equal([]string{"hash0", "hash3", "hash6", "hash9"}, hashes)
+	a.Equal(5, pages)
+
+	keys, _ := c.ScanType(ctx, "0", "*", 100, "string").Val()
+	a.Len(keys, 10)
+	keys, _ = c.ScanType(ctx, "0", "*", 100, "list").Val()
+	a.Len(keys, 0)
+	a.NotNil(c.ScanType(ctx, "0", "*", 100, "nosuch").Err())
+}
+
+func TestMissingSubdirectory(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "key", "value", 0)
+	c.HSet(ctx, "hash", "field", "value")
+	a.Nil(os.RemoveAll(c.HashDir()))
+
+	a.Equal("value", c.Get(ctx, "key").Val())
+	rec := c.Keys(ctx, "*")
+	a.Nil(rec.Err())
+	a.Equal([]string{"key"}, rec.Val())
+	keys, _ := c.Scan(ctx, "0", "*", 10).Val()
+	a.Equal([]string{"key"}, keys)
+	hkeys := c.HKeys(ctx, "hash")
+	a.Nil(hkeys.Err())
+	a.Len(hkeys.Val(), 0)
+	a.False(c.HExists(ctx, "hash", "field").Val())
+
+	// reopening puts the directory back
+	a.Nil(c.Open())
+	_, err := os.Stat(c.HashDir())
+	a.Nil(err)
+}
+
+func TestBulk(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir(), BulkBatch: 100})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "key5", "old", time.Hour)
+	a.Nil(c.BeginBulk(ctx))
+	for i := 0; i < 250; i++ {
+		a.Nil(c.Set(ctx, fmt.Sprintf("key%d", i), fmt.Sprintf("value%d", i), 0).Err())
+	}
+	// reads see buffered values
+	a.Equal("value249", c.Get(ctx, "key249").Val())
+	a.Nil(c.EndBulk(ctx))
+
+	a.Len(c.Keys(ctx, "*").Val(), 250)
+	for i := 0; i < 250; i++ {
+		a.Equal(fmt.Sprintf("value%d", i), c.Get(ctx, fmt.Sprintf("key%d", i)).Val())
+	}
+	_, ok := c.deadline("key5")
+	a.False(ok)
+
+	t.Run("EndBulk during SETs", func(t *testing.T) {
+		a := assert.New(t)
+		a.Nil(c.BeginBulk(ctx))
+		var wg sync.WaitGroup
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < 200; i++ {
+					a.Nil(c.Set(ctx, fmt.Sprintf("race%d-%d", w, i), "value", 0).Err())
+				}
+			}(w)
+		}
+		a.Nil(c.EndBulk(ctx))
+		wg.Wait()
+		a.Len(c.Keys(ctx, "race*").Val(), 800)
+	})
+
+	t.Run("Durable", func(t *testing.T) {
+		a := assert.New(t)
+		c := NewClient(&Options{Addr: t.TempDir(), Durable: true})
+		a.Nil(c.Open())
+		defer c.Close()
+		a.Nil(c.BeginBulk(ctx))
+		for i := 0; i < 10; i++ {
+			a.Nil(c.Set(ctx, fmt.Sprintf("key%d", i), "value", 0).Err())
+		}
+		before := c.DebugStats(ctx).Fsyncs
+		a.Nil(c.EndBulk(ctx))
+		// one per value and one for the directory
+		a.Equal(before+11, c.DebugStats(ctx).Fsyncs)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		a := assert.New(t)
+		c := NewClient(&Options{Addr: t.TempDir()})
+		a.ErrorIs(c.BeginBulk(ctx), ErrNotOpen)
+		a.ErrorIs(c.EndBulk(ctx), ErrNotOpen)
+	})
+}
+
+// The bulk benchmarks are only a guide: each key is still its own file, so creating the files dominates either way
+func benchmarkSet(b *testing.B, bulk, durable bool) {
+	ctx := context.Background()
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		c := NewClient(&Options{Addr: b.TempDir(), Durable: durable})
+		c.Open()
+		b.StartTimer()
+		if bulk {
+			c.BeginBulk(ctx)
+		}
+		for i := 0; i < 100000; i++ {
+			c.Set(ctx, "key"+strconv.Itoa(i), "value", 0)
+		}
+		if bulk {
+			c.EndBulk(ctx)
+		}
+	}
+}
+
+func BenchmarkSet100k(b *testing.B)            { benchmarkSet(b, false, false) }
+func BenchmarkBulkSet100k(b *testing.B)        { benchmarkSet(b, true, false) }
+func BenchmarkDurableSet100k(b *testing.B)     { benchmarkSet(b, false, true) }
+func BenchmarkDurableBulkSet100k(b *testing.B) { benchmarkSet(b, true, true) }
+
+func TestConfigGet(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	c := NewClient(&Options{Addr: dir, ReadOnly: true})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	a.Equal(map[string]string{"dir": dir}, c.ConfigGet(ctx, "dir").Val())
+	a.Equal(map[string]string{"readonly": "yes", "record-duration": "no"}, c.ConfigGet(ctx, "re*").Val())
+	a.Equal("fs", c.ConfigGet(ctx, "*").Val()["backend"])
+	a.Len(c.ConfigGet(ctx, "nosuch").Val(), 0)
+}
+
+func TestSetOption(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "key", "value", 0)
+	a.Equal(int64(0), c.DebugStats(ctx).Fsyncs)
+
+	a.Nil(c.SetOption(ctx, "durable", "yes"))
+	a.Equal("yes", c.ConfigGet(ctx, "durable").Val()["durable"])
+	c.Set(ctx, "key", "value", 0)
+	c.HSet(ctx, "hash", "field", "value")
+	c.SetBit(ctx, "bits", 3, 1)
+	a.Equal(int64(3), c.DebugStats(ctx).Fsyncs)
+	a.Equal("value", c.Get(ctx, "key").Val())
+
+	a.Nil(c.SetOption(ctx, "durable", "no"))
+	c.Set(ctx, "key", "value", 0)
+	a.Equal(int64(3), c.DebugStats(ctx).Fsyncs)
+
+	a.Nil(c.SetOption(ctx, "max-key-len", "4"))
+	a.ErrorIs(c.Set(ctx, "toolong", "value", 0).Err(), jkv.ErrNameTooLong)
+	a.Nil(c.SetOption(ctx, "readonly", "yes"))
+	a.ErrorIs(c.Set(ctx, "key", "value", 0).Err(), jkv.ErrReadOnly)
+
+	a.EqualError(c.SetOption(ctx, "dir", "/tmp"), "ERR CONFIG SET 'dir' can't be changed without reopening the database")
+	a.NotNil(c.SetOption(ctx, "durable", "maybe"))
+	a.NotNil(c.SetOption(ctx, "nosuch", "yes"))
+}
+
+// TestSetOptionConcurrent changes settings while other goroutines use the client, for go test -race to check
+func TestSetOptionConcurrent(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient(&Options{Addr: t.TempDir()})
+	assert.Nil(t, c.Open())
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			key := "key" + strconv.Itoa(g)
+			for i := 0; i < 100; i++ {
+				c.Set(ctx, key, "value", 0)
+				c.Get(ctx, key)
+				c.HSet(ctx, "hash", key, "value")
+				c.HKeys(ctx, "hash")
+				c.Keys(ctx, "*")
+			}
+		}(g)
+	}
+	for i := 0; i < 100; i++ {
+		for _, name := range []string{"readonly", "sort-keys", "include-expired", "audit-reads", "record-duration",
+			"durable", "keep-empty-hashes"} {
+			c.SetOption(ctx, name, yesNo(i%2 == 0))
+		}
+		c.SetOption(ctx, "max-key-len", strconv.Itoa(100+i))
+		c.ConfigGet(ctx, "*")
+	}
+	wg.Wait()
+}
+
+func TestExistsListed(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	for _, naming := range []FileNaming{NamingPlain, NamingEncoded} {
+		c := NewClient(&Options{Addr: t.TempDir(), FileNaming: naming})
+		a.Nil(c.Open())
+		var keys []string
+		for i := 0; i < 50; i++ {
+			key := fmt.Sprintf("key %d", i)
+			keys = append(keys, key)
+			if i%2 == 0 {
+				c.Set(ctx, key, "value", 0)
+			}
+		}
+		c.HSet(ctx, "hash", "field", "value")
+		keys = append(keys, "hash", "key 0")
+
+		// duplicates count twice and hashes aren't scalars, the same as with a stat per key
+		n, err := c.existsListed(keys)
+		a.Nil(err)
+		a.Equal(int64(26), n)
+		a.Equal(c.existsStat(keys), n)
+		a.True(c.listFaster(len(keys)))
+		a.Equal(int64(26), c.Exists(ctx, keys...).Val())
+		c.Close()
+	}
+}
+
+func benchmarkExists(b *testing.B, listed bool) {
+	ctx := context.Background()
+	c := NewClient(&Options{Addr: b.TempDir()})
+	c.Open()
+	var keys []string
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, "key"+strconv.Itoa(i))
+		c.Set(ctx, keys[i], "value", 0)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if listed {
+			c.existsListed(keys)
+		} else {
+			c.existsStat(keys)
+		}
+	}
+}
+
+func BenchmarkExistsStat(b *testing.B)   { benchmarkExists(b, false) }
+func BenchmarkExistsListed(b *testing.B) { benchmarkExists(b, true) }
+
+func TestVersion(t *testing.T) {
+	c := NewClient(&Options{Addr: t.TempDir()})
+	rec := c.Version(context.Background())
+	assert.Nil(t, rec.Err())
+	assert.Equal(t, jkv.VERSION, rec.Val())
+}
+
+func TestKeepEmptyHashes(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir(), KeepEmptyHashes: true})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.HSet(ctx, "hash", "field", "value")
+	a.Equal(int64(1), c.HDel(ctx, "hash", "field").Val())
+	info, err := os.Stat(c.HashDir() + "hash")
+	a.Nil(err)
+	a.True(info.IsDir())
+
+	rec := c.HKeys(ctx, "hash")
+	a.Nil(rec.Err())
+	a.Len(rec.Val(), 0)
+	a.False(c.HExists(ctx, "hash", "field").Val())
+	a.Len(c.Keys(ctx, "*").Val(), 0)
+	keys, _ := c.ScanType(ctx, "0", "*", 10, "hash").Val()
+	a.Len(keys, 0)
+
+	a.Equal(int64(1), c.HSet(ctx, "hash", "field", "again").Val())
+	a.Equal([]string{"hash"}, c.Keys(ctx, "*").Val())
+}
+
+func benchmarkHashChurn(b *testing.B, keep bool) {
+	ctx := context.Background()
+	c := NewClient(&Options{Addr: b.TempDir(), KeepEmptyHashes: keep})
+	c.Open()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		c.HSet(ctx, "hash", "field", "value")
+		c.HDel(ctx, "hash", "field")
+	}
+}
+
+func BenchmarkHashChurn(b *testing.B)          { benchmarkHashChurn(b, false) }
+func BenchmarkHashChurnKeepEmpty(b *testing.B) { benchmarkHashChurn(b, true) }
+
+func TestCompact(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir(), KeepEmptyHashes: true})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	for i := 0; i < 5; i++ {
+		hash := fmt.Sprintf("hash%d", i)
+		c.HSet(ctx, hash, "field", "value")
+		c.HDel(ctx, hash, "field")
+	}
+	c.HSet(ctx, "live", "field", "value")
+	c.HExpire(ctx, "live", 60, "field")
+	c.Set(ctx, "key", "value", time.Hour)
+	// sidecars left behind by a crash
+	a.Nil(os.WriteFile(c.ExpireDir()+"gone", []byte("1"), 0664))
+	a.Nil(os.WriteFile(c.MetaDir()+"gone", []byte("{}"), 0664))
+	a.Nil(os.MkdirAll(c.FieldExpireDir()+"gone", 0775))
+	a.Nil(os.WriteFile(c.FieldExpireDir()+"gone/field", []byte("1"), 0664))
+
+	rec := c.Compact(ctx)
+	a.Nil(rec.Err())
+	a.Positive(rec.Val())
+
+	entries, _ := os.ReadDir(c.HashDir())
+	a.Len(entries, 1)
+	for _, path := range []string{c.ExpireDir() + "gone", c.MetaDir() + "gone", c.FieldExpireDir() + "gone"} {
+		_, err := os.Stat(path)
+		a.True(os.IsNotExist(err), path)
+	}
+
+	a.Equal("value", c.Get(ctx, "key").Val())
+	_, ok := c.deadline("key")
+	a.True(ok)
+	a.Equal("value", c.HGet(ctx, "live", "field").Val())
+	a.Equal([]int64{60}, c.HTTL(ctx, "live", "field").Val())
+	a.Equal(int64(0), c.Compact(ctx).Val())
+}
+
+func TestKeyNotFound(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	a.Nil(c.Set(ctx, "empty", "", 0).Err())
+	info, err := os.Stat(c.scalarPath("empty"))
+	a.Nil(err)
+	a.Equal(int64(0), info.Size())
+	rec := c.Get(ctx, "empty")
+	a.Nil(rec.Err())
+	a.Equal("", rec.Val())
+	a.ErrorIs(c.Get(ctx, "missing").Err(), jkv.ErrKeyNotFound)
+
+	c.HSet(ctx, "hash", "empty", "")
+	rec = c.HGet(ctx, "hash", "empty")
+	a.Nil(rec.Err())
+	a.Equal("", rec.Val())
+	a.ErrorIs(c.HGet(ctx, "hash", "missing").Err(), jkv.ErrKeyNotFound)
+	a.ErrorIs(c.HGet(ctx, "missing", "field").Err(), jkv.ErrKeyNotFound)
+	a.ErrorIs(c.PopScalar(ctx, "missing").Err(), jkv.ErrKeyNotFound)
+}
+
+// fakeClock is a Clock that only moves when told to
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func TestClock(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	clock := &fakeClock{now: time.Date(2024, 7, 11, 13, 0, 0, 0, time.UTC)}
+	c := NewClient(&Options{Addr: t.TempDir(), Clock: clock})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "key", "value", time.Hour)
+	c.HSet(ctx, "hash", "field", "value")
+	c.HExpire(ctx, "hash", 60, "field")
+	clock.Advance(59 * time.Second)
+	a.Equal("value", c.Get(ctx, "key").Val())
+	a.Equal([]int64{1}, c.HTTL(ctx, "hash", "field").Val())
+
+	clock.Advance(time.Second)
+	a.ErrorIs(c.HGet(ctx, "hash", "field").Err(), jkv.ErrKeyNotFound)
+	clock.Advance(time.Hour)
+	a.ErrorIs(c.Get(ctx, "key").Err(), jkv.ErrKeyNotFound)
+	a.Len(c.Keys(ctx, "*").Val(), 0)
+}
+
+func TestScanAllDBs(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	root := t.TempDir()
+
+	db0 := NewClient(&Options{Addr: root})
+	db2 := NewClient(&Options{Addr: root, DB: 2})
+	a.Nil(db0.Open())
+	a.Nil(db2.Open())
+	defer db0.Close()
+	defer db2.Close()
+
+	db0.Set(ctx, "user:1", "zero", 0)
+	db0.Set(ctx, "other", "zero", 0)
+	db2.HSet(ctx, "user:2", "field", "two")
+	a.Equal([]string{"other", "user:1"}, db0.Keys(ctx, "*").Val())
+
+	keys, err := db2.ScanAllDBs(ctx, "user:*")
+	a.Nil(err)
+	a.Equal([]DBKey{{DB: 0, Key: "user:1"}, {DB: 2, Key: "user:2"}}, keys)
+	dbs, err := db0.DBs()
+	a.Nil(err)
+	a.Equal([]int{0, 2}, dbs)
+}
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	large := strings.Repeat("0123456789abcdef", 1<<18) // 4MiB
+	a.Equal("OK", c.SetFrom(ctx, "large", strings.NewReader(large)).Val())
+	a.Equal([]string{"large"}, c.Keys(ctx, "*").Val())
+
+	var buf bytes.Buffer
+	rec := c.GetTo(ctx, "large", &buf)
+	a.Nil(rec.Err())
+	a.Equal(int64(len(large)), rec.Val())
+	a.Equal(large, buf.String())
+	a.Equal(large, c.Get(ctx, "large").Val())
+
+	c.Set(ctx, "small", "value", time.Hour)
+	a.Nil(c.SetFrom(ctx, "small", strings.NewReader("streamed")).Err())
+	a.Equal("streamed", c.Get(ctx, "small").Val())
+	_, ok := c.deadline("small")
+	a.False(ok)
+	a.ErrorIs(c.GetTo(ctx, "missing", &buf).Err(), jkv.ErrKeyNotFound)
+
+	// other writers go ahead while SetFrom waits on its reader
+	r, w := io.Pipe()
+	done := make(chan *jkv.StatusCmd)
+	go func() { done <- c.SetFrom(ctx, "slow", r) }()
+	w.Write([]byte("part"))
+	a.Nil(c.Set(ctx, "other", "value", 0).Err())
+	a.Equal(jkv.ErrKeyNotFound, c.Get(ctx, "slow").Err())
+	w.Write([]byte(" and the rest"))
+	w.Close()
+	a.Nil((<-done).Err())
+	a.Equal("part and the rest", c.Get(ctx, "slow").Val())
+}
+
+func TestNil(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.HSet(ctx, "hash", "field", "value")
+	a.True(errors.Is(c.Get(ctx, "missing").Err(), jkv.Nil))
+	a.True(errors.Is(c.HGet(ctx, "hash", "missing").Err(), jkv.Nil))
+	a.True(errors.Is(c.HGet(ctx, "missing", "field").Err(), jkv.Nil))
+	a.True(errors.Is(c.Do(ctx, "get", "missing").Err(), jkv.Nil))
+}
+
+func TestHyperLogLog(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	a.Equal(int64(1), c.PFAdd(ctx, "empty").Val())
+	a.Equal(int64(0), c.PFCount(ctx, "empty").Val())
+	a.Equal(int64(0), c.PFCount(ctx, "missing").Val())
+
+	const n = 100000
+	for i := 0; i < n; i += 1000 {
+		elements := make([]string, 1000)
+		for j := range elements {
+			elements[j] = "element:" + strconv.Itoa(i+j)
+		}
+		a.Nil(c.PFAdd(ctx, "hll", elements...).Err())
+	}
+	a.Equal(int64(0), c.PFAdd(ctx, "hll", "element:1", "element:2").Val())
+	// 3 standard errors of 0.81%
+	a.InDelta(n, c.PFCount(ctx, "hll").Val(), n*0.0243)
+
+	small := []string{"a", "b", "c", "d", "e"}
+	c.PFAdd(ctx, "small", small...)
+	a.Equal(int64(5), c.PFCount(ctx, "small").Val())
+
+	c.Set(ctx, "string", "value", 0)
+	a.ErrorIs(c.PFAdd(ctx, "string", "a").Err(), jkv.ErrWrongType)
+	a.ErrorIs(c.PFCount(ctx, "string").Err(), jkv.ErrWrongType)
+}
+
+func TestPFMerge(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	for i := 0; i < 1000; i++ {
+		c.PFAdd(ctx, "even", strconv.Itoa(2*i))
+		c.PFAdd(ctx, "low", strconv.Itoa(i))
+	}
+	// 0..998 are in both, so the union holds the 1000 values below 1000 and the 500 even ones above it
+	a.InDelta(1500, c.PFCount(ctx, "even", "low").Val(), 1500*0.0243)
+	a.Equal("OK", c.PFMerge(ctx, "union", "even", "low").Val())
+	a.Equal(c.PFCount(ctx, "even", "low").Val(), c.PFCount(ctx, "union").Val())
+	a.Equal(int64(0), c.PFAdd(ctx, "union", "1998").Val())
+}
+
+func TestActiveExpire(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	clock := &fakeClock{now: time.Date(2024, 7, 11, 13, 0, 0, 0, time.UTC)}
+	c := NewClient(&Options{Addr: t.TempDir(), Clock: clock, ActiveExpire: true, ActiveExpireInterval: time.Millisecond})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.SetActiveExpire(false)
+	c.Set(ctx, "lazy", "value", time.Minute)
+	clock.Advance(2 * time.Minute)
+	time.Sleep(20 * time.Millisecond)
+	_, err := os.Stat(c.scalarPath("lazy"))
+	a.Nil(err, "the key should still be on disk with active expiry off")
+	a.ErrorIs(c.Get(ctx, "lazy").Err(), jkv.ErrKeyNotFound)
+	_, err = os.Stat(c.scalarPath("lazy"))
+	a.True(os.IsNotExist(err))
+
+	a.Nil(c.SetOption(ctx, "active-expire", "yes"))
+	c.Set(ctx, "active", "value", time.Minute)
+	c.HSet(ctx, "hash", "field", "value", "other", "value")
+	c.HExpire(ctx, "hash", 60, "field")
+	clock.Advance(2 * time.Minute)
+	a.Eventually(func() bool {
+		_, err := os.Stat(c.scalarPath("active"))
+		return os.IsNotExist(err)
+	}, time.Second, time.Millisecond)
+	a.Eventually(func() bool {
+		_, err := os.Stat(c.HashDir() + "hash/field")
+		return os.IsNotExist(err)
+	}, time.Second, time.Millisecond)
+	a.Equal("value", c.HGet(ctx, "hash", "other").Val())
+	a.Equal("yes", c.ConfigGet(ctx, "active-expire").Val()["active-expire"])
+}
+
+func TestCompareAndSet(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	// a missing key isn't the same as an empty one
+	a.False(c.CompareAndSet(ctx, "key", "", "one").Val())
+	a.Equal(jkv.ErrKeyNotFound, c.Get(ctx, "key").Err())
+	c.Set(ctx, "key", "", 0)
+	a.True(c.CompareAndSet(ctx, "key", "", "one").Val())
+	a.False(c.CompareAndSet(ctx, "key", "", "two").Val())
+	a.False(c.CompareAndSet(ctx, "key", "two", "three").Val())
+	a.Equal("one", c.Get(ctx, "key").Val())
+	a.True(c.CompareAndSet(ctx, "key", "one", "two").Val())
+	a.Equal("two", c.Get(ctx, "key").Val())
+
+	c.HSet(ctx, "hash", "field", "value")
+	a.ErrorIs(c.CompareAndSet(ctx, "hash", "", "value").Err(), jkv.ErrWrongType)
+
+	var wg sync.WaitGroup
+	var swaps int32
+	c.Set(ctx, "contended", "start", 0)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if c.CompareAndSet(ctx, "contended", "start", strconv.Itoa(i)).Val() {
+				atomic.AddInt32(&swaps, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	a.Equal(int32(1), swaps)
+}
+
+func TestSetNX(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	clock := &fakeClock{now: time.Date(2024, 7, 11, 13, 0, 0, 0, time.UTC)}
+	c := NewClient(&Options{Addr: t.TempDir(), Clock: clock})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	a.True(c.SetNX(ctx, "key", "one", time.Minute).Val())
+	a.False(c.SetNX(ctx, "key", "two", 0).Val())
+	c.HSet(ctx, "hash", "field", "value")
+	a.False(c.SetNX(ctx, "hash", "value", 0).Val())
+	clock.Advance(time.Minute)
+	a.True(c.SetNX(ctx, "key", "two", 0).Val())
+	a.Equal("two", c.Get(ctx, "key").Val())
+
+	a.False(c.CompareAndDelete(ctx, "key", "one").Val())
+	a.False(c.CompareAndDelete(ctx, "missing", "").Val())
+	a.True(c.CompareAndDelete(ctx, "key", "two").Val())
+	a.ErrorIs(c.Get(ctx, "key").Err(), jkv.ErrKeyNotFound)
+}
+
+func TestInfo(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	clock := &fakeClock{now: time.Date(2024, 7, 11, 13, 0, 0, 0, time.UTC)}
+	c := NewClient(&Options{Addr: t.TempDir(), Clock: clock})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "key", "value", 0)
+	c.Set(ctx, "key", "other", 0)
+	c.Get(ctx, "key")
+	c.HSet(ctx, "hash", "field", "value")
+	c.HGet(ctx, "hash", "field")
+	c.Del(ctx, "key")
+	clock.Advance(90 * time.Second)
+
+	a.Equal("# Commandstats\r\n"+
+		"cmdstat_del:calls=1\r\ncmdstat_get:calls=1\r\ncmdstat_hget:calls=1\r\ncmdstat_hset:calls=1\r\ncmdstat_set:calls=2\r\n",
+		c.Info(ctx, "commandstats").Val())
+	info := c.Info(ctx).Val()
+	a.Contains(info, "# Server\r\n")
+	a.Contains(info, "uptime_in_seconds:90\r\n")
+	a.Contains(info, "total_commands_processed:6\r\n")
+	a.Contains(info, "total_bytes_written:15\r\n")
+	a.Contains(info, "total_bytes_read:10\r\n")
+	a.NotContains(c.Info(ctx, "stats").Val(), "# Server")
+}
+
+func TestHashMatch(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+	c.SortKeys = true
+
+	a.Nil(c.HSet(ctx, "hash", "config:a", "1", "config:b", "22", "other", "a much longer value").Err())
+	a.Equal([]string{"config:a", "config:b"}, c.HKeysMatch(ctx, "hash", "config:*").Val())
+	a.Equal([]string{}, c.HKeysMatch(ctx, "hash", "nosuch*").Val())
+	a.Equal([]string{}, c.HKeysMatch(ctx, "nosuch", "*").Val())
+	a.NotNil(c.HKeysMatch(ctx, "hash", "[").Err())
+
+	// only the values of matching fields are read
+	before := c.DebugStats(ctx).BytesRead
+	rec := c.HGetAllMatch(ctx, "hash", "config:*")
+	a.Nil(rec.Err())
+	a.Equal(map[string]string{"config:a": "1", "config:b": "22"}, rec.Val())
+	a.Equal(before+3, c.DebugStats(ctx).BytesRead)
+	a.Equal(map[string]string{}, c.HGetAllMatch(ctx, "nosuch", "*").Val())
+}
+
+func TestConcurrentOpen(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir(), ActiveExpire: true})
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.Open()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		a.Nil(err)
+	}
+	a.True(c.IsOpen())
+	a.Nil(c.Set(ctx, "key", "value", 0).Err())
+	a.Equal("value", c.Get(ctx, "key").Val())
+
+	// a failed Open leaves the database closed, even one that was open
+	file := t.TempDir() + "/file"
+	a.Nil(os.WriteFile(file, nil, 0660))
+	c.DBDir = file
+	a.NotNil(c.Open())
+	a.False(c.IsOpen())
+	a.ErrorIs(c.Get(ctx, "key").Err(), ErrClosed)
+}
+
+// TestOpenCloseConcurrent runs commands while the client is opened and closed, for go test -race to check
+func TestOpenCloseConcurrent(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir(), ActiveExpire: true})
+	a.Nil(c.Open())
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			key := "key" + strconv.Itoa(g)
+			for i := 0; i < 100; i++ {
+				if err := c.Set(ctx, key, "value", 0).Err(); err != nil {
+					a.ErrorIs(err, ErrClosed)
+				}
+				if err := c.Get(ctx, key).Err(); err != nil && !errors.Is(err, jkv.ErrKeyNotFound) {
+					a.ErrorIs(err, ErrClosed)
+				}
+				c.Keys(ctx, "*")
+				c.Info(ctx)
+			}
+		}(g)
+	}
+	for i := 0; i < 100; i++ {
+		c.Close()
+		a.Nil(c.Open())
+	}
+	wg.Wait()
+	a.True(c.IsOpen())
+	c.Close()
+	a.False(c.IsOpen())
+}
+
+func TestTTL(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := NewClient(&Options{Addr: t.TempDir(), Clock: clock})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "forever", "value", 0)
+	c.Set(ctx, "soon", "value", 90*time.Second)
+	c.HSet(ctx, "hash", "field", "value")
+	a.Equal(int64(-1), c.TTL(ctx, "forever").Val())
+	a.Equal(int64(90), c.TTL(ctx, "soon").Val())
+	a.Equal(int64(-1), c.TTL(ctx, "hash").Val())
+	a.Equal(int64(-2), c.TTL(ctx, "nosuch").Val())
+	a.Equal(int64(90), c.Do(ctx, "ttl", "soon").Val())
+
+	clock.Advance(89*time.Second + time.Millisecond)
+	a.Equal(int64(1), c.TTL(ctx, "soon").Val())
+	clock.Advance(time.Second)
+	a.Equal(int64(-2), c.TTL(ctx, "soon").Val())
+}
+
+func TestChecksum(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+
+	seed := func(naming FileNaming, reverse bool) *Client {
+		c := NewClient(&Options{Addr: t.TempDir(), FileNaming: naming})
+		a.Nil(c.Open())
+		t.Cleanup(c.Close)
+		keys := []string{"a", "b", "c"}
+		if reverse {
+			keys = []string{"c", "b", "a"}
+		}
+		for _, key := range keys {
+			c.Set(ctx, key, "value of "+key, 0)
+			c.HSet(ctx, "h"+key, "x", "1", "y", "2")
+		}
+		return c
+	}
+	one, two := seed(NamingPlain, false), seed(NamingEncoded, true)
+	sum := one.Checksum(ctx)
+	a.Nil(sum.Err())
+	a.Len(sum.Val(), 64)
+	a.Equal(sum.Val(), two.Checksum(ctx).Val())
+
+	// a value moved between keys or fields changes the checksum
+	two.Set(ctx, "a", "value of ", 0)
+	two.Set(ctx, "b", "avalue of b", 0)
+	a.NotEqual(sum.Val(), two.Checksum(ctx).Val())
+	two.Set(ctx, "a", "value of a", 0)
+	two.Set(ctx, "b", "value of b", 0)
+	a.Equal(sum.Val(), two.Checksum(ctx).Val())
+	two.HSet(ctx, "ha", "y", "3")
+	a.NotEqual(sum.Val(), two.Checksum(ctx).Val())
+
+	// an empty database has a checksum too
+	empty := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(empty.Open())
+	defer empty.Close()
+	a.Len(empty.Checksum(ctx).Val(), 64)
+	a.NotEqual(sum.Val(), empty.Checksum(ctx).Val())
+}
+
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	old, next := t.TempDir(), t.TempDir()+"/next"
+	c := NewClient(&Options{Addr: old})
+	a.Nil(c.Open())
+	defer c.Close()
+	a.Nil(c.Set(ctx, "key", "old", 0).Err())
+
+	// an unusable directory leaves the current one open
+	file := t.TempDir() + "/file"
+	a.Nil(os.WriteFile(file, nil, 0660))
+	a.NotNil(c.Reopen(ctx, file))
+	a.True(c.IsOpen())
+	a.Equal(old, c.GetDBDir())
+	a.Equal("old", c.Get(ctx, "key").Val())
+
+	a.Nil(c.Reopen(ctx, next))
+	a.True(c.IsOpen())
+	a.Equal(next, c.GetDBDir())
+	a.ErrorIs(c.Get(ctx, "key").Err(), jkv.Nil)
+	a.Nil(c.Set(ctx, "key", "new", 0).Err())
+	a.Equal("new", c.Get(ctx, "key").Val())
+
+	// the old database is untouched
+	data, err := os.ReadFile(old + "/scalars/key")
+	a.Nil(err)
+	a.Equal("old", string(data))
+
+	// a closed client is opened on the new directory
+	c.Close()
+	a.Nil(c.Reopen(ctx, old))
+	a.True(c.IsOpen())
+	a.Equal("old", c.Get(ctx, "key").Val())
+}
+
+// TestReopenConcurrent switches between two databases while other goroutines read, for go test -race to check
+func TestReopenConcurrent(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	dirs := []string{t.TempDir(), t.TempDir()}
+	for _, dir := range dirs {
+		c := NewClient(&Options{Addr: dir})
+		a.Nil(c.Open())
+		a.Nil(c.Set(ctx, "key", "value", 0).Err())
+		c.Close()
+	}
+	c := NewClient(&Options{Addr: dirs[0]})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				a.Equal("value", c.Get(ctx, "key").Val())
+				a.Equal(int64(1), c.Exists(ctx, "key").Val())
+				c.Keys(ctx, "*")
+				c.TTL(ctx, "key")
+				c.ConfigGet(ctx, "dir")
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		a.Nil(c.Reopen(ctx, dirs[i%2]))
+	}
+	wg.Wait()
+}
+
+func TestScanUnderWrites(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	stable := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("stable%03d", i)
+		stable[key] = true
+		c.Set(ctx, key, "value", 0)
+		c.Set(ctx, fmt.Sprintf("doomed%03d", i), "value", 0)
+	}
+
+	// between pages add keys before and after the cursor and remove others
+	seen := map[string]int{}
+	cursor, page := scanStart, 0
+	for {
+		rec := c.Scan(ctx, cursor, "*", 7)
+		a.Nil(rec.Err())
+		keys, next := rec.Val()
+		for _, key := range keys {
+			seen[key]++
+		}
+		if next == scanStart {
+			break
+		}
+		cursor = next
+		page++
+		c.Del(ctx, fmt.Sprintf("doomed%03d", page*3))
+		c.Set(ctx, fmt.Sprintf("added%03d", page), "value", 0)
+		c.Set(ctx, fmt.Sprintf("zadded%03d", page), "value", 0)
+		c.HSet(ctx, fmt.Sprintf("stable%03d-hash", page), "field", "value")
+	}
+	for key := range stable {
+		a.Equal(1, seen[key], key)
+	}
+	for key, n := range seen {
+		a.Equal(1, n, key)
+	}
+}
+
+func TestMGetStream(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	var keys []string
+	for i := 0; i < 100; i++ {
+		keys = append(keys, fmt.Sprintf("key%d", i))
+		if i%10 != 0 {
+			c.Set(ctx, keys[i], "value"+strconv.Itoa(i), 0)
+		}
+	}
+	keys = append(keys, "key1") // a key asked for twice gets two results
+
+	seen := make([]int, len(keys))
+	for res := range c.MGetStream(ctx, keys...) {
+		seen[res.Index]++
+		if n, _ := strconv.Atoi(strings.TrimPrefix(keys[res.Index], "key")); n%10 == 0 {
+			a.ErrorIs(res.Err, jkv.Nil)
+		} else {
+			a.Nil(res.Err)
+			a.Equal("value"+strconv.Itoa(n), res.Value)
+		}
+	}
+	for i, n := range seen {
+		a.Equal(1, n, keys[i])
+	}
+
+	// cancelling stops the stream and closes the channel
+	cancelled, cancel := context.WithCancel(ctx)
+	stream := c.MGetStream(cancelled, keys...)
+	<-stream
+	cancel()
+	n := 0
+	for range stream {
+		n++
+	}
+	a.Less(n, len(keys)-1)
+
+	c.Close()
+	for res := range c.MGetStream(ctx, "key1") {
+		a.Equal(0, res.Index)
+		a.NotNil(res.Err)
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+
+	a.ErrorIs(c.Get(ctx, "key").Err(), ErrNotOpen)
+	a.ErrorIs(c.FlushDB(ctx).Err(), ErrNotOpen)
+	c.Close() // closing a client that was never opened does nothing
+	a.ErrorIs(c.Set(ctx, "key", "value", 0).Err(), ErrNotOpen)
+
+	a.Nil(c.Open())
+	a.Nil(c.Set(ctx, "key", "value", 0).Err())
+	c.Close()
+	c.Close()
+	a.False(c.IsOpen())
+	a.ErrorIs(c.Get(ctx, "key").Err(), ErrClosed)
+	a.ErrorIs(c.Set(ctx, "key", "value", 0).Err(), ErrClosed)
+	a.ErrorIs(c.HKeys(ctx, "hash").Err(), ErrClosed)
+	a.ErrorIs(c.FlushDB(ctx).Err(), ErrClosed)
+
+	// opening it again works as before
+	a.Nil(c.Open())
+	a.Equal("value", c.Get(ctx, "key").Val())
+}
+
+func TestCompressMinBytes(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir(), CompressMinBytes: 100})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	small, large := "short", strings.Repeat("a large and repetitive value ", 100)
+	a.Nil(c.Set(ctx, "small", small, 0).Err())
+	a.Nil(c.Set(ctx, "large", large, 0).Err())
+	a.Nil(c.HSet(ctx, "hash", "large", large).Err())
+
+	data, _ := os.ReadFile(c.scalarPath("small"))
+	a.Equal(small, string(data))
+	data, _ = os.ReadFile(c.scalarPath("large"))
+	a.True(strings.HasPrefix(string(data), compressMagic))
+	a.Less(len(data), len(large))
+	a.Equal(EncodingRaw, c.Encoding(ctx, "small").Val())
+	a.Equal(EncodingGzip, c.Encoding(ctx, "large").Val())
+
+	a.Equal(small, c.Get(ctx, "small").Val())
+	a.Equal(large, c.Get(ctx, "large").Val())
+	a.Equal(large, c.HGet(ctx, "hash", "large").Val())
+	var b bytes.Buffer
+	rec := c.GetTo(ctx, "large", &b)
+	a.Nil(rec.Err())
+	a.Equal(int64(len(large)), rec.Val())
+	a.Equal(large, b.String())
+
+	// a small value that looks compressed is compressed so it reads back as it was
+	tricky := compressMagic + "not gzip"
+	a.Nil(c.Set(ctx, "tricky", tricky, 0).Err())
+	a.Equal(tricky, c.Get(ctx, "tricky").Val())
+
+	// bits are set on the plain value
+	a.Equal(int64(0), c.GetBit(ctx, "large", 0).Val())
+	a.Equal(int64(1), c.GetBit(ctx, "large", 1).Val())
+	a.Equal(int64(0), c.SetBit(ctx, "large", 0, 1).Val())
+	a.Equal("\xe1"+large[1:], c.Get(ctx, "large").Val())
+
+	// a client that doesn't compress reads compressed values
+	plain := NewClient(&Options{Addr: c.DBDir})
+	a.Nil(plain.Open())
+	defer plain.Close()
+	a.Equal(large, plain.HGet(ctx, "hash", "large").Val())
+}
+
+func TestDefaultTTL(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	c := NewClient(&Options{Addr: t.TempDir(), Clock: clock, DefaultTTL: time.Minute})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	a.Nil(c.Set(ctx, "cached", "value", 0).Err())
+	a.Nil(c.Set(ctx, "short", "value", 10*time.Second).Err())
+	a.Nil(c.Set(ctx, "persisted", "value", jkv.NoExpiration).Err())
+	a.True(c.SetNX(ctx, "nx", "value", 0).Val())
+	a.Nil(c.HSet(ctx, "hash", "field", "value").Err())
+	a.Equal(int64(60), c.TTL(ctx, "cached").Val())
+	a.Equal(int64(10), c.TTL(ctx, "short").Val())
+	a.Equal(int64(-1), c.TTL(ctx, "persisted").Val())
+	a.Equal(int64(60), c.TTL(ctx, "hash").Val())
+
+	// KEEPTTL leaves the deadline where it was, PERSIST clears it
+	clock.Advance(30 * time.Second)
+	a.Nil(c.Set(ctx, "short", "kept", jkv.KeepTTL).Err())
+	a.Nil(c.Do(ctx, "SET", "nx", "value", "PERSIST").Err())
+	a.Equal(int64(-2), c.TTL(ctx, "short").Val())
+	a.Nil(c.Do(ctx, "SET", "cached", "value", "KEEPTTL").Err())
+	a.Equal(int64(30), c.TTL(ctx, "cached").Val())
+	a.Equal(int64(-1), c.TTL(ctx, "nx").Val())
+
+	clock.Advance(time.Minute)
+	a.ErrorIs(c.Get(ctx, "cached").Err(), jkv.ErrKeyNotFound)
+	a.ErrorIs(c.HGet(ctx, "hash", "field").Err(), jkv.ErrKeyNotFound)
+	a.Equal("value", c.Get(ctx, "persisted").Val())
+	a.Equal("value", c.Get(ctx, "nx").Val())
+}
+
+func TestHAppend(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	a.Equal(int64(5), c.HAppend(ctx, "log", "user", "hello").Val())
+	a.Equal(int64(11), c.HAppend(ctx, "log", "user", " world").Val())
+	a.Equal("hello world", c.HGet(ctx, "log", "user").Val())
+	c.Set(ctx, "scalar", "value", 0)
+	a.ErrorIs(c.HAppend(ctx, "scalar", "field", "value").Err(), jkv.ErrWrongType)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.HAppend(ctx, "hash", "field", "x")
+		}()
+	}
+	wg.Wait()
+	a.Equal(strings.Repeat("x", 50), c.HGet(ctx, "hash", "field").Val())
+}
+
+func TestEINTR(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+	a.Nil(c.Set(ctx, "key", "value", 0).Err())
+
+	// each call fails with EINTR the first time round, then goes through
+	interrupt := func(interrupted *int) error {
+		if *interrupted++; *interrupted == 1 {
+			return &os.PathError{Op: "open", Err: syscall.EINTR}
+		}
+		return nil
+	}
+	var reads, dirs, writes, creates int
+	readFile, readDir, writeFile, createTemp := sysReadFile, sysReadDir, sysWriteFile, sysCreateTemp
+	defer func() {
+		sysReadFile, sysReadDir, sysWriteFile, sysCreateTemp = readFile, readDir, writeFile, createTemp
+	}()
+	sysReadFile = func(name string) ([]byte, error) {
+		if err := interrupt(&reads); err != nil {
+			return nil, err
+		}
+		return os.ReadFile(name)
+	}
+	sysReadDir = func(name string) ([]os.DirEntry, error) {
+		if err := interrupt(&dirs); err != nil {
+			return nil, err
+		}
+		return os.ReadDir(name)
+	}
+	sysWriteFile = func(name string, data []byte, perm os.FileMode) error {
+		if err := interrupt(&writes); err != nil {
+			return err
+		}
+		return os.WriteFile(name, data, perm)
+	}
+	sysCreateTemp = func(dir, pattern string) (*os.File, error) {
+		if err := interrupt(&creates); err != nil {
+			return nil, err
+		}
+		return os.CreateTemp(dir, pattern)
+	}
+
+	a.Equal("value", c.Get(ctx, "key").Val())
+	a.Equal([]string{"key"}, c.Keys(ctx, "*").Val())
+	// the value goes through a temporary file, the deadline is written directly
+	a.Nil(c.Set(ctx, "other", "value", time.Hour).Err())
+	a.Less(1, reads)
+	a.Equal(2, creates)
+	a.Equal(2, writes)
+	a.Less(1, dirs)
+
+	// a call that keeps failing gives up
+	sysReadFile = func(name string) ([]byte, error) { return nil, &os.PathError{Op: "open", Err: syscall.EINTR} }
+	a.ErrorIs(c.Get(ctx, "key").Err(), syscall.EINTR)
+}
+
+func TestTypes(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	c := NewClient(&Options{Addr: t.TempDir(), Clock: clock})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	a.Equal(map[string]int64{}, c.Types(ctx).Val())
+	for _, key := range []string{"one", "two", "three"} {
+		c.Set(ctx, key, "value", 0)
+	}
+	c.Set(ctx, "expiring", "value", time.Second)
+	c.HSet(ctx, "h1", "field", "value")
+	c.HSet(ctx, "h2", "field", "value", "other", "value")
+	a.Equal(map[string]int64{"string": 4, "hash": 2}, c.Types(ctx).Val())
+	clock.Advance(time.Second)
+	a.Equal(map[string]int64{"string": 3, "hash": 2}, c.Types(ctx).Val())
+}
+
+func TestExpire(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	c := NewClient(&Options{Addr: t.TempDir(), Clock: clock})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "key", "value", 0)
+	c.HSet(ctx, "hash", "field", "value")
+	a.True(c.Expire(ctx, "key", time.Minute).Val())
+	a.Equal(int64(1), c.Do(ctx, "EXPIRE", "hash", 30).Val())
+	a.Equal(int64(0), c.Do(ctx, "EXPIRE", "missing", 30).Val())
+	a.Equal(int64(60), c.TTL(ctx, "key").Val())
+	a.Equal(int64(30), c.TTL(ctx, "hash").Val())
+
+	clock.Advance(30 * time.Second)
+	a.ErrorIs(c.HGet(ctx, "hash", "field").Err(), jkv.ErrKeyNotFound)
+	a.True(c.Expire(ctx, "key", 0).Val())
+	a.ErrorIs(c.Get(ctx, "key").Err(), jkv.ErrKeyNotFound)
+}
+
+func TestHandleCache(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir(), HandleCacheSize: 2})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "one", "1", 0)
+	c.Set(ctx, "two", "2", 0)
+	c.Set(ctx, "three", "3", 0)
+	for _, key := range []string{"one", "two", "three", "one"} {
+		a.Equal(key == "one", c.Get(ctx, key).Val() == "1")
+	}
+	a.Equal(2, c.handles.lru.Len())
+
+	// a handle sees a value rewritten, a shorter one too, and a file replaced by a rename behind its back
+	c.Set(ctx, "one", "a longer value", 0)
+	a.Equal("a longer value", c.Get(ctx, "one").Val())
+	c.Set(ctx, "one", "x", 0)
+	a.Equal("x", c.Get(ctx, "one").Val())
+	os.WriteFile(c.scalarPath("replaced"), []byte("new"), 0660)
+	a.Nil(os.Rename(c.scalarPath("replaced"), c.scalarPath("one")))
+	a.Equal("new", c.Get(ctx, "one").Val())
+	c.Del(ctx, "one")
+	a.ErrorIs(c.Get(ctx, "one").Err(), jkv.ErrKeyNotFound)
+
+	c.Close()
+	a.Equal(0, c.handles.lru.Len())
+}
+
+func benchmarkGet(b *testing.B, handles int) {
+	ctx := context.Background()
+	c := NewClient(&Options{Addr: b.TempDir(), HandleCacheSize: handles})
+	c.Open()
+	defer c.Close()
+	c.Set(ctx, "key", "value", 0)
+
+	var opens int64
+	open, readFile := sysOpen, sysReadFile
+	defer func() { sysOpen, sysReadFile = open, readFile }()
+	sysOpen = func(name string) (*os.File, error) {
+		opens++
+		return os.Open(name)
+	}
+	sysReadFile = func(name string) ([]byte, error) {
+		if strings.HasPrefix(name, c.ScalarDir()) {
+			opens++
+		}
+		return os.ReadFile(name)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		c.Get(ctx, "key")
+	}
+	b.ReportMetric(float64(opens)/float64(b.N), "opens/op")
+}
+
+func BenchmarkGet(b *testing.B)            { benchmarkGet(b, 0) }
+func BenchmarkGetHandleCache(b *testing.B) { benchmarkGet(b, 16) }
+
+func TestDelMany(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	c := NewClient(&Options{Addr: t.TempDir(), Clock: clock})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "a", "1", 0)
+	c.Set(ctx, "c", "3", 0)
+	c.Set(ctx, "expired", "4", time.Second)
+	clock.Advance(time.Second)
+	rec := c.Del(ctx, "a", "b", "c", "expired")
+	a.Nil(rec.Err())
+	a.Equal(int64(2), rec.Val())
+	a.Equal(int64(0), c.Exists(ctx, "a", "c").Val())
+
+	// a file that can't be removed is an error, the keys before it are still counted
+	c.Set(ctx, "a", "1", 0)
+	a.Nil(os.Mkdir(c.scalarPath("stuck"), 0775))
+	a.Nil(os.WriteFile(c.scalarPath("stuck")+"/file", nil, 0664))
+	rec = c.Del(ctx, "a", "stuck")
+	a.Error(rec.Err())
+	a.Equal(int64(1), rec.Val())
+}
+
+func TestKeysPattern(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir(), FileNaming: NamingEncoded})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "user:1", "a", 0)
+	c.Set(ctx, "user:2", "b", 0)
+	c.Set(ctx, "path/to", "c", 0)
+	c.HSet(ctx, "user:h", "field", "value")
+	c.HSet(ctx, "other", "field", "value")
+
+	a.Equal([]string{"other", "path/to", "user:1", "user:2", "user:h"}, c.Keys(ctx, "*").Val())
+	a.Equal([]string{"user:1", "user:2", "user:h"}, c.Keys(ctx, "user:*").Val())
+	a.Equal([]string{"user:1", "user:2"}, c.Keys(ctx, "user:[0-9]").Val())
+	a.Equal([]string{"user:h"}, c.Keys(ctx, "user:[a-z]").Val())
+	a.Equal([]string{"path/to"}, c.Keys(ctx, "p*o").Val())
+	rec := c.Keys(ctx, "nothing*")
+	a.Nil(rec.Err())
+	a.Equal([]string{}, rec.Val())
+
+	rec = c.Keys(ctx, "user:[")
+	a.ErrorIs(rec.Err(), filepath.ErrBadPattern)
+	a.Empty(rec.Val())
+}
+
+func TestHGetAll(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.HSet(ctx, "hash", "one", "1", "two", "2")
+	rec := c.HGetAll(ctx, "hash")
+	a.Nil(rec.Err())
+	a.Equal(map[string]string{"one": "1", "two": "2"}, rec.Val())
+	rec = c.HGetAll(ctx, "missing")
+	a.Nil(rec.Err())
+	a.Empty(rec.Val())
+}
+
+func TestSwap(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := NewClient(&Options{Addr: t.TempDir(), Clock: clock})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	c.Set(ctx, "short", "lives a minute", time.Minute)
+	c.Set(ctx, "forever", "never expires", 0)
+	c.SetWithMeta(ctx, "typed", "{}", map[string]string{"content-type": "application/json"})
+	a.Equal("OK", c.Swap(ctx, "short", "forever").Val())
+	a.Equal("never expires", c.Get(ctx, "short").Val())
+	a.Equal("lives a minute", c.Get(ctx, "forever").Val())
+	a.Equal(int64(-1), c.TTL(ctx, "short").Val())
+	a.Equal(int64(60), c.TTL(ctx, "forever").Val())
+
+	a.Nil(c.Swap(ctx, "short", "typed").Err())
+	_, meta, err := c.GetWithMeta(ctx, "short")
+	a.Nil(err)
+	a.Equal("application/json", meta["content-type"])
+	a.Equal([]string{"forever", "short", "typed"}, c.Keys(ctx, "*").Val())
+
+	clock.Advance(time.Minute)
+	a.ErrorIs(c.Swap(ctx, "forever", "short").Err(), jkv.ErrNoKey)
+	c.HSet(ctx, "hash", "field", "value")
+	a.ErrorIs(c.Swap(ctx, "short", "hash").Err(), jkv.ErrWrongType)
+	a.Equal("{}", c.Get(ctx, "short").Val())
+}
+
+func TestHLoadFile(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	old := []string{}
+	var tsv strings.Builder
+	for i := 0; i < 1000; i++ {
+		old = append(old, fmt.Sprintf("field%d", i), "old")
+		fmt.Fprintf(&tsv, "field%d\tnew\n", i)
+	}
+	a.Nil(c.HSet(ctx, "hash", old...).Err())
+	c.HSet(ctx, "hash", "stale", "old")
+	c.HExpire(ctx, "hash", 60, "field0")
+	path := filepath.Join(t.TempDir(), "hash.tsv")
+	a.Nil(os.WriteFile(path, []byte(tsv.String()), 0644))
+
+	// a reader running through the load sees 1001 old fields or 1000 new ones, never some of each
+	done := make(chan struct{})
+	mixed := make(chan string, 1)
+	go func() {
+		defer close(mixed)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			counts := map[string]int{}
+			for _, value := range c.HGetAll(ctx, "hash").Val() {
+				counts[value]++
+			}
+			if counts["new"] > 0 && (counts["old"] > 0 || counts["new"] != 1000) || counts["old"] > 0 && counts["old"] != 1001 {
+				mixed <- fmt.Sprintf("%d old and %d new fields", counts["old"], counts["new"])
+				return
+			}
+		}
+	}()
+	rec := c.HLoadFile(ctx, "hash", path)
+	close(done)
+	a.Nil(rec.Err())
+	a.Equal(int64(1000), rec.Val())
+	a.Empty(<-mixed)
+
+	values := c.HGetAll(ctx, "hash").Val()
+	a.Len(values, 1000)
+	a.Equal("new", values["field999"])
+	a.Equal([]int64{-1}, c.HTTL(ctx, "hash", "field0").Val())
+	entries, _ := os.ReadDir(c.GetDBDir())
+	for _, entry := range entries {
+		a.False(strings.HasPrefix(entry.Name(), ".hload-"), entry.Name())
+	}
+
+	path = filepath.Join(t.TempDir(), "hash.json")
+	a.Nil(os.WriteFile(path, []byte(`{"one": "1", "two": "2"}`), 0644))
+	a.Equal(int64(2), c.HLoadFile(ctx, "json", path).Val())
+	a.Equal(map[string]string{"one": "1", "two": "2"}, c.HGetAll(ctx, "json").Val())
+
+	a.Nil(os.WriteFile(path, []byte(`{}`), 0644))
+	a.Equal(int64(0), c.HLoadFile(ctx, "json", path).Val())
+	a.Equal("none", c.keyType("json"))
+
+	a.Nil(os.WriteFile(path, []byte("one\t1\ntwo 2\n"), 0644))
+	a.Nil(os.Rename(path, path+".tsv"))
+	a.ErrorContains(c.HLoadFile(ctx, "hash", path+".tsv").Err(), "line 2: no tab between field and value")
+	a.Len(c.HGetAll(ctx, "hash").Val(), 1000)
+	c.Set(ctx, "scalar", "value", 0)
+	a.ErrorIs(c.HLoadFile(ctx, "scalar", filepath.Join(t.TempDir(), "missing")).Err(), os.ErrNotExist)
+	a.Nil(os.WriteFile(path, []byte(`{"one": "1"}`), 0644))
+	a.ErrorIs(c.HLoadFile(ctx, "scalar", path).Err(), jkv.ErrWrongType)
+}
+
+func TestExistsMany(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.ErrorIs(c.Exists(ctx, "a").Err(), ErrNotOpen)
+	a.Nil(c.Open())
+
+	c.Set(ctx, "a", "1", 0)
+	c.Set(ctx, "b", "2", 0)
+	a.Equal(int64(2), c.Exists(ctx, "a", "b", "c").Val())
+	a.Equal(int64(3), c.Exists(ctx, "a", "b", "a").Val())
+	a.Equal(int64(0), c.Exists(ctx, "c", "c").Val())
+
+	// an expired key doesn't count whether the keys are looked up one by one or in a listing
+	c.Set(ctx, "gone", "3", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	a.Equal(int64(0), c.existsStat([]string{"gone"}))
+	n, err := c.existsListed([]string{"a", "gone", "b"})
+	a.Nil(err)
+	a.Equal(int64(2), n)
+	a.Equal(int64(0), c.Exists(ctx, "gone").Val())
+	a.Equal(int64(2), c.Exists(ctx, "a", "gone", "b").Val())
+	a.Equal(int64(2), c.Exists(ctx, "a", "b", "gone", "gone").Val())
+
+	c.Close()
+	rec := c.Exists(ctx, "a")
+	a.ErrorIs(rec.Err(), ErrClosed)
+	a.Equal(int64(0), rec.Val())
+}
+
+func TestGetMmap(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	value := strings.Repeat("0123456789abcdef", 1<<18) // 4 MiB
+	c.Set(ctx, "large", value, 0)
+	data, err := c.GetBytes(ctx, "large")
+	a.Nil(err)
+	a.Equal(value, string(data))
+
+	m, err := c.GetMmap(ctx, "large")
+	a.Nil(err)
+	a.Equal(value, string(m.Bytes()))
+	// where there is /proc/self/maps it lists the mappings of the process by file
+	mapped := func() bool {
+		maps, err := os.ReadFile("/proc/self/maps")
+		return err == nil && strings.Contains(string(maps), c.scalarPath("large"))
+	}
+	_, err = os.Stat("/proc/self/maps")
+	procMaps := err == nil
+	a.Equal(procMaps, mapped())
+	a.Nil(m.Release())
+	a.Nil(m.Bytes())
+	a.False(mapped())
+	a.Nil(m.Release())
+
+	// writing the key while it is mapped leaves the mapping on the old value
+	m, err = c.GetMmap(ctx, "large")
+	a.Nil(err)
+	a.Nil(c.Set(ctx, "large", "short", 0).Err())
+	a.Equal(value, string(m.Bytes()))
+	a.Equal("short", c.Get(ctx, "large").Val())
+	a.Nil(m.Release())
+
+	c.CompressMinBytes = 1024
+	c.Set(ctx, "compressed", value, 0)
+	m, err = c.GetMmap(ctx, "compressed")
+	a.Nil(err)
+	a.Equal(value, string(m.Bytes()))
+	a.Nil(m.Release())
+
+	c.Set(ctx, "empty", "", 0)
+	m, err = c.GetMmap(ctx, "empty")
+	a.Nil(err)
+	a.Equal([]byte{}, m.Bytes())
+	_, err = c.GetMmap(ctx, "missing")
+	a.ErrorIs(err, jkv.ErrKeyNotFound)
+}
+
+func TestIncr(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: t.TempDir()})
+	a.Nil(c.Open())
+	defer c.Close()
+
+	rec := c.Incr(ctx, "counter")
+	a.Nil(rec.Err())
+	a.Equal(int64(1), rec.Val())
+	a.Equal(int64(-1), c.Decr(ctx, "fresh").Val())
+
+	c.Set(ctx, "existing", "41", time.Minute)
+	a.Equal(int64(42), c.Incr(ctx, "existing").Val())
+	a.Equal(int64(41), c.Decr(ctx, "existing").Val())
+	a.Equal("41", c.Get(ctx, "existing").Val())
+	a.Equal(int64(60), c.TTL(ctx, "existing").Val())
+
+	c.Set(ctx, "text", "forty two", 0)
+	a.ErrorIs(c.Incr(ctx, "text").Err(), jkv.ErrNotInteger)
+	a.Equal("forty two", c.Get(ctx, "text").Val())
+	c.Set(ctx, "max", strconv.FormatInt(math.MaxInt64, 10), 0)
+	a.ErrorIs(c.Incr(ctx, "max").Err(), jkv.ErrOverflow)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Incr(ctx, "shared")
+		}()
+	}
+	wg.Wait()
+	a.Equal("50", c.Get(ctx, "shared").Val())
+}
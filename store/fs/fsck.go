@@ -0,0 +1,48 @@
+package fs
+
+import (
+	"context"
+	"os"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// LostDir holds entries FSCK moved out of the way
+func (c *Client) LostDir() string { return c.GetDBDir() + "/lost+found/" }
+
+// FSCK reports entries that don't belong in the store, e.g. a file left where a hash directory should be after a
+// crash. With repair they are moved to LostDir so they can be inspected, which a read only client can't do.
+func (c *Client) Fsck(ctx context.Context, repair bool) ([]string, error) {
+	if !c.IsOpen() {
+		return nil, c.notOpen()
+	}
+	if repair && c.readOnly() {
+		return nil, jkv.ErrReadOnly
+	}
+	c.lock()
+	defer c.unlock()
+	if repair {
+		c.audit(ctx, "FSCK")
+	}
+
+	entries, err := readDir(c.HashDir())
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		problems = append(problems, c.HashDir()+entry.Name()+" is not a directory")
+		if repair {
+			if err := os.MkdirAll(c.LostDir(), 0775); err != nil {
+				return problems, err
+			}
+			if err := os.Rename(c.HashDir()+entry.Name(), c.LostDir()+entry.Name()); err != nil {
+				return problems, err
+			}
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,132 @@
+package fs
+
+import (
+	linked "container/list"
+	"io"
+	"os"
+	"sync"
+)
+
+// handleCache keeps up to size value files open for reading, dropping the least recently used, so repeated reads of
+// a hot key don't open and close its file each time. Writes replace a file by renaming a new one over it, and a file
+// may also be removed or rewritten by another process: each read stats the path and opens it afresh if it is no
+// longer the file the handle has open. writeFile drops the handle of the path it writes.
+type handleCache struct {
+	mu      sync.Mutex
+	size    int
+	lru     *linked.List // of *handle, the most recently used at the front
+	handles map[string]*linked.Element
+}
+
+// handle is an open file, closed once it has left the cache and no read is using it
+type handle struct {
+	path    string
+	f       *os.File
+	info    os.FileInfo // of f when it was opened, to tell if path still names it
+	refs    int
+	dropped bool
+}
+
+func newHandleCache(size int) *handleCache {
+	return &handleCache{size: size, lru: linked.New(), handles: map[string]*linked.Element{}}
+}
+
+// read returns the contents of the file at path through a cached handle
+func (hc *handleCache) read(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		hc.drop(path)
+		return nil, err
+	}
+	h, err := hc.acquire(path, info)
+	if err != nil {
+		return nil, err
+	}
+	defer hc.release(h)
+
+	// the size now, not when it was opened, as a write may have changed it since
+	if info, err = h.f.Stat(); err != nil {
+		return nil, err
+	}
+	data := make([]byte, info.Size())
+	n, err := h.f.ReadAt(data, 0)
+	if err == io.EOF {
+		err = nil // truncated by a write since the Stat
+	}
+	return data[:n], err
+}
+
+// acquire returns a handle on the file at path described by info, opening it if there is no cached handle on it
+func (hc *handleCache) acquire(path string, info os.FileInfo) (*handle, error) {
+	hc.mu.Lock()
+	if e, ok := hc.handles[path]; ok {
+		h := e.Value.(*handle)
+		if os.SameFile(h.info, info) {
+			hc.lru.MoveToFront(e)
+			h.refs++
+			hc.mu.Unlock()
+			return h, nil
+		}
+		hc.remove(e)
+	}
+	hc.mu.Unlock()
+
+	f, err := retryEINTR(func() (*os.File, error) { return sysOpen(path) })
+	if err != nil {
+		return nil, err
+	}
+	if info, err = f.Stat(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	h := &handle{path: path, f: f, info: info, refs: 1}
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if _, ok := hc.handles[path]; ok {
+		h.dropped = true // another read cached one first, this one is closed when released
+		return h, nil
+	}
+	hc.handles[path] = hc.lru.PushFront(h)
+	for hc.lru.Len() > hc.size {
+		hc.remove(hc.lru.Back())
+	}
+	return h, nil
+}
+
+// release is called when a read is done with h
+func (hc *handleCache) release(h *handle) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if h.refs--; h.refs == 0 && h.dropped {
+		h.f.Close()
+	}
+}
+
+// drop removes the handle on path from the cache
+func (hc *handleCache) drop(path string) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if e, ok := hc.handles[path]; ok {
+		hc.remove(e)
+	}
+}
+
+// purge removes every handle from the cache
+func (hc *handleCache) purge() {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	for hc.lru.Len() > 0 {
+		hc.remove(hc.lru.Front())
+	}
+}
+
+// remove takes e out of the cache and closes its file unless a read is using it, mu must be held
+func (hc *handleCache) remove(e *linked.Element) {
+	h := hc.lru.Remove(e).(*handle)
+	delete(hc.handles, h.path)
+	h.dropped = true
+	if h.refs == 0 {
+		h.f.Close()
+	}
+}
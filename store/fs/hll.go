@@ -0,0 +1,184 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"os"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// A HyperLogLog is kept as a scalar holding hllMagic followed by one byte per register. With 2^14 registers the
+// standard error of a count is 1.04/sqrt(2^14), about 0.81%, the same as in Redis.
+const (
+	hllMagic     = "JKVHLL1\n"
+	hllPrecision = 14
+	hllRegisters = 1 << hllPrecision
+)
+
+// hllHash hashes an element with FNV-1a followed by the murmur3 finalizer, which spreads its bits out enough for the
+// leading bits to pick a register and the rest to count zeros in
+func hllHash(element string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(element))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// hllAdd records element in registers and returns true if a register changed
+func hllAdd(registers []byte, element string) bool {
+	h := hllHash(element)
+	index := h & (hllRegisters - 1)
+	// the bits left over after the index, with a sentinel so the count stops at 64-hllPrecision+1
+	rank := byte(bits.TrailingZeros64(h>>hllPrecision|1<<(64-hllPrecision)) + 1)
+	if rank > registers[index] {
+		registers[index] = rank
+		return true
+	}
+	return false
+}
+
+// hllEstimate returns the number of distinct elements recorded in registers
+func hllEstimate(registers []byte) int64 {
+	m := float64(hllRegisters)
+	sum, zeros := 0.0, 0
+	for _, r := range registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// linear counting is more accurate while many registers are still empty
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(estimate + 0.5)
+}
+
+// readHLL returns the registers of the HyperLogLog in key, nil if key doesn't exist, or jkv.ErrWrongType if it holds
+// something else
+func (c *Client) readHLL(key string) ([]byte, error) {
+	if c.hasExpired(key) {
+		return nil, nil
+	}
+	if _, err := os.Stat(c.hashPath(key)); err == nil {
+		return nil, jkv.ErrWrongType
+	}
+	data, err := c.readFile(c.scalarPath(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(data) != len(hllMagic)+hllRegisters || !bytes.HasPrefix(data, []byte(hllMagic)) {
+		return nil, jkv.ErrWrongType
+	}
+	return data[len(hllMagic):], nil
+}
+
+func (c *Client) writeHLL(key string, registers []byte) error {
+	return c.writeFile(c.scalarPath(key), append([]byte(hllMagic), registers...), 0660)
+}
+
+// PFADD adds elements to the HyperLogLog in key, creating it if needed, and returns 1 if its estimate may have
+// changed, 0 otherwise
+func (c *Client) PFAdd(ctx context.Context, key string, elements ...string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewIntCmd(0, jkv.ErrReadOnly)
+		}
+		if err := c.checkNames(key); err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "PFADD", key)
+		c.expire(key)
+		registers, err := c.readHLL(key)
+		if err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		changed := registers == nil
+		if changed {
+			registers = make([]byte, hllRegisters)
+		}
+		for _, element := range elements {
+			if hllAdd(registers, element) {
+				changed = true
+			}
+		}
+		if !changed {
+			return jkv.NewIntCmd(0, nil)
+		}
+		return jkv.NewIntCmd(1, c.writeHLL(key, registers))
+	}
+	return jkv.NewIntCmd(0, c.notOpen())
+}
+
+// union returns the registers of the union of the HyperLogLogs in keys, missing keys count as empty
+func (c *Client) union(keys ...string) ([]byte, error) {
+	union := make([]byte, hllRegisters)
+	for _, key := range keys {
+		registers, err := c.readHLL(key)
+		if err != nil {
+			return nil, err
+		}
+		for i, r := range registers {
+			if r > union[i] {
+				union[i] = r
+			}
+		}
+	}
+	return union, nil
+}
+
+// PFCOUNT returns the approximate number of distinct elements added to the HyperLogLogs in keys
+func (c *Client) PFCount(ctx context.Context, keys ...string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.auditRead(ctx, "PFCOUNT", keys...)
+		c.settle()
+		union, err := c.union(keys...)
+		if err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		return jkv.NewIntCmd(hllEstimate(union), nil)
+	}
+	return jkv.NewIntCmd(0, c.notOpen())
+}
+
+// PFMERGE stores the union of dest and the HyperLogLogs in keys in dest
+func (c *Client) PFMerge(ctx context.Context, dest string, keys ...string) (res *jkv.StatusCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewStatusCmd("", jkv.ErrReadOnly)
+		}
+		if err := c.checkNames(dest); err != nil {
+			return jkv.NewStatusCmd("", err)
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "PFMERGE", append([]string{dest}, keys...)...)
+		c.expire(dest)
+		union, err := c.union(append([]string{dest}, keys...)...)
+		if err != nil {
+			return jkv.NewStatusCmd("", err)
+		}
+		if err := c.writeHLL(dest, union); err != nil {
+			return jkv.NewStatusCmd("", err)
+		}
+		return jkv.NewStatusCmd("OK", nil)
+	}
+	return jkv.NewStatusCmd("", c.notOpen())
+}
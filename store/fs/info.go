@@ -0,0 +1,75 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// infoSections are the sections of INFO in the order they are printed
+var infoSections = []string{"server", "stats", "commandstats"}
+
+// INFO returns the server, stats and commandstats sections in the format of Redis, or only the named sections.
+// Commands are counted as they run, INFO itself isn't.
+func (c *Client) Info(ctx context.Context, sections ...string) (res *jkv.StringCmd) {
+	defer timed(c.start(), &res)
+	if !c.IsOpen() {
+		return jkv.NewStringCmd("", c.notOpen())
+	}
+	all, wanted := len(sections) == 0, map[string]bool{}
+	for _, section := range sections {
+		switch section = strings.ToLower(section); section {
+		case "all", "everything", "default":
+			all = true
+		default:
+			wanted[section] = true
+		}
+	}
+
+	stats := c.DebugStats(ctx)
+	var b strings.Builder
+	for _, section := range infoSections {
+		if !all && !wanted[section] {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		switch section {
+		case "server":
+			b.WriteString("# Server\r\n")
+			fmt.Fprintf(&b, "jkv_version:%s\r\n", jkv.BuildVersion())
+			fmt.Fprintf(&b, "backend:fs\r\n")
+			fmt.Fprintf(&b, "dir:%s\r\n", c.GetDBDir())
+			c.openMu.Lock()
+			opened := c.opened
+			c.openMu.Unlock()
+			fmt.Fprintf(&b, "uptime_in_seconds:%d\r\n", int64(c.Clock.Now().Sub(opened).Seconds()))
+		case "stats":
+			total := int64(0)
+			for _, n := range stats.Commands {
+				total += n
+			}
+			b.WriteString("# Stats\r\n")
+			fmt.Fprintf(&b, "total_commands_processed:%d\r\n", total)
+			fmt.Fprintf(&b, "total_bytes_read:%d\r\n", stats.BytesRead)
+			fmt.Fprintf(&b, "total_bytes_written:%d\r\n", stats.BytesWritten)
+			fmt.Fprintf(&b, "lock_waits:%d\r\n", stats.LockWaits)
+			fmt.Fprintf(&b, "fsyncs:%d\r\n", stats.Fsyncs)
+		case "commandstats":
+			b.WriteString("# Commandstats\r\n")
+			ops := make([]string, 0, len(stats.Commands))
+			for op := range stats.Commands {
+				ops = append(ops, op)
+			}
+			sort.Strings(ops)
+			for _, op := range ops {
+				fmt.Fprintf(&b, "cmdstat_%s:calls=%d\r\n", strings.ToLower(op), stats.Commands[op])
+			}
+		}
+	}
+	return jkv.NewStringCmd(b.String(), nil)
+}
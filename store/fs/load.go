@@ -0,0 +1,124 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// HLoadFile replaces hash with the field/value pairs in the file at path, returning the number of fields loaded. A
+// file named .json holds a JSON object of strings, any other holds a line of field<TAB>value per field. The fields
+// are written to a directory kept out of the hash directory, which is then renamed into place, so HGETALL sees the
+// old hash or the new one and never part of each. Any expiration of the old hash and its fields goes with it.
+func (c *Client) HLoadFile(ctx context.Context, hash, path string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewIntCmd(0, jkv.ErrReadOnly)
+		}
+		data, err := readRaw(path)
+		if err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		var fields map[string]string
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			err = json.Unmarshal(data, &fields)
+		} else {
+			fields, err = parseTSV(string(data))
+		}
+		if err != nil {
+			return jkv.NewIntCmd(0, fmt.Errorf("ERR %s: %w", path, err))
+		}
+		names := []string{hash}
+		for field := range fields {
+			names = append(names, field)
+		}
+		if err := c.checkNames(names...); err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "HLOADFILE", hash)
+		if c.existsStat([]string{hash}) > 0 {
+			return jkv.NewIntCmd(0, jkv.ErrWrongType)
+		}
+		staged, err := c.stageHash(fields)
+		if err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		defer os.RemoveAll(staged)
+		defer os.RemoveAll(staged + ".old")
+		if err := c.swapHash(hash, staged); err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		c.clearDeadline(hash)
+		if len(fields) == 0 && !c.keepEmptyHashes() {
+			os.Remove(c.hashPath(hash))
+			return jkv.NewIntCmd(0, nil)
+		}
+		if c.DefaultTTL > 0 {
+			return jkv.NewIntCmd(int64(len(fields)), c.setDeadline(hash, c.Clock.Now().Add(c.DefaultTTL)))
+		}
+		return jkv.NewIntCmd(int64(len(fields)), nil)
+	}
+	return jkv.NewIntCmd(0, c.notOpen())
+}
+
+// parseTSV returns the fields in lines of field<TAB>value, a later line for a field replacing an earlier one
+func parseTSV(input string) (map[string]string, error) {
+	fields := map[string]string{}
+	for i, line := range strings.Split(strings.TrimSuffix(input, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		field, value, ok := strings.Cut(line, "\t")
+		if !ok {
+			return nil, fmt.Errorf("line %d: no tab between field and value", i+1)
+		}
+		fields[field] = value
+	}
+	return fields, nil
+}
+
+// stageHash writes fields to a new directory beside the database directories, returning its path
+func (c *Client) stageHash(fields map[string]string) (string, error) {
+	dir, err := os.MkdirTemp(c.GetDBDir(), ".hload-*")
+	if err != nil {
+		return "", err
+	}
+	if err = os.Chmod(dir, 0775); err == nil {
+		for field, value := range fields {
+			if err = c.writeValue(dir+"/"+c.diskName(field), []byte(value), 0664); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// swapHash renames the directory staged into place as hash, leaving the old hash at staged.old to be removed. A
+// directory can't be renamed over one that isn't empty, so the old one is moved aside first, and hashMu keeps
+// readers of the whole hash from seeing the moment it has neither.
+func (c *Client) swapHash(hash, staged string) error {
+	old := staged + ".old"
+	c.hashMu.Lock()
+	defer c.hashMu.Unlock()
+	if err := os.Rename(c.hashPath(hash), old); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(staged, c.hashPath(hash)); err != nil {
+		os.Rename(old, c.hashPath(hash))
+		return err
+	}
+	return nil
+}
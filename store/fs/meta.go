@@ -0,0 +1,64 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// Metadata of a scalar, e.g. its content-type, is kept as a JSON object in a sidecar file under MetaDir. Set, Del
+// and expiry remove it along with the value.
+
+func (c *Client) MetaDir() string { return c.GetDBDir() + "/meta/" }
+
+func (c *Client) clearMeta(key string) { os.Remove(c.MetaDir() + c.diskName(key)) }
+
+// SetWithMeta sets scalar key to value, like SET with no expiration, and stores meta alongside it
+func (c *Client) SetWithMeta(ctx context.Context, key, value string, meta map[string]string) (res *jkv.StatusCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewStatusCmd("", jkv.ErrReadOnly)
+		}
+		if err := c.checkNames(key); err != nil {
+			return jkv.NewStatusCmd("", err)
+		}
+		data, err := json.Marshal(meta)
+		if err != nil {
+			return jkv.NewStatusCmd("", err)
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "SET", key)
+		if err := c.writeValue(c.scalarPath(key), []byte(value), 0660); err != nil {
+			return jkv.NewStatusCmd("", err)
+		}
+		c.clearDeadline(key)
+		if err := c.writeFile(c.MetaDir()+c.diskName(key), data, 0660); err != nil {
+			return jkv.NewStatusCmd("", err)
+		}
+		return jkv.NewStatusCmd("OK", nil)
+	}
+	return jkv.NewStatusCmd("", c.notOpen())
+}
+
+// GetWithMeta returns the value of scalar key and its metadata, which is empty if it was stored by SET
+func (c *Client) GetWithMeta(ctx context.Context, key string) (value string, meta map[string]string, err error) {
+	if !c.IsOpen() {
+		return "", nil, c.notOpen()
+	}
+	rec := c.Get(ctx, key)
+	if rec.Err() != nil {
+		return "", nil, rec.Err()
+	}
+	meta = map[string]string{}
+	data, err := c.readFile(c.MetaDir() + c.diskName(key))
+	if os.IsNotExist(err) {
+		return rec.Val(), meta, nil
+	} else if err != nil {
+		return "", nil, err
+	}
+	return rec.Val(), meta, json.Unmarshal(data, &meta)
+}
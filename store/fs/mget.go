@@ -0,0 +1,74 @@
+package fs
+
+import (
+	"context"
+	"sync"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// mgetWorkers is how many files MGetStream reads at once
+const mgetWorkers = 8
+
+// MGetResult is the value of the key at Index in the keys passed to MGetStream, Err is jkv.ErrKeyNotFound if it
+// doesn't exist
+type MGetResult struct {
+	Index int
+	Value string
+	Err   error
+}
+
+// MGetStream reads the values of keys and sends each on the returned channel as soon as it has been read, so the
+// first values can be used while the rest are still being read. Results arrive in any order, Index puts them back in
+// the order of keys. Each key gets exactly one result unless ctx is done first, in which case the channel is closed
+// without the results not yet sent.
+func (c *Client) MGetStream(ctx context.Context, keys ...string) <-chan MGetResult {
+	results := make(chan MGetResult)
+	indexes := make(chan int)
+	if c.IsOpen() {
+		c.auditRead(ctx, "MGET", keys...)
+		c.settle()
+	}
+
+	var wg sync.WaitGroup
+	workers := mgetWorkers
+	if len(keys) < workers {
+		workers = len(keys)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				res := MGetResult{Index: i}
+				if c.IsOpen() {
+					if c.lazyExpire(keys[i]) {
+						res.Err = jkv.ErrKeyNotFound
+					} else {
+						data, err := c.readFile(c.scalarPath(keys[i]))
+						res.Value, res.Err = string(data), notFound(err)
+					}
+				} else {
+					res.Err = c.notOpen()
+				}
+				select {
+				case results <- res:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(results)
+		defer wg.Wait()
+		defer close(indexes)
+		for i := range keys {
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results
+}
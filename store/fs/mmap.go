@@ -0,0 +1,87 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sync/atomic"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// GetBytes returns the value of key as a []byte, read like GET but without the conversion to a string
+func (c *Client) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	if !c.IsOpen() {
+		return nil, c.notOpen()
+	}
+	c.auditRead(ctx, "GET", key)
+	c.settle()
+	if c.lazyExpire(key) {
+		return nil, jkv.ErrKeyNotFound
+	}
+	data, err := c.readFile(c.scalarPath(key))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return data, nil
+}
+
+// Mapping is a value mapped into memory by GetMmap. Bytes is the page cache of the value file rather than a copy on
+// the heap, so it must not be modified and must not be used after Release. Writes to the key rename a new file into
+// place, so the mapping keeps the value it was made from, except SETBIT, which changes the file where it lies.
+type Mapping struct {
+	data   []byte
+	mapped bool // false for a value that had to be read instead, an empty or compressed one
+}
+
+// Bytes returns the value, nil once it has been released
+func (m *Mapping) Bytes() []byte { return m.data }
+
+// Release unmaps the value, releasing it again does nothing
+func (m *Mapping) Release() error {
+	data, mapped := m.data, m.mapped
+	m.data, m.mapped = nil, false
+	if !mapped {
+		return nil
+	}
+	return munmap(data)
+}
+
+// GetMmap returns the value of key mapped read only into memory, which saves copying a large value to the heap. The
+// caller must Release it. A compressed value can't be used where it lies, so it is read and decompressed as by GET.
+func (c *Client) GetMmap(ctx context.Context, key string) (*Mapping, error) {
+	if !c.IsOpen() {
+		return nil, c.notOpen()
+	}
+	c.auditRead(ctx, "GET", key)
+	c.settle()
+	if c.lazyExpire(key) {
+		return nil, jkv.ErrKeyNotFound
+	}
+	f, err := retryEINTR(func() (*os.File, error) { return sysOpen(c.scalarPath(key)) })
+	if err != nil {
+		return nil, notFound(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return &Mapping{data: []byte{}}, nil // there is nothing to map
+	}
+	data, err := mmap(f, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&c.stats.bytesRead, int64(len(data)))
+	if bytes.HasPrefix(data, []byte(compressMagic)) {
+		value, err := decompress(data)
+		munmap(data)
+		if err != nil {
+			return nil, err
+		}
+		return &Mapping{data: value}, nil
+	}
+	return &Mapping{data: data, mapped: true}, nil
+}
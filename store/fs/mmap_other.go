@@ -0,0 +1,17 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package fs
+
+import (
+	"io"
+	"os"
+)
+
+// mmap reads the file where there is no mmap, so a Mapping holds a copy of the value
+func mmap(f *os.File, size int) ([]byte, error) {
+	data := make([]byte, size)
+	_, err := io.ReadFull(f, data)
+	return data, err
+}
+
+func munmap(data []byte) error { return nil }
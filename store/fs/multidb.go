@@ -0,0 +1,85 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DB 0 lives in the directory given by Options.Addr, any other numbered DB in a db<n> directory below it, which
+// keeps it out of the way of the directories DB 0 manages
+const dbPrefix = "db"
+
+// dbDir returns the directory of DB n under root
+func dbDir(root string, n int) string {
+	if n == 0 {
+		return root
+	}
+	return root + "/" + dbPrefix + strconv.Itoa(n)
+}
+
+// DBKey is a key and the number of the DB it was found in
+type DBKey struct {
+	DB  int
+	Key string
+}
+
+// DBs returns the numbers of the DBs under the root of c, in order. DB 0 is always there.
+func (c *Client) DBs() ([]int, error) {
+	dbs := []int{0}
+	entries, err := readDir(c.root())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return dbs, nil
+		}
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), dbPrefix) {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), dbPrefix)); err == nil && n > 0 {
+			dbs = append(dbs, n)
+		}
+	}
+	sort.Ints(dbs)
+	return dbs, nil
+}
+
+// ScanAllDBs returns the keys matching pattern in every numbered DB under the root of c, ordered by DB
+func (c *Client) ScanAllDBs(ctx context.Context, pattern string) ([]DBKey, error) {
+	if !c.IsOpen() {
+		return nil, c.notOpen()
+	}
+	if _, err := globMatch(pattern, ""); err != nil {
+		return nil, err
+	}
+	dbs, err := c.DBs()
+	if err != nil {
+		return nil, err
+	}
+	var keys []DBKey
+	for _, n := range dbs {
+		db := c
+		if n != c.DB {
+			db = NewClient(&Options{Addr: c.root(), DB: n, ReadOnly: true, Logger: c.Logger, FileNaming: c.FileNaming,
+				IncludeExpired: c.includeExpired(), Clock: c.Clock})
+			if err := db.Open(); err != nil {
+				return nil, err
+			}
+		}
+		rec := db.Keys(ctx, pattern)
+		if db != c {
+			db.Close()
+		}
+		if rec.Err() != nil {
+			return nil, rec.Err()
+		}
+		for _, key := range rec.Val() {
+			keys = append(keys, DBKey{DB: n, Key: key})
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,101 @@
+package fs
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// FileNaming selects how keys are named on disk
+type FileNaming int
+
+const (
+	// NamingPlain stores a key in a file with the same name
+	NamingPlain FileNaming = iota
+	// NamingExtension adds ScalarExt to the key, e.g. for tools that pick an editor or viewer by extension
+	NamingExtension
+	// NamingEncoded %XX escapes every byte of a key, hash field or sidecar name except letters, digits, '-', '_', ':'
+	// and a '.' that is not first, so any byte string, including '/', NUL and invalid UTF-8, is a safe filename
+	NamingEncoded
+)
+
+// ScalarExt is the extension NamingExtension adds to scalar files
+const ScalarExt = ".txt"
+
+func (n FileNaming) String() string {
+	switch n {
+	case NamingPlain:
+		return "plain"
+	case NamingExtension:
+		return "extension"
+	case NamingEncoded:
+		return "encoded"
+	}
+	return fmt.Sprintf("FileNaming(%d)", int(n))
+}
+
+func encodeName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		if 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' ||
+			ch == '-' || ch == '_' || ch == ':' || ch == '.' && i > 0 {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// fileName returns the name of the file holding scalar key
+func (c *Client) fileName(key string) string {
+	switch c.FileNaming {
+	case NamingExtension:
+		return key + ScalarExt
+	case NamingEncoded:
+		return encodeName(key)
+	}
+	return key
+}
+
+// keyName returns the key stored in file, ok is false if the file doesn't follow the naming scheme
+func (c *Client) keyName(file string) (key string, ok bool) {
+	switch c.FileNaming {
+	case NamingExtension:
+		return strings.TrimSuffix(file, ScalarExt), strings.HasSuffix(file, ScalarExt)
+	case NamingEncoded:
+		key, err := url.PathUnescape(file)
+		return key, err == nil
+	}
+	return file, true
+}
+
+// scalarPath returns the path of the file holding scalar key
+func (c *Client) scalarPath(key string) string { return c.ScalarDir() + c.fileName(key) }
+
+// diskName returns the name on disk of a hash, a hash field or a sidecar file. NamingEncoded escapes it like a
+// scalar file name, so hashes, fields and sidecars take any key too. NamingExtension only applies to scalar files.
+func (c *Client) diskName(name string) string {
+	if c.FileNaming == NamingEncoded {
+		return encodeName(name)
+	}
+	return name
+}
+
+// nameOf returns the name stored on disk as file by diskName, ok is false if file isn't a valid encoding
+func (c *Client) nameOf(file string) (name string, ok bool) {
+	if c.FileNaming == NamingEncoded {
+		name, err := url.PathUnescape(file)
+		return name, err == nil
+	}
+	return file, true
+}
+
+// hashPath returns the path of the directory of hash
+func (c *Client) hashPath(hash string) string { return c.HashDir() + c.diskName(hash) }
+
+// fieldPath returns the path of the file holding field of hash
+func (c *Client) fieldPath(hash, field string) string {
+	return c.hashPath(hash) + "/" + c.diskName(field)
+}
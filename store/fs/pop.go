@@ -0,0 +1,59 @@
+package fs
+
+import (
+	"context"
+	"os"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// PopScalar returns the value of scalar key and deletes it while holding the lock, like GETDEL
+func (c *Client) PopScalar(ctx context.Context, key string) (res *jkv.StringCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewStringCmd("", jkv.ErrReadOnly)
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "GETDEL", key)
+
+		c.expire(key)
+		data, err := c.readFile(c.scalarPath(key))
+		if err != nil {
+			return jkv.NewStringCmd("", notFound(err))
+		}
+		if err = os.Remove(c.scalarPath(key)); err != nil {
+			return jkv.NewStringCmd("", err)
+		}
+		c.clearDeadline(key)
+		c.clearMeta(key)
+		return jkv.NewStringCmd(string(data), nil)
+	}
+	return jkv.NewStringCmd("", c.notOpen())
+}
+
+// HPop returns the value of a hash field and deletes it while holding the lock, the hash is removed with its last
+// field
+func (c *Client) HPop(ctx context.Context, hash, field string) (res *jkv.StringCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewStringCmd("", jkv.ErrReadOnly)
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "HPOP", hash)
+
+		c.expireField(hash, field)
+		data, err := c.readFile(c.fieldPath(hash, field))
+		if err != nil {
+			return jkv.NewStringCmd("", notFound(err))
+		}
+		if _, err = c.hdel(hash, []string{field}); err != nil {
+			return jkv.NewStringCmd("", err)
+		}
+		return jkv.NewStringCmd(string(data), nil)
+	}
+	return jkv.NewStringCmd("", c.notOpen())
+}
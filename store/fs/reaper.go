@@ -0,0 +1,81 @@
+package fs
+
+import "time"
+
+// DEFAULT_ACTIVE_EXPIRE_INTERVAL is how often the reaper looks for expired keys, like the 10Hz of Redis
+const DEFAULT_ACTIVE_EXPIRE_INTERVAL = 100 * time.Millisecond
+
+// SetActiveExpire turns the background reaper on or off. With it off, expired keys and fields stay on disk until
+// they are written, or read or listed by a client that isn't read only, which is the lazy expiry every client does
+// anyway, like DEBUG SET-ACTIVE-EXPIRE in Redis.
+func (c *Client) SetActiveExpire(on bool) {
+	c.reaperMu.Lock()
+	defer c.reaperMu.Unlock()
+	c.ActiveExpire = on
+	if on && c.IsOpen() {
+		c.startReaper()
+	} else {
+		c.stopReaper()
+	}
+}
+
+// startReaper starts the reaper unless it is running or the client is read only, c.reaperMu must be held
+func (c *Client) startReaper() {
+	if c.reaperStop != nil || c.readOnly() {
+		return
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	c.reaperStop, c.reaperDone = stop, done
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(c.ActiveExpireInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				c.reap()
+			}
+		}
+	}()
+}
+
+// stopReaper stops the reaper and waits for it to finish a sweep it is in, c.reaperMu must be held
+func (c *Client) stopReaper() {
+	if c.reaperStop == nil {
+		return
+	}
+	close(c.reaperStop)
+	<-c.reaperDone
+	c.reaperStop, c.reaperDone = nil, nil
+}
+
+// reap removes every key and hash field whose deadline has passed
+func (c *Client) reap() {
+	c.lock()
+	defer c.unlock()
+	if entries, err := readDir(c.ExpireDir()); err == nil {
+		for _, entry := range entries {
+			if key, ok := c.nameOf(entry.Name()); ok {
+				c.expire(key)
+			}
+		}
+	}
+	hashes, err := readDir(c.FieldExpireDir())
+	if err != nil {
+		return
+	}
+	for _, hash := range hashes {
+		name, ok := c.nameOf(hash.Name())
+		fields, err := readDir(c.FieldExpireDir() + hash.Name())
+		if !ok || err != nil {
+			continue
+		}
+		for _, field := range fields {
+			if field, ok := c.nameOf(field.Name()); ok {
+				c.expireField(name, field)
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+var errTargetExists = errors.New("ERR target key name is busy")
+
+// RenamePrefix renames every scalar and hash whose name starts with oldPrefix to start with newPrefix instead,
+// returning the number renamed. Nothing is renamed if any of the new names is already taken.
+func (c *Client) RenamePrefix(ctx context.Context, oldPrefix, newPrefix string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewIntCmd(0, jkv.ErrReadOnly)
+		}
+		c.lock()
+		defer c.unlock()
+
+		names, err := c.names()
+		if err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		var from, to []string
+		for _, name := range names {
+			if strings.HasPrefix(name, oldPrefix) {
+				from = append(from, name)
+				to = append(to, newPrefix+strings.TrimPrefix(name, oldPrefix))
+			}
+		}
+		if err := c.checkNames(to...); err != nil {
+			return jkv.NewIntCmd(0, err)
+		}
+		for _, name := range to {
+			if c.exists(name) {
+				return jkv.NewIntCmd(0, errTargetExists)
+			}
+		}
+
+		c.audit(ctx, "RENAMEPREFIX", from...)
+		for i := range from {
+			if err := c.rename(from[i], to[i]); err != nil {
+				return jkv.NewIntCmd(int64(i), err)
+			}
+		}
+		return jkv.NewIntCmd(int64(len(from)), nil)
+	}
+	return jkv.NewIntCmd(0, c.notOpen())
+}
+
+// exists returns true if name is a scalar or a hash
+func (c *Client) exists(name string) bool {
+	if _, err := os.Stat(c.scalarPath(name)); err == nil {
+		return true
+	}
+	_, err := os.Stat(c.hashPath(name))
+	return err == nil
+}
+
+// rename moves scalar or hash from to to along with its sidecar files, with the lock held
+func (c *Client) rename(from, to string) error {
+	if err := os.Rename(c.scalarPath(from), c.scalarPath(to)); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Rename(c.hashPath(from), c.hashPath(to)); err != nil {
+			return err
+		}
+	}
+	for _, dir := range []string{c.ExpireDir(), c.FieldExpireDir(), c.MetaDir()} {
+		if err := os.Rename(dir+c.diskName(from), dir+c.diskName(to)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// SWAP exchanges the values of scalars a and b, each value taking its expiration and metadata with it. The files are
+// swapped by renames through a temporary name kept out of the scalar directory, so a reader sees either value whole.
+// It returns jkv.ErrNoKey if either key doesn't exist and jkv.ErrWrongType if either is a hash.
+func (c *Client) Swap(ctx context.Context, a, b string) (res *jkv.StatusCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewStatusCmd("", jkv.ErrReadOnly)
+		}
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "SWAP", a, b)
+		for _, key := range []string{a, b} {
+			c.expire(key)
+			switch c.keyType(key) {
+			case "none":
+				return jkv.NewStatusCmd("", jkv.ErrNoKey)
+			case "hash":
+				return jkv.NewStatusCmd("", jkv.ErrWrongType)
+			}
+		}
+		if a == b {
+			return jkv.NewStatusCmd("OK", nil)
+		}
+
+		f, err := os.CreateTemp(c.GetDBDir(), ".swap-*")
+		if err != nil {
+			return jkv.NewStatusCmd("", err)
+		}
+		f.Close()
+		defer os.Remove(f.Name())
+		if err := swapFiles(c.scalarPath(a), c.scalarPath(b), f.Name()); err != nil {
+			return jkv.NewStatusCmd("", err)
+		}
+		for _, dir := range []string{c.ExpireDir(), c.MetaDir()} {
+			if err := swapFiles(dir+c.diskName(a), dir+c.diskName(b), f.Name()); err != nil {
+				return jkv.NewStatusCmd("", err)
+			}
+		}
+		return jkv.NewStatusCmd("OK", nil)
+	}
+	return jkv.NewStatusCmd("", c.notOpen())
+}
+
+// swapFiles exchanges the files at x and y, either of which may be missing, by way of the path tmp
+func swapFiles(x, y, tmp string) error {
+	moved := true
+	if err := os.Rename(x, tmp); os.IsNotExist(err) {
+		moved = false
+	} else if err != nil {
+		return err
+	}
+	if err := os.Rename(y, x); err != nil && !os.IsNotExist(err) {
+		if moved {
+			os.Rename(tmp, x)
+		}
+		return err
+	}
+	if moved {
+		return os.Rename(tmp, y)
+	}
+	return nil
+}
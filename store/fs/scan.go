@@ -0,0 +1,164 @@
+package fs
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// The SCAN cursor is an opaque token holding the last key returned, so a scan can be resumed by another Client or
+// another process. Keys are visited in sorted order and a page starts after the key in the cursor, so keys added or
+// removed during a scan don't disturb the position of the others, though a key added behind the cursor won't be seen.
+//
+// This gives the guarantee Redis documents for its SCAN: a key present from the start of a scan to its end is
+// returned. It is returned exactly once, where Redis may return it twice when its table is resized, but callers
+// should still tolerate duplicates so they work with both stores. A key added or removed during the scan may or may
+// not be returned.
+
+const scanStart = "0"
+
+func encodeCursor(key string) string { return "k" + base64.RawURLEncoding.EncodeToString([]byte(key)) }
+
+func decodeCursor(cursor string) (string, error) {
+	if cursor == scanStart || cursor == "" {
+		return "", nil
+	}
+	if cursor[0] != 'k' {
+		return "", errors.New("ERR invalid cursor")
+	}
+	key, err := base64.RawURLEncoding.DecodeString(cursor[1:])
+	if err != nil {
+		return "", errors.New("ERR invalid cursor")
+	}
+	return string(key), nil
+}
+
+// names returns the sorted names of the scalars and hashes that haven't expired
+func (c *Client) names() ([]string, error) {
+	seen := c.expired() // marking expired keys as seen leaves them out
+	if seen == nil {
+		seen = map[string]bool{}
+	}
+	var names []string
+	for _, dir := range []string{c.ScalarDir(), c.HashDir()} {
+		entries, err := readDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name, ok := entry.Name(), true
+			if dir == c.ScalarDir() {
+				name, ok = c.keyName(name)
+			} else if name, ok = c.nameOf(name); ok && c.keepEmptyHashes() {
+				ok = !c.emptyHash(name)
+			}
+			if ok && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// globMatch reports whether name matches the glob pattern, with *, ? and [...] as in filepath.Match. Like KEYS and
+// SCAN MATCH in Redis they match / too: / is swapped for NUL in both so filepath.Match doesn't treat it as a
+// separator. A malformed pattern returns filepath.ErrBadPattern.
+func globMatch(pattern, name string) (bool, error) {
+	return filepath.Match(strings.ReplaceAll(pattern, "/", "\x00"), strings.ReplaceAll(name, "/", "\x00"))
+}
+
+// keyType returns the type of key as reported by TYPE, "none" if it doesn't exist
+func (c *Client) keyType(key string) string {
+	if _, err := os.Stat(c.scalarPath(key)); err == nil {
+		return "string"
+	}
+	if info, err := os.Stat(c.hashPath(key)); err == nil && info.IsDir() && !(c.keepEmptyHashes() && c.emptyHash(key)) {
+		return "hash"
+	}
+	return "none"
+}
+
+// TYPES returns how many keys there are of each type that has any, from one pass over the keys
+func (c *Client) Types(ctx context.Context) (res *jkv.StringIntMapCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.auditRead(ctx, "TYPES")
+		c.settle()
+		names, err := c.names()
+		if err != nil {
+			return jkv.NewStringIntMapCmd(map[string]int64{}, err)
+		}
+		types := map[string]int64{}
+		for _, name := range names {
+			if t := c.keyType(name); t != "none" {
+				types[t]++
+			}
+		}
+		return jkv.NewStringIntMapCmd(types, nil)
+	}
+	return jkv.NewStringIntMapCmd(map[string]int64{}, c.notOpen())
+}
+
+// keyTypes are the types SCAN TYPE accepts, only strings and hashes exist in the fs store so far
+var keyTypes = map[string]bool{"string": true, "hash": true, "list": true, "set": true, "zset": true, "stream": true}
+
+// SCAN visits up to count keys after cursor and returns those matching the glob pattern match
+func (c *Client) Scan(ctx context.Context, cursor string, match string, count int64) *jkv.ScanCmd {
+	return c.ScanType(ctx, cursor, match, count, "")
+}
+
+// SCAN with TYPE, like Scan but only keys of keyType are returned, all keys if it is empty
+func (c *Client) ScanType(ctx context.Context, cursor string, match string, count int64, keyType string) (res *jkv.ScanCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.auditRead(ctx, "SCAN")
+		c.settle()
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return jkv.NewScanCmd([]string{}, scanStart, err)
+		}
+		keyType = strings.ToLower(keyType)
+		if keyType != "" && !keyTypes[keyType] {
+			return jkv.NewScanCmd([]string{}, scanStart, fmt.Errorf("ERR unknown type name '%s'", keyType))
+		}
+		if match == "" {
+			match = "*"
+		}
+		if _, err := globMatch(match, ""); err != nil {
+			return jkv.NewScanCmd([]string{}, scanStart, err)
+		}
+		if count <= 0 {
+			count = 10
+		}
+
+		names, err := c.names()
+		if err != nil {
+			return jkv.NewScanCmd([]string{}, scanStart, err)
+		}
+		i := 0
+		if cursor != scanStart && cursor != "" {
+			i = sort.Search(len(names), func(i int) bool { return names[i] > after })
+		}
+
+		keys := []string{}
+		for n := int64(0); i < len(names) && n < count; i, n = i+1, n+1 {
+			if ok, _ := globMatch(match, names[i]); ok && (keyType == "" || c.keyType(names[i]) == keyType) {
+				keys = append(keys, names[i])
+			}
+		}
+		if i >= len(names) {
+			return jkv.NewScanCmd(keys, scanStart, nil)
+		}
+		return jkv.NewScanCmd(keys, encodeCursor(names[i-1]), nil)
+	}
+	return jkv.NewScanCmd([]string{}, scanStart, c.notOpen())
+}
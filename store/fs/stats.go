@@ -0,0 +1,95 @@
+package fs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats are internal counters kept by the fs store for diagnosing performance problems
+type Stats struct {
+	LockWaits    int64            // number of times a writer had to wait for the lock
+	LockWaitTime time.Duration    // total time writers spent waiting for the lock
+	Fsyncs       int64            // number of files synced by Durable writes
+	BytesRead    int64            // bytes of values read
+	BytesWritten int64            // bytes of values and metadata written
+	Commands     map[string]int64 // number of times each command ran, by its upper case name
+}
+
+type stats struct {
+	lockWaits, lockWaitNs, fsyncs, bytesRead, bytesWritten int64
+
+	commandsMu sync.Mutex
+	commands   map[string]int64
+}
+
+// count records that the command op ran, every command is counted by audit or auditRead
+func (c *Client) count(op string) {
+	c.stats.commandsMu.Lock()
+	defer c.stats.commandsMu.Unlock()
+	if c.stats.commands == nil {
+		c.stats.commands = map[string]int64{}
+	}
+	c.stats.commands[op]++
+}
+
+// lock acquires the writer lock and writes out any buffered bulk writes so the caller sees them
+func (c *Client) lock() {
+	c.acquire()
+	if len(c.pending) > 0 {
+		c.flush()
+	}
+}
+
+// acquire acquires the writer lock, recording any time spent waiting for it
+func (c *Client) acquire() {
+	if c.mu.TryLock() {
+		return
+	}
+	start := time.Now()
+	c.mu.Lock()
+	atomic.AddInt64(&c.stats.lockWaits, 1)
+	atomic.AddInt64(&c.stats.lockWaitNs, int64(time.Since(start)))
+}
+
+func (c *Client) unlock() { c.mu.Unlock() }
+
+// DebugStats returns a snapshot of the internal counters
+func (c *Client) DebugStats(ctx context.Context) Stats {
+	return Stats{
+		LockWaits:    atomic.LoadInt64(&c.stats.lockWaits),
+		LockWaitTime: time.Duration(atomic.LoadInt64(&c.stats.lockWaitNs)),
+		Fsyncs:       atomic.LoadInt64(&c.stats.fsyncs),
+		BytesRead:    atomic.LoadInt64(&c.stats.bytesRead),
+		BytesWritten: atomic.LoadInt64(&c.stats.bytesWritten),
+		Commands:     c.commandCounts(),
+	}
+}
+
+// commandCounts returns a copy of the command counters
+func (c *Client) commandCounts() map[string]int64 {
+	c.stats.commandsMu.Lock()
+	defer c.stats.commandsMu.Unlock()
+	counts := make(map[string]int64, len(c.stats.commands))
+	for op, n := range c.stats.commands {
+		counts[op] = n
+	}
+	return counts
+}
+
+// start returns the time a command started if RecordDuration is set
+func (c *Client) start() time.Time {
+	if c.recordDuration() {
+		return time.Now()
+	}
+	return time.Time{}
+}
+
+// timed records the duration of *res, each command defers it with the result of start, which is zero unless
+// RecordDuration is set
+func timed[T interface{ SetDuration(time.Duration) }](start time.Time, res *T) {
+	if !start.IsZero() {
+		(*res).SetDuration(time.Since(start))
+	}
+}
@@ -0,0 +1,102 @@
+package fs
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// GetTo copies the value of key to w without holding it in memory and returns the number of bytes copied
+func (c *Client) GetTo(ctx context.Context, key string, w io.Writer) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		c.auditRead(ctx, "GET", key)
+		c.settle()
+		if c.lazyExpire(key) {
+			return jkv.NewIntCmd(0, jkv.ErrKeyNotFound)
+		}
+		f, err := os.Open(c.scalarPath(key))
+		if err != nil {
+			return jkv.NewIntCmd(0, notFound(err))
+		}
+		defer f.Close()
+		if compressed(c.scalarPath(key)) {
+			f.Seek(int64(len(compressMagic)), io.SeekStart)
+			counted := &countingReader{r: f}
+			defer func() { atomic.AddInt64(&c.stats.bytesRead, counted.n) }()
+			r, err := gzip.NewReader(counted)
+			if err != nil {
+				return jkv.NewIntCmd(0, err)
+			}
+			defer r.Close()
+			return jkv.NewIntCmd(io.Copy(w, r))
+		}
+		n, err := io.Copy(w, f)
+		atomic.AddInt64(&c.stats.bytesRead, n)
+		return jkv.NewIntCmd(n, err)
+	}
+	return jkv.NewIntCmd(0, c.notOpen())
+}
+
+// SetFrom sets key to everything read from r. The value is written to a temporary file that is renamed into place,
+// so readers see the old value or the new one and never part of it. The lock is only taken for the rename, a slow r
+// doesn't hold up other writers.
+func (c *Client) SetFrom(ctx context.Context, key string, r io.Reader) (res *jkv.StatusCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen() {
+		if c.readOnly() {
+			return jkv.NewStatusCmd("", jkv.ErrReadOnly)
+		}
+		if err := c.checkNames(key); err != nil {
+			return jkv.NewStatusCmd("", err)
+		}
+		// the temporary file is kept out of the scalar directory so KEYS never lists it
+		f, err := os.CreateTemp(c.GetDBDir(), ".setfrom-*")
+		if err != nil {
+			return jkv.NewStatusCmd("", err)
+		}
+		n, err := io.Copy(f, r)
+		atomic.AddInt64(&c.stats.bytesWritten, n)
+		if err == nil {
+			err = c.sync(f)
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Chmod(f.Name(), 0660)
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return jkv.NewStatusCmd("", err)
+		}
+
+		c.lock()
+		defer c.unlock()
+		c.audit(ctx, "SET", key)
+		if err := os.Rename(f.Name(), c.scalarPath(key)); err != nil {
+			os.Remove(f.Name())
+			return jkv.NewStatusCmd("", err)
+		}
+		c.clearMeta(key)
+		c.clearDeadline(key)
+		return jkv.NewStatusCmd("OK", nil)
+	}
+	return jkv.NewStatusCmd("", c.notOpen())
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,412 @@
+// Package limit wraps a jkv.Client so no more than a set number of commands are in flight at once, and keeps track
+// of the commands in flight so stuck ones can be listed and cancelled
+package limit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// ErrLimited is returned instead of waiting for a slot when Options.FailFast is set. It starts with BUSY, so the
+// retry package treats it as transient.
+var ErrLimited = errors.New("BUSY too many commands in flight")
+
+// Options controls how many commands may be in flight and what happens to the ones over the limit
+type Options struct {
+	MaxConcurrency int  // commands run at once, 1 if zero
+	FailFast       bool // return ErrLimited instead of waiting for a slot
+}
+
+// Client is a jkv.Client that limits the commands in flight on Inner
+type Client struct {
+	Inner   jkv.Client
+	Options Options
+
+	slots chan struct{} // a semaphore, holding one value per command in flight
+
+	opsMu  sync.Mutex
+	ops    map[uint64]*op // commands in flight by ID, waiting for a slot or running
+	nextID uint64
+}
+
+// New returns inner wrapped so at most opts.MaxConcurrency commands run on it at once. Callers over the limit wait
+// for a slot until their ctx is done, or fail straight away with ErrLimited if opts.FailFast is set.
+func New(inner jkv.Client, opts Options) *Client {
+	if opts.MaxConcurrency <= 0 {
+		opts.MaxConcurrency = 1
+	}
+	return &Client{Inner: inner, Options: opts, slots: make(chan struct{}, opts.MaxConcurrency), ops: map[uint64]*op{}}
+}
+
+// acquire registers cmd on keys as in flight and takes a slot for it, returning an error if ctx is done first or
+// there is none free and FailFast is set. The command is to run with the ctx returned, which Cancel cancels.
+func (c *Client) acquire(ctx context.Context, cmd string, keys []string) (context.Context, *op, error) {
+	ctx, o := c.track(ctx, cmd, keys)
+	select {
+	case c.slots <- struct{}{}:
+		return ctx, o, nil
+	default:
+	}
+	if c.Options.FailFast {
+		c.untrack(o)
+		return ctx, nil, ErrLimited
+	}
+	select {
+	case c.slots <- struct{}{}:
+		return ctx, o, nil
+	case <-ctx.Done():
+		c.untrack(o)
+		return ctx, nil, ctx.Err()
+	}
+}
+
+// release gives back the slot taken by acquire for o
+func (c *Client) release(o *op) {
+	<-c.slots
+	c.untrack(o)
+}
+
+func (c *Client) Open() error {
+	_, o, err := c.acquire(context.Background(), "OPEN", nil)
+	if err != nil {
+		return err
+	}
+	defer c.release(o)
+	return c.Inner.Open()
+}
+
+func (c *Client) Close()           { c.Inner.Close() }
+func (c *Client) GetDBDir() string { return c.Inner.GetDBDir() }
+
+func (c *Client) Capabilities() jkv.Capabilities { return c.Inner.Capabilities() }
+
+func (c *Client) FlushDB(ctx context.Context) *jkv.StatusCmd {
+	ctx, o, err := c.acquire(ctx, "FLUSHDB", nil)
+	if err != nil {
+		return jkv.NewStatusCmd("", err)
+	}
+	defer c.release(o)
+	return c.Inner.FlushDB(ctx)
+}
+
+func (c *Client) Get(ctx context.Context, key string) *jkv.StringCmd {
+	ctx, o, err := c.acquire(ctx, "GET", []string{key})
+	if err != nil {
+		return jkv.NewStringCmd("", err)
+	}
+	defer c.release(o)
+	return c.Inner.Get(ctx, key)
+}
+
+func (c *Client) GetEX(ctx context.Context, key string, opts jkv.ExpiryOptions) *jkv.StringCmd {
+	ctx, o, err := c.acquire(ctx, "GETEX", []string{key})
+	if err != nil {
+		return jkv.NewStringCmd("", err)
+	}
+	defer c.release(o)
+	return c.Inner.GetEX(ctx, key, opts)
+}
+
+func (c *Client) Set(ctx context.Context, key, value string, expiration time.Duration) *jkv.StatusCmd {
+	ctx, o, err := c.acquire(ctx, "SET", []string{key})
+	if err != nil {
+		return jkv.NewStatusCmd("", err)
+	}
+	defer c.release(o)
+	return c.Inner.Set(ctx, key, value, expiration)
+}
+
+func (c *Client) Del(ctx context.Context, keys ...string) *jkv.IntCmd {
+	ctx, o, err := c.acquire(ctx, "DEL", keys)
+	if err != nil {
+		return jkv.NewIntCmd(0, err)
+	}
+	defer c.release(o)
+	return c.Inner.Del(ctx, keys...)
+}
+
+func (c *Client) SetNX(ctx context.Context, key, value string, expiration time.Duration) *jkv.BoolCmd {
+	ctx, o, err := c.acquire(ctx, "SETNX", []string{key})
+	if err != nil {
+		return jkv.NewBoolCmd(false, err)
+	}
+	defer c.release(o)
+	return c.Inner.SetNX(ctx, key, value, expiration)
+}
+
+func (c *Client) CompareAndDelete(ctx context.Context, key, value string) *jkv.BoolCmd {
+	ctx, o, err := c.acquire(ctx, "CAD", []string{key})
+	if err != nil {
+		return jkv.NewBoolCmd(false, err)
+	}
+	defer c.release(o)
+	return c.Inner.CompareAndDelete(ctx, key, value)
+}
+
+func (c *Client) Incr(ctx context.Context, key string) *jkv.IntCmd {
+	ctx, o, err := c.acquire(ctx, "INCR", []string{key})
+	if err != nil {
+		return jkv.NewIntCmd(0, err)
+	}
+	defer c.release(o)
+	return c.Inner.Incr(ctx, key)
+}
+
+func (c *Client) Decr(ctx context.Context, key string) *jkv.IntCmd {
+	ctx, o, err := c.acquire(ctx, "DECR", []string{key})
+	if err != nil {
+		return jkv.NewIntCmd(0, err)
+	}
+	defer c.release(o)
+	return c.Inner.Decr(ctx, key)
+}
+
+func (c *Client) SetBit(ctx context.Context, key string, offset int64, value int) *jkv.IntCmd {
+	ctx, o, err := c.acquire(ctx, "SETBIT", []string{key})
+	if err != nil {
+		return jkv.NewIntCmd(0, err)
+	}
+	defer c.release(o)
+	return c.Inner.SetBit(ctx, key, offset, value)
+}
+
+func (c *Client) GetBit(ctx context.Context, key string, offset int64) *jkv.IntCmd {
+	ctx, o, err := c.acquire(ctx, "GETBIT", []string{key})
+	if err != nil {
+		return jkv.NewIntCmd(0, err)
+	}
+	defer c.release(o)
+	return c.Inner.GetBit(ctx, key, offset)
+}
+
+func (c *Client) BitCount(ctx context.Context, key string, bitCount *jkv.BitCount) *jkv.IntCmd {
+	ctx, o, err := c.acquire(ctx, "BITCOUNT", []string{key})
+	if err != nil {
+		return jkv.NewIntCmd(0, err)
+	}
+	defer c.release(o)
+	return c.Inner.BitCount(ctx, key, bitCount)
+}
+
+func (c *Client) PFAdd(ctx context.Context, key string, elements ...string) *jkv.IntCmd {
+	ctx, o, err := c.acquire(ctx, "PFADD", []string{key})
+	if err != nil {
+		return jkv.NewIntCmd(0, err)
+	}
+	defer c.release(o)
+	return c.Inner.PFAdd(ctx, key, elements...)
+}
+
+func (c *Client) PFCount(ctx context.Context, keys ...string) *jkv.IntCmd {
+	ctx, o, err := c.acquire(ctx, "PFCOUNT", keys)
+	if err != nil {
+		return jkv.NewIntCmd(0, err)
+	}
+	defer c.release(o)
+	return c.Inner.PFCount(ctx, keys...)
+}
+
+func (c *Client) PFMerge(ctx context.Context, dest string, keys ...string) *jkv.StatusCmd {
+	ctx, o, err := c.acquire(ctx, "PFMERGE", []string{dest})
+	if err != nil {
+		return jkv.NewStatusCmd("", err)
+	}
+	defer c.release(o)
+	return c.Inner.PFMerge(ctx, dest, keys...)
+}
+
+func (c *Client) Keys(ctx context.Context, pattern string) *jkv.StringSliceCmd {
+	ctx, o, err := c.acquire(ctx, "KEYS", nil)
+	if err != nil {
+		return jkv.NewStringSliceCmd([]string{}, err)
+	}
+	defer c.release(o)
+	return c.Inner.Keys(ctx, pattern)
+}
+
+func (c *Client) Scan(ctx context.Context, cursor string, match string, count int64) *jkv.ScanCmd {
+	ctx, o, err := c.acquire(ctx, "SCAN", nil)
+	if err != nil {
+		return jkv.NewScanCmd([]string{}, "0", err)
+	}
+	defer c.release(o)
+	return c.Inner.Scan(ctx, cursor, match, count)
+}
+
+func (c *Client) ScanType(ctx context.Context, cursor string, match string, count int64, keyType string) *jkv.ScanCmd {
+	ctx, o, err := c.acquire(ctx, "SCAN", nil)
+	if err != nil {
+		return jkv.NewScanCmd([]string{}, "0", err)
+	}
+	defer c.release(o)
+	return c.Inner.ScanType(ctx, cursor, match, count, keyType)
+}
+
+func (c *Client) Exists(ctx context.Context, keys ...string) *jkv.IntCmd {
+	ctx, o, err := c.acquire(ctx, "EXISTS", keys)
+	if err != nil {
+		return jkv.NewIntCmd(0, err)
+	}
+	defer c.release(o)
+	return c.Inner.Exists(ctx, keys...)
+}
+
+func (c *Client) Expire(ctx context.Context, key string, expiration time.Duration) *jkv.BoolCmd {
+	ctx, o, err := c.acquire(ctx, "EXPIRE", []string{key})
+	if err != nil {
+		return jkv.NewBoolCmd(false, err)
+	}
+	defer c.release(o)
+	return c.Inner.Expire(ctx, key, expiration)
+}
+
+func (c *Client) TTL(ctx context.Context, key string) *jkv.IntCmd {
+	ctx, o, err := c.acquire(ctx, "TTL", []string{key})
+	if err != nil {
+		return jkv.NewIntCmd(0, err)
+	}
+	defer c.release(o)
+	return c.Inner.TTL(ctx, key)
+}
+
+func (c *Client) HGet(ctx context.Context, hash, key string) *jkv.StringCmd {
+	ctx, o, err := c.acquire(ctx, "HGET", []string{hash})
+	if err != nil {
+		return jkv.NewStringCmd("", err)
+	}
+	defer c.release(o)
+	return c.Inner.HGet(ctx, hash, key)
+}
+
+func (c *Client) HSet(ctx context.Context, hash string, values ...string) *jkv.IntCmd {
+	ctx, o, err := c.acquire(ctx, "HSET", []string{hash})
+	if err != nil {
+		return jkv.NewIntCmd(0, err)
+	}
+	defer c.release(o)
+	return c.Inner.HSet(ctx, hash, values...)
+}
+
+func (c *Client) HDel(ctx context.Context, hash string, values ...string) *jkv.IntCmd {
+	ctx, o, err := c.acquire(ctx, "HDEL", []string{hash})
+	if err != nil {
+		return jkv.NewIntCmd(0, err)
+	}
+	defer c.release(o)
+	return c.Inner.HDel(ctx, hash, values...)
+}
+
+func (c *Client) HKeys(ctx context.Context, hash string) *jkv.StringSliceCmd {
+	ctx, o, err := c.acquire(ctx, "HKEYS", []string{hash})
+	if err != nil {
+		return jkv.NewStringSliceCmd([]string{}, err)
+	}
+	defer c.release(o)
+	return c.Inner.HKeys(ctx, hash)
+}
+
+func (c *Client) HKeysMatch(ctx context.Context, hash, pattern string) *jkv.StringSliceCmd {
+	ctx, o, err := c.acquire(ctx, "HKEYS", []string{hash})
+	if err != nil {
+		return jkv.NewStringSliceCmd([]string{}, err)
+	}
+	defer c.release(o)
+	return c.Inner.HKeysMatch(ctx, hash, pattern)
+}
+
+func (c *Client) HGetAll(ctx context.Context, hash string) *jkv.StringStringMapCmd {
+	ctx, o, err := c.acquire(ctx, "HGETALL", []string{hash})
+	if err != nil {
+		return jkv.NewStringStringMapCmd(map[string]string{}, err)
+	}
+	defer c.release(o)
+	return c.Inner.HGetAll(ctx, hash)
+}
+
+func (c *Client) HGetAllMatch(ctx context.Context, hash, pattern string) *jkv.StringStringMapCmd {
+	ctx, o, err := c.acquire(ctx, "HGETALL", []string{hash})
+	if err != nil {
+		return jkv.NewStringStringMapCmd(map[string]string{}, err)
+	}
+	defer c.release(o)
+	return c.Inner.HGetAllMatch(ctx, hash, pattern)
+}
+
+func (c *Client) HExists(ctx context.Context, hash, key string) *jkv.BoolCmd {
+	ctx, o, err := c.acquire(ctx, "HEXISTS", []string{hash})
+	if err != nil {
+		return jkv.NewBoolCmd(false, err)
+	}
+	defer c.release(o)
+	return c.Inner.HExists(ctx, hash, key)
+}
+
+func (c *Client) HExpire(ctx context.Context, hash string, seconds int64, fields ...string) *jkv.IntSliceCmd {
+	ctx, o, err := c.acquire(ctx, "HEXPIRE", []string{hash})
+	if err != nil {
+		return jkv.NewIntSliceCmd([]int64{}, err)
+	}
+	defer c.release(o)
+	return c.Inner.HExpire(ctx, hash, seconds, fields...)
+}
+
+func (c *Client) HTTL(ctx context.Context, hash string, fields ...string) *jkv.IntSliceCmd {
+	ctx, o, err := c.acquire(ctx, "HTTL", []string{hash})
+	if err != nil {
+		return jkv.NewIntSliceCmd([]int64{}, err)
+	}
+	defer c.release(o)
+	return c.Inner.HTTL(ctx, hash, fields...)
+}
+
+func (c *Client) Ping(ctx context.Context) *jkv.StatusCmd {
+	ctx, o, err := c.acquire(ctx, "PING", nil)
+	if err != nil {
+		return jkv.NewStatusCmd("", err)
+	}
+	defer c.release(o)
+	return c.Inner.Ping(ctx)
+}
+
+func (c *Client) Version(ctx context.Context) *jkv.StringCmd {
+	ctx, o, err := c.acquire(ctx, "VERSION", nil)
+	if err != nil {
+		return jkv.NewStringCmd("", err)
+	}
+	defer c.release(o)
+	return c.Inner.Version(ctx)
+}
+
+func (c *Client) Info(ctx context.Context, sections ...string) *jkv.StringCmd {
+	ctx, o, err := c.acquire(ctx, "INFO", nil)
+	if err != nil {
+		return jkv.NewStringCmd("", err)
+	}
+	defer c.release(o)
+	return c.Inner.Info(ctx, sections...)
+}
+
+func (c *Client) ConfigGet(ctx context.Context, parameter string) *jkv.StringStringMapCmd {
+	ctx, o, err := c.acquire(ctx, "CONFIG GET", nil)
+	if err != nil {
+		return jkv.NewStringStringMapCmd(map[string]string{}, err)
+	}
+	defer c.release(o)
+	return c.Inner.ConfigGet(ctx, parameter)
+}
+
+func (c *Client) Do(ctx context.Context, args ...interface{}) *jkv.Cmd {
+	cmd, keys := describe(args)
+	ctx, o, err := c.acquire(ctx, cmd, keys)
+	if err != nil {
+		return jkv.NewCmd(nil, err)
+	}
+	defer c.release(o)
+	return c.Inner.Do(ctx, args...)
+}
+
+var _ jkv.Client = (*Client)(nil)
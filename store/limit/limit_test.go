@@ -0,0 +1,125 @@
+package limit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/panduit-joeb/jkv"
+	"github.com/stretchr/testify/assert"
+)
+
+// blocking holds every Get until release is closed
+type blocking struct {
+	jkv.Client
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blocking) Get(ctx context.Context, key string) *jkv.StringCmd {
+	b.started <- struct{}{}
+	<-b.release
+	return jkv.NewStringCmd("value", nil)
+}
+
+// slow holds every Get until its ctx is done
+type slow struct {
+	jkv.Client
+	started chan struct{}
+}
+
+func (s *slow) Get(ctx context.Context, key string) *jkv.StringCmd {
+	s.started <- struct{}{}
+	<-ctx.Done()
+	return jkv.NewStringCmd("", ctx.Err())
+}
+
+func newBlocking() *blocking {
+	return &blocking{started: make(chan struct{}, 10), release: make(chan struct{})}
+}
+
+func TestLimit(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Waits for a slot", func(t *testing.T) {
+		a := assert.New(t)
+		inner := newBlocking()
+		c := New(inner, Options{MaxConcurrency: 2})
+
+		done := make(chan *jkv.StringCmd, 3)
+		for i := 0; i < 3; i++ {
+			go func() { done <- c.Get(ctx, "key") }()
+		}
+		<-inner.started
+		<-inner.started
+		select {
+		case <-inner.started:
+			t.Fatal("a third Get ran with MaxConcurrency 2")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(inner.release)
+		<-inner.started
+		for i := 0; i < 3; i++ {
+			a.Equal("value", (<-done).Val())
+		}
+	})
+
+	t.Run("Fails fast", func(t *testing.T) {
+		a := assert.New(t)
+		inner := newBlocking()
+		c := New(inner, Options{MaxConcurrency: 1, FailFast: true})
+
+		done := make(chan *jkv.StringCmd)
+		go func() { done <- c.Get(ctx, "key") }()
+		<-inner.started
+		a.ErrorIs(c.Get(ctx, "key").Err(), ErrLimited)
+		close(inner.release)
+		a.Nil((<-done).Err())
+	})
+
+	t.Run("Honors ctx", func(t *testing.T) {
+		a := assert.New(t)
+		inner := newBlocking()
+		c := New(inner, Options{MaxConcurrency: 1})
+
+		done := make(chan *jkv.StringCmd)
+		go func() { done <- c.Get(ctx, "key") }()
+		<-inner.started
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		a.ErrorIs(c.Get(ctx, "key").Err(), context.DeadlineExceeded)
+		close(inner.release)
+		a.Nil((<-done).Err())
+	})
+}
+
+func TestOps(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	inner := &slow{started: make(chan struct{}, 10)}
+	c := New(inner, Options{MaxConcurrency: 1})
+
+	running, waiting := make(chan *jkv.StringCmd), make(chan *jkv.StringCmd)
+	go func() { running <- c.Get(ctx, "slow") }()
+	<-inner.started
+	go func() { waiting <- c.Get(ctx, "queued") }()
+	time.Sleep(20 * time.Millisecond)
+
+	ops := c.Ops(10 * time.Millisecond)
+	a.Len(ops, 2)
+	a.Equal("GET", ops[0].Command)
+	a.Equal([]string{"slow"}, ops[0].Keys)
+	a.Equal([]string{"queued"}, ops[1].Keys)
+	a.Empty(c.Ops(time.Hour))
+
+	// cancelling the one waiting for a slot leaves the running one alone
+	a.True(c.Cancel(ops[1].ID))
+	a.ErrorIs((<-waiting).Err(), context.Canceled)
+	a.Len(c.Ops(0), 1)
+
+	a.True(c.Cancel(ops[0].ID))
+	a.ErrorIs((<-running).Err(), context.Canceled)
+	a.Empty(c.Ops(0))
+	a.False(c.Cancel(ops[0].ID))
+}
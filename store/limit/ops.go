@@ -0,0 +1,82 @@
+package limit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Op describes a command in flight through a Client, waiting for a slot or running on Inner
+type Op struct {
+	ID      uint64
+	Command string
+	Keys    []string
+	Start   time.Time
+}
+
+// op is a command in flight and the cancel func of the ctx it runs with
+type op struct {
+	Op
+	cancel context.CancelFunc
+}
+
+// track registers cmd on keys as in flight, returning the ctx it is to run with
+func (c *Client) track(ctx context.Context, cmd string, keys []string) (context.Context, *op) {
+	ctx, cancel := context.WithCancel(ctx)
+	c.opsMu.Lock()
+	defer c.opsMu.Unlock()
+	c.nextID++
+	o := &op{Op: Op{ID: c.nextID, Command: cmd, Keys: keys, Start: time.Now()}, cancel: cancel}
+	c.ops[o.ID] = o
+	return ctx, o
+}
+
+// untrack removes o from the commands in flight once it is done
+func (c *Client) untrack(o *op) {
+	c.opsMu.Lock()
+	defer c.opsMu.Unlock()
+	delete(c.ops, o.ID)
+	o.cancel()
+}
+
+// Ops returns the commands that have been in flight for at least threshold, the longest running first, so stuck
+// ones can be found and cancelled
+func (c *Client) Ops(threshold time.Duration) []Op {
+	c.opsMu.Lock()
+	defer c.opsMu.Unlock()
+	now := time.Now()
+	ops := []Op{}
+	for _, o := range c.ops {
+		if now.Sub(o.Start) >= threshold {
+			ops = append(ops, o.Op)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].ID < ops[j].ID })
+	return ops
+}
+
+// Cancel cancels the ctx of the command in flight with id, returning false if there is none. A command waiting for
+// a slot gives up with context.Canceled; one running on Inner stops as soon as Inner notices its ctx is done.
+func (c *Client) Cancel(id uint64) bool {
+	c.opsMu.Lock()
+	defer c.opsMu.Unlock()
+	o, ok := c.ops[id]
+	if ok {
+		o.cancel()
+	}
+	return ok
+}
+
+// describe returns the command and key of the arguments of Do
+func describe(args []interface{}) (string, []string) {
+	cmd, keys := "DO", []string{}
+	if len(args) > 0 {
+		cmd = strings.ToUpper(fmt.Sprint(args[0]))
+	}
+	if len(args) > 1 {
+		keys = append(keys, fmt.Sprint(args[1]))
+	}
+	return cmd, keys
+}
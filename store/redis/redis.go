@@ -3,6 +3,11 @@ package redis
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/panduit-joeb/jkv"
@@ -13,12 +18,26 @@ import (
 type Options struct {
 	Addr, Password string
 	DB             int
+	ReadOnly       bool
+	Logger         *log.Logger
+	// ReplicaAddr is a replica of Addr that serves reads, writes always go to Addr
+	ReplicaAddr string
+	// RouteReadsToPrimary sends reads to Addr even when ReplicaAddr is set, which guarantees read-after-write
+	// consistency at the cost of the extra latency and load on the primary. Use WithPrimary for a single call.
+	RouteReadsToPrimary bool
+	// RecordDuration sets the Duration of each command result
+	RecordDuration bool
 }
 
 type Client struct {
-	DBDir       string
-	IsOpen      bool
-	RedisClient *real_redis.Client
+	DBDir               string
+	IsOpen              bool
+	ReadOnly            bool
+	Logger              *log.Logger
+	RouteReadsToPrimary bool
+	RecordDuration      bool
+	RedisClient         *real_redis.Client
+	ReplicaClient       *real_redis.Client
 }
 
 var _ jkv.Client = (*Client)(nil)
@@ -27,12 +46,63 @@ const DEFAULT_DB = "localhost:6379"
 
 func notOpen() error { return errors.New("DB is not open") }
 
+// redisExpiration turns jkv.NoExpiration into the 0 redis takes for no expiration, jkv.KeepTTL is already redis.KeepTTL
+func redisExpiration(expiration time.Duration) time.Duration {
+	if expiration == jkv.NoExpiration {
+		return 0
+	}
+	return expiration
+}
+
+// notFound turns redis.Nil from reading a missing key or field into jkv.ErrKeyNotFound
+func notFound(err error) error {
+	if err == real_redis.Nil {
+		return jkv.ErrKeyNotFound
+	}
+	return err
+}
+
 func (c *Client) GetDBDir() string {
 	return c.DBDir
 }
 
-func NewClient(opts *Options) (db *Client) {
-	return &Client{DBDir: opts.Addr, IsOpen: false, RedisClient: real_redis.NewClient(&real_redis.Options{Addr: opts.Addr, Password: opts.Password, DB: opts.DB})}
+// Capabilities returns the features of the redis client beyond jkv.Client
+func (c *Client) Capabilities() jkv.Capabilities {
+	return jkv.NewCapabilities(jkv.CapConfigSet, jkv.CapReplicaReads, jkv.CapServerCommand)
+}
+
+// NewClient returns a closed client configured by opts, which may be nil, followed by any functional options
+func NewClient(opts *Options, options ...jkv.Option) (db *Client) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	s := jkv.Settings{Addr: opts.Addr, Password: opts.Password, DB: opts.DB, ReadOnly: opts.ReadOnly, Logger: opts.Logger}.Apply(options...)
+	if s.Logger == nil {
+		s.Logger = log.New(os.Stdout, "", 0)
+	}
+	c := &Client{DBDir: s.Addr, IsOpen: false, ReadOnly: s.ReadOnly, Logger: s.Logger, RouteReadsToPrimary: opts.RouteReadsToPrimary, RecordDuration: opts.RecordDuration, RedisClient: real_redis.NewClient(&real_redis.Options{Addr: s.Addr, Password: s.Password, DB: s.DB})}
+	if opts.ReplicaAddr != "" {
+		c.ReplicaClient = real_redis.NewClient(&real_redis.Options{Addr: opts.ReplicaAddr, Password: s.Password, DB: s.DB})
+	}
+	return c
+}
+
+type primaryKey struct{}
+
+// WithPrimary returns a context that routes reads made with it to the primary instead of a replica
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// reader returns the connection reads should use
+func (c *Client) reader(ctx context.Context) *real_redis.Client {
+	if c.ReplicaClient == nil || c.RouteReadsToPrimary {
+		return c.RedisClient
+	}
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary {
+		return c.RedisClient
+	}
+	return c.ReplicaClient
 }
 
 // Open a database by creating the directories required if they don't exist and mark the database open
@@ -42,72 +112,309 @@ func (c *Client) Open() error {
 }
 
 // Close a database, basically just mark it closed
-func (c *Client) Close() { c.IsOpen = false; c.RedisClient.Close() }
+func (c *Client) Close() {
+	c.IsOpen = false
+	c.RedisClient.Close()
+	if c.ReplicaClient != nil {
+		c.ReplicaClient.Close()
+	}
+}
 
 // FLUSHDB a database by removing the j.dbDir and everything underneath, ignore errors for now
-func (c *Client) FlushDB(ctx context.Context) *jkv.StatusCmd {
+func (c *Client) FlushDB(ctx context.Context) (res *jkv.StatusCmd) {
+	defer timed(c.start(), &res)
+	if c.ReadOnly {
+		return jkv.NewStatusCmd("", jkv.ErrReadOnly)
+	}
 	rec := c.RedisClient.FlushDB(context.Background())
 	return jkv.NewStatusCmd(rec.Val(), rec.Err())
 }
 
 // Return data in scalar key data, error is file is missing or inaccessible
-func (c *Client) Get(ctx context.Context, key string) *jkv.StringCmd {
+func (c *Client) Get(ctx context.Context, key string) (res *jkv.StringCmd) {
+	defer timed(c.start(), &res)
 	if c.IsOpen {
-		rec := c.RedisClient.Get(context.Background(), key)
-		return jkv.NewStringCmd(rec.Val(), rec.Err())
+		rec := c.reader(ctx).Get(ctx, key)
+		return jkv.NewStringCmd(rec.Val(), notFound(rec.Err()))
+	}
+	return jkv.NewStringCmd("", notOpen())
+}
+
+// GETEX returns the value of key and sets or clears its expiration
+func (c *Client) GetEX(ctx context.Context, key string, opts jkv.ExpiryOptions) (res *jkv.StringCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		args := []interface{}{"getex", key}
+		switch {
+		case opts.Persist:
+			args = append(args, "persist")
+		case opts.EX > 0:
+			args = append(args, "ex", int64(opts.EX/time.Second))
+		case opts.PX > 0:
+			args = append(args, "px", int64(opts.PX/time.Millisecond))
+		case !opts.EXAT.IsZero():
+			args = append(args, "exat", opts.EXAT.Unix())
+		case !opts.PXAT.IsZero():
+			args = append(args, "pxat", opts.PXAT.UnixMilli())
+		}
+		rec := real_redis.NewStringCmd(ctx, args...)
+		c.RedisClient.Process(ctx, rec)
+		return jkv.NewStringCmd(rec.Val(), notFound(rec.Err()))
 	}
 	return jkv.NewStringCmd("", notOpen())
 }
 
 // Set a scalar key to a value
-func (c *Client) Set(ctx context.Context, key, value string, expiration time.Duration) *jkv.StatusCmd {
+func (c *Client) Set(ctx context.Context, key, value string, expiration time.Duration) (res *jkv.StatusCmd) {
+	defer timed(c.start(), &res)
 	if c.IsOpen {
-		rec := c.RedisClient.Set(ctx, key, value, expiration)
+		if c.ReadOnly {
+			return jkv.NewStatusCmd("", jkv.ErrReadOnly)
+		}
+		rec := c.RedisClient.Set(ctx, key, value, redisExpiration(expiration))
 		return jkv.NewStatusCmd(rec.Val(), rec.Err())
 	}
 	return jkv.NewStatusCmd("", notOpen())
 }
 
+// SETNX sets key to value, expiring after expiration if it is positive, only if key doesn't exist
+func (c *Client) SetNX(ctx context.Context, key, value string, expiration time.Duration) (res *jkv.BoolCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		if c.ReadOnly {
+			return jkv.NewBoolCmd(false, jkv.ErrReadOnly)
+		}
+		rec := c.RedisClient.SetNX(ctx, key, value, redisExpiration(expiration))
+		return jkv.NewBoolCmd(rec.Val(), rec.Err())
+	}
+	return jkv.NewBoolCmd(false, notOpen())
+}
+
+// compareAndDelete deletes KEYS[1] if its value is ARGV[1] in one step on the server
+var compareAndDelete = real_redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// CompareAndDelete deletes key if its value is value, returning true if it did
+func (c *Client) CompareAndDelete(ctx context.Context, key, value string) (res *jkv.BoolCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		if c.ReadOnly {
+			return jkv.NewBoolCmd(false, jkv.ErrReadOnly)
+		}
+		n, err := compareAndDelete.Run(ctx, c.RedisClient, []string{key}, value).Int64()
+		return jkv.NewBoolCmd(n == 1, err)
+	}
+	return jkv.NewBoolCmd(false, notOpen())
+}
+
+// INCR adds 1 to the integer value of key, a missing key counting as 0, and returns the new value
+func (c *Client) Incr(ctx context.Context, key string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		if c.ReadOnly {
+			return jkv.NewIntCmd(0, jkv.ErrReadOnly)
+		}
+		rec := c.RedisClient.Incr(ctx, key)
+		return jkv.NewIntCmd(rec.Val(), rec.Err())
+	}
+	return jkv.NewIntCmd(0, notOpen())
+}
+
+// DECR subtracts 1 from the integer value of key, a missing key counting as 0, and returns the new value
+func (c *Client) Decr(ctx context.Context, key string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		if c.ReadOnly {
+			return jkv.NewIntCmd(0, jkv.ErrReadOnly)
+		}
+		rec := c.RedisClient.Decr(ctx, key)
+		return jkv.NewIntCmd(rec.Val(), rec.Err())
+	}
+	return jkv.NewIntCmd(0, notOpen())
+}
+
 // Delete a key by removing the scalar file
-func (c *Client) Del(ctx context.Context, keys ...string) *jkv.IntCmd {
+func (c *Client) Del(ctx context.Context, keys ...string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
 	if c.IsOpen {
+		if c.ReadOnly {
+			return jkv.NewIntCmd(0, jkv.ErrReadOnly)
+		}
 		rec := c.RedisClient.Del(context.Background(), keys...)
 		return jkv.NewIntCmd(rec.Val(), rec.Err())
 	}
 	return jkv.NewIntCmd(0, notOpen())
 }
 
+// SETBIT sets or clears the bit at offset and returns its previous value
+func (c *Client) SetBit(ctx context.Context, key string, offset int64, value int) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		if c.ReadOnly {
+			return jkv.NewIntCmd(0, jkv.ErrReadOnly)
+		}
+		rec := c.RedisClient.SetBit(ctx, key, offset, value)
+		return jkv.NewIntCmd(rec.Val(), rec.Err())
+	}
+	return jkv.NewIntCmd(0, notOpen())
+}
+
+// PFADD adds elements to the HyperLogLog in key and returns 1 if its estimate may have changed
+func (c *Client) PFAdd(ctx context.Context, key string, elements ...string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		if c.ReadOnly {
+			return jkv.NewIntCmd(0, jkv.ErrReadOnly)
+		}
+		els := make([]interface{}, len(elements))
+		for i, e := range elements {
+			els[i] = e
+		}
+		rec := c.RedisClient.PFAdd(ctx, key, els...)
+		return jkv.NewIntCmd(rec.Val(), rec.Err())
+	}
+	return jkv.NewIntCmd(0, notOpen())
+}
+
+// PFCOUNT returns the approximate number of distinct elements in the union of the HyperLogLogs in keys
+func (c *Client) PFCount(ctx context.Context, keys ...string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		rec := c.reader(ctx).PFCount(ctx, keys...)
+		return jkv.NewIntCmd(rec.Val(), rec.Err())
+	}
+	return jkv.NewIntCmd(0, notOpen())
+}
+
+// PFMERGE stores the union of dest and the HyperLogLogs in keys in dest
+func (c *Client) PFMerge(ctx context.Context, dest string, keys ...string) (res *jkv.StatusCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		if c.ReadOnly {
+			return jkv.NewStatusCmd("", jkv.ErrReadOnly)
+		}
+		rec := c.RedisClient.PFMerge(ctx, dest, keys...)
+		return jkv.NewStatusCmd(rec.Val(), rec.Err())
+	}
+	return jkv.NewStatusCmd("", notOpen())
+}
+
+// GETBIT returns the bit at offset
+func (c *Client) GetBit(ctx context.Context, key string, offset int64) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		rec := c.reader(ctx).GetBit(ctx, key, offset)
+		return jkv.NewIntCmd(rec.Val(), rec.Err())
+	}
+	return jkv.NewIntCmd(0, notOpen())
+}
+
+// BITCOUNT counts the set bits, in a byte range if bitCount is not nil
+func (c *Client) BitCount(ctx context.Context, key string, bitCount *jkv.BitCount) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		var rng *real_redis.BitCount
+		if bitCount != nil {
+			rng = &real_redis.BitCount{Start: bitCount.Start, End: bitCount.End}
+		}
+		rec := c.reader(ctx).BitCount(ctx, key, rng)
+		return jkv.NewIntCmd(rec.Val(), rec.Err())
+	}
+	return jkv.NewIntCmd(0, notOpen())
+}
+
 // KEYS return a list of keys
-func (c *Client) Keys(ctx context.Context, pattern string) *jkv.StringSliceCmd {
+func (c *Client) Keys(ctx context.Context, pattern string) (res *jkv.StringSliceCmd) {
+	defer timed(c.start(), &res)
 	if c.IsOpen {
-		rec := c.RedisClient.Keys(context.Background(), pattern)
+		rec := c.reader(ctx).Keys(ctx, pattern)
 		return jkv.NewStringSliceCmd(rec.Val(), rec.Err())
 	}
 	return jkv.NewStringSliceCmd([]string{}, notOpen())
 }
 
+// SCAN return a page of keys matching match and the cursor of the next page
+func (c *Client) Scan(ctx context.Context, cursor string, match string, count int64) (res *jkv.ScanCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		n, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			return jkv.NewScanCmd([]string{}, "0", errors.New("ERR invalid cursor"))
+		}
+		keys, next, err := c.reader(ctx).Scan(ctx, n, match, count).Result()
+		return jkv.NewScanCmd(keys, strconv.FormatUint(next, 10), err)
+	}
+	return jkv.NewScanCmd([]string{}, "0", notOpen())
+}
+
+func (c *Client) ScanType(ctx context.Context, cursor string, match string, count int64, keyType string) (res *jkv.ScanCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		n, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			return jkv.NewScanCmd([]string{}, "0", errors.New("ERR invalid cursor"))
+		}
+		keys, next, err := c.reader(ctx).ScanType(ctx, n, match, count, keyType).Result()
+		return jkv.NewScanCmd(keys, strconv.FormatUint(next, 10), err)
+	}
+	return jkv.NewScanCmd([]string{}, "0", notOpen())
+}
+
 // Return true if scalar key file exists, false otherwise
-func (c *Client) Exists(ctx context.Context, keys ...string) *jkv.IntCmd {
+func (c *Client) Exists(ctx context.Context, keys ...string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		rec := c.reader(ctx).Exists(ctx, keys...)
+		return jkv.NewIntCmd(rec.Val(), rec.Err())
+	}
+	return jkv.NewIntCmd(0, notOpen())
+}
+
+// EXPIRE sets key to expire after expiration, returning false if it doesn't exist
+func (c *Client) Expire(ctx context.Context, key string, expiration time.Duration) (res *jkv.BoolCmd) {
+	defer timed(c.start(), &res)
 	if c.IsOpen {
-		rec := c.RedisClient.Exists(context.Background(), keys...)
+		if c.ReadOnly {
+			return jkv.NewBoolCmd(false, jkv.ErrReadOnly)
+		}
+		rec := c.RedisClient.Expire(ctx, key, expiration)
+		return jkv.NewBoolCmd(rec.Val(), rec.Err())
+	}
+	return jkv.NewBoolCmd(false, notOpen())
+}
+
+// TTL returns the remaining time to live of key in seconds, -2 if it doesn't exist and -1 if it has no expiration
+func (c *Client) TTL(ctx context.Context, key string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		rec := real_redis.NewIntCmd(ctx, "ttl", key)
+		c.reader(ctx).Process(ctx, rec)
 		return jkv.NewIntCmd(rec.Val(), rec.Err())
 	}
 	return jkv.NewIntCmd(0, notOpen())
 }
 
 // Return data in hashed key data, error is file is missing or inaccessible
-func (c *Client) HGet(ctx context.Context, hash, key string) *jkv.StringCmd {
+func (c *Client) HGet(ctx context.Context, hash, key string) (res *jkv.StringCmd) {
+	defer timed(c.start(), &res)
 	if c.IsOpen {
-		rec := c.RedisClient.HGet(ctx, hash, key)
-		return jkv.NewStringCmd(rec.Val(), rec.Err())
+		rec := c.reader(ctx).HGet(ctx, hash, key)
+		return jkv.NewStringCmd(rec.Val(), notFound(rec.Err()))
 	}
 	return jkv.NewStringCmd("", notOpen())
 }
 
 // Create a hash directory and store the data in a key file
-func (c *Client) HSet(ctx context.Context, hash string, values ...string) *jkv.IntCmd {
+func (c *Client) HSet(ctx context.Context, hash string, values ...string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
 	var rec *real_redis.IntCmd
 	if c.IsOpen {
+		if c.ReadOnly {
+			return jkv.NewIntCmd(0, jkv.ErrReadOnly)
+		}
 		var valueMap []interface{}
 		for _, v := range values {
 			valueMap = append(valueMap, v)
@@ -119,9 +426,13 @@ func (c *Client) HSet(ctx context.Context, hash string, values ...string) *jkv.I
 }
 
 // Delete a hashed key by removing the file, if no keys exist after the operation remove the hash directory
-func (c *Client) HDel(ctx context.Context, hash string, values ...string) *jkv.IntCmd {
+func (c *Client) HDel(ctx context.Context, hash string, values ...string) (res *jkv.IntCmd) {
+	defer timed(c.start(), &res)
 	var rec *real_redis.IntCmd
 	if c.IsOpen {
+		if c.ReadOnly {
+			return jkv.NewIntCmd(0, jkv.ErrReadOnly)
+		}
 		rec = c.RedisClient.HDel(ctx, hash, values...)
 		return jkv.NewIntCmd(rec.Val(), rec.Err())
 	}
@@ -129,24 +440,185 @@ func (c *Client) HDel(ctx context.Context, hash string, values ...string) *jkv.I
 }
 
 // HKEYS return a list of keys for a hash
-func (c *Client) HKeys(ctx context.Context, hash string) *jkv.StringSliceCmd {
+func (c *Client) HKeys(ctx context.Context, hash string) (res *jkv.StringSliceCmd) {
+	defer timed(c.start(), &res)
 	if c.IsOpen {
-		rec := c.RedisClient.HKeys(ctx, hash)
+		rec := c.reader(ctx).HKeys(ctx, hash)
 		return jkv.NewStringSliceCmd(rec.Val(), rec.Err())
 	}
 	return jkv.NewStringSliceCmd([]string{}, notOpen())
 }
 
+// hscan returns the field and value pairs of hash whose fields match the glob pattern, scanning all of it
+func (c *Client) hscan(ctx context.Context, hash, pattern string) ([]string, error) {
+	var pairs []string
+	for cursor := uint64(0); ; {
+		page, next, err := c.reader(ctx).HScan(ctx, hash, cursor, pattern, 100).Result()
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, page...)
+		if cursor = next; cursor == 0 {
+			return pairs, nil
+		}
+	}
+}
+
+// HKeysMatch returns the fields of hash whose names match the glob pattern
+func (c *Client) HKeysMatch(ctx context.Context, hash, pattern string) (res *jkv.StringSliceCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		pairs, err := c.hscan(ctx, hash, pattern)
+		fields := []string{}
+		for i := 0; i+1 < len(pairs); i += 2 {
+			fields = append(fields, pairs[i])
+		}
+		return jkv.NewStringSliceCmd(fields, err)
+	}
+	return jkv.NewStringSliceCmd([]string{}, notOpen())
+}
+
+// HGETALL returns the fields of hash and their values
+func (c *Client) HGetAll(ctx context.Context, hash string) (res *jkv.StringStringMapCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		rec := c.reader(ctx).HGetAll(ctx, hash)
+		return jkv.NewStringStringMapCmd(rec.Val(), rec.Err())
+	}
+	return jkv.NewStringStringMapCmd(map[string]string{}, notOpen())
+}
+
+// HGetAllMatch returns the fields of hash whose names match the glob pattern and their values
+func (c *Client) HGetAllMatch(ctx context.Context, hash, pattern string) (res *jkv.StringStringMapCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		pairs, err := c.hscan(ctx, hash, pattern)
+		values := map[string]string{}
+		for i := 0; i+1 < len(pairs); i += 2 {
+			values[pairs[i]] = pairs[i+1]
+		}
+		return jkv.NewStringStringMapCmd(values, err)
+	}
+	return jkv.NewStringStringMapCmd(map[string]string{}, notOpen())
+}
+
 // Return true if hashed key file exists, false otherwise
-func (c *Client) HExists(ctx context.Context, hash, key string) *jkv.BoolCmd {
+func (c *Client) HExists(ctx context.Context, hash, key string) (res *jkv.BoolCmd) {
+	defer timed(c.start(), &res)
 	if c.IsOpen {
-		rec := c.RedisClient.HExists(context.Background(), hash, key)
+		rec := c.reader(ctx).HExists(ctx, hash, key)
 		return jkv.NewBoolCmd(rec.Val(), rec.Err())
 	}
 	return jkv.NewBoolCmd(false, notOpen())
 }
 
-func (c *Client) Ping(ctx context.Context) *jkv.StatusCmd {
+// HEXPIRE sets the time to live of hash fields in seconds
+func (c *Client) HExpire(ctx context.Context, hash string, seconds int64, fields ...string) (res *jkv.IntSliceCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		if c.ReadOnly {
+			return jkv.NewIntSliceCmd([]int64{}, jkv.ErrReadOnly)
+		}
+		args := []interface{}{"hexpire", hash, seconds, "fields", len(fields)}
+		for _, field := range fields {
+			args = append(args, field)
+		}
+		rec := real_redis.NewIntSliceCmd(ctx, args...)
+		c.RedisClient.Process(ctx, rec)
+		return jkv.NewIntSliceCmd(rec.Val(), rec.Err())
+	}
+	return jkv.NewIntSliceCmd([]int64{}, notOpen())
+}
+
+// HTTL returns the remaining time to live of hash fields in seconds
+func (c *Client) HTTL(ctx context.Context, hash string, fields ...string) (res *jkv.IntSliceCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		args := []interface{}{"httl", hash, "fields", len(fields)}
+		for _, field := range fields {
+			args = append(args, field)
+		}
+		rec := real_redis.NewIntSliceCmd(ctx, args...)
+		c.reader(ctx).Process(ctx, rec)
+		return jkv.NewIntSliceCmd(rec.Val(), rec.Err())
+	}
+	return jkv.NewIntSliceCmd([]int64{}, notOpen())
+}
+
+func (c *Client) Ping(ctx context.Context) (res *jkv.StatusCmd) {
+	defer timed(c.start(), &res)
 	rec := c.RedisClient.Ping(ctx)
 	return jkv.NewStatusCmd(rec.Val(), rec.Err())
 }
+
+// VERSION returns the version of jkv and of the redis server
+func (c *Client) Version(ctx context.Context) (res *jkv.StringCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		info, err := c.RedisClient.Info(ctx, "server").Result()
+		if err != nil {
+			return jkv.NewStringCmd(jkv.BuildVersion(), err)
+		}
+		for _, line := range strings.Split(info, "\n") {
+			if line = strings.TrimSpace(line); strings.HasPrefix(line, "redis_version:") {
+				return jkv.NewStringCmd(jkv.BuildVersion()+" redis="+strings.TrimPrefix(line, "redis_version:"), nil)
+			}
+		}
+		return jkv.NewStringCmd(jkv.BuildVersion(), nil)
+	}
+	return jkv.NewStringCmd(jkv.BuildVersion(), notOpen())
+}
+
+// CONFIG GET returns the redis server settings whose names match the glob pattern parameter
+func (c *Client) ConfigGet(ctx context.Context, parameter string) (res *jkv.StringStringMapCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		settings := map[string]string{}
+		values, err := c.RedisClient.ConfigGet(ctx, parameter).Result()
+		for i := 0; i+1 < len(values); i += 2 {
+			settings[fmt.Sprint(values[i])] = fmt.Sprint(values[i+1])
+		}
+		return jkv.NewStringStringMapCmd(settings, err)
+	}
+	return jkv.NewStringStringMapCmd(map[string]string{}, notOpen())
+}
+
+// Do sends args to redis as a command, for commands jkv doesn't model. A ReadOnly client refuses it since jkv can't
+// tell which commands write.
+func (c *Client) Do(ctx context.Context, args ...interface{}) (res *jkv.Cmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		if c.ReadOnly {
+			return jkv.NewCmd(nil, jkv.ErrReadOnly)
+		}
+		rec := c.RedisClient.Do(ctx, args...)
+		return jkv.NewCmd(rec.Val(), notFound(rec.Err()))
+	}
+	return jkv.NewCmd(nil, notOpen())
+}
+
+// INFO returns the named sections of the server's INFO, or the default ones
+func (c *Client) Info(ctx context.Context, sections ...string) (res *jkv.StringCmd) {
+	defer timed(c.start(), &res)
+	if c.IsOpen {
+		rec := c.RedisClient.Info(ctx, sections...)
+		return jkv.NewStringCmd(rec.Val(), rec.Err())
+	}
+	return jkv.NewStringCmd("", notOpen())
+}
+
+// start returns the time a command started if RecordDuration is set
+func (c *Client) start() time.Time {
+	if c.RecordDuration {
+		return time.Now()
+	}
+	return time.Time{}
+}
+
+// timed records the duration of *res, each command defers it with the result of start, which is zero unless
+// RecordDuration is set
+func timed[T interface{ SetDuration(time.Duration) }](start time.Time, res *T) {
+	if !start.IsZero() {
+		(*res).SetDuration(time.Since(start))
+	}
+}
@@ -1,8 +1,16 @@
 package redis
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/panduit-joeb/jkv"
@@ -280,3 +288,155 @@ func TestHash(t *testing.T) {
 		a.Nil(c.HDel(ctx, hash, key).Err())
 	})
 }
+
+func TestOptions(t *testing.T) {
+	t.Run("Functional options", func(t *testing.T) {
+		a := assert.New(t)
+		c := NewClient(&Options{Addr: "localhost:6379"}, jkv.WithAddr("127.0.0.1:6380"), jkv.WithPassword("secret"), jkv.WithDB(2), jkv.WithReadOnly(true))
+		defer c.Close()
+		c.Open()
+
+		a.Equal("127.0.0.1:6380", c.GetDBDir())
+		a.Equal("127.0.0.1:6380", c.RedisClient.Options().Addr)
+		a.Equal("secret", c.RedisClient.Options().Password)
+		a.Equal(2, c.RedisClient.Options().DB)
+		a.ErrorIs(c.Set(context.Background(), "key", "value", 0).Err(), jkv.ErrReadOnly)
+	})
+}
+
+func TestRouteReadsToPrimary(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Reads go to the replica", func(t *testing.T) {
+		c := NewClient(&Options{Addr: "primary:6379", ReplicaAddr: "replica:6379"})
+		defer c.Close()
+		assert.Same(t, c.ReplicaClient, c.reader(ctx))
+		assert.Same(t, c.RedisClient, c.reader(WithPrimary(ctx)))
+	})
+
+	t.Run("RouteReadsToPrimary", func(t *testing.T) {
+		c := NewClient(&Options{Addr: "primary:6379", ReplicaAddr: "replica:6379", RouteReadsToPrimary: true})
+		defer c.Close()
+		assert.Same(t, c.RedisClient, c.reader(ctx))
+		assert.Equal(t, "primary:6379", c.reader(ctx).Options().Addr)
+	})
+
+	t.Run("No replica", func(t *testing.T) {
+		c := NewClient(&Options{Addr: "primary:6379"})
+		defer c.Close()
+		assert.Same(t, c.RedisClient, c.reader(ctx))
+	})
+}
+
+// fakeRedis serves RESP on a local port until the test ends, answering GET with name and anything else with OK, so
+// a reply tells which server a command reached. It returns the address and a count of the commands served.
+func fakeRedis(t *testing.T, name string) (string, *int32) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	var commands int32
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					args, err := readCommand(r)
+					if err != nil {
+						return
+					}
+					atomic.AddInt32(&commands, 1)
+					reply := "+OK\r\n"
+					if strings.EqualFold(args[0], "get") {
+						reply = fmt.Sprintf("$%d\r\n%s\r\n", len(name), name)
+					}
+					if _, err := conn.Write([]byte(reply)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String(), &commands
+}
+
+// readCommand reads a command sent as a RESP array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("not a command: %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, fmt.Errorf("not a bulk string: %q", line)
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func TestRouteReadsToPrimaryServers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("WithPrimary", func(t *testing.T) {
+		a := assert.New(t)
+		primary, _ := fakeRedis(t, "primary")
+		replica, _ := fakeRedis(t, "replica")
+		c := NewClient(&Options{Addr: primary, ReplicaAddr: replica})
+		defer c.Close()
+		a.Nil(c.Open())
+		a.Equal("replica", c.Get(ctx, "key").Val())
+		a.Equal("primary", c.Get(WithPrimary(ctx), "key").Val())
+	})
+
+	t.Run("RouteReadsToPrimary", func(t *testing.T) {
+		a := assert.New(t)
+		primary, _ := fakeRedis(t, "primary")
+		replica, replicaCommands := fakeRedis(t, "replica")
+		c := NewClient(&Options{Addr: primary, ReplicaAddr: replica, RouteReadsToPrimary: true})
+		defer c.Close()
+		a.Nil(c.Open())
+		a.Equal("primary", c.Get(ctx, "key").Val())
+		a.Equal("primary", c.Get(WithPrimary(ctx), "key").Val())
+		a.Equal(int32(0), atomic.LoadInt32(replicaCommands))
+	})
+}
+
+func TestDo(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	c := NewClient(&Options{Addr: "localhost:6379"})
+	defer c.Close()
+	a.Nil(c.Open())
+
+	a.Nil(c.Do(ctx, "set", "key", "value").Err())
+	rec := c.Do(ctx, "get", "key")
+	a.Nil(rec.Err())
+	a.Equal("value", rec.Val())
+}
+
+func TestNil(t *testing.T) {
+	a := assert.New(t)
+	a.True(errors.Is(notFound(real_redis.Nil), jkv.Nil))
+	a.Nil(notFound(nil))
+	a.False(errors.Is(notFound(jkv.ErrReadOnly), jkv.Nil))
+}
@@ -0,0 +1,287 @@
+// Package retry wraps a jkv.Client so transient errors are retried with exponential backoff.
+//
+// A command that fails with a network timeout may have run all the same, only its reply was lost. Commands that
+// give the same result and leave the same data however many times they run, like GET or SET, are retried on any
+// Retryable error. The others, like INCR, SETNX, DEL or Do, are only retried on errors that prove the command
+// didn't run, see NotRun, so a retry never applies a change twice. EXPIRE and HEXPIRE are among them: their
+// expiration counts from when they run, so a late retry would push the deadline back.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// Policy controls how often and how long a failed command is retried
+type Policy struct {
+	Attempts  int              // tries including the first, 3 if zero
+	BaseDelay time.Duration    // delay before the first retry, doubled for each one after it, 10ms if zero
+	MaxDelay  time.Duration    // upper bound on a delay, 1s if zero
+	Retryable func(error) bool // errors worth retrying, Retryable if nil
+	NotRun    func(error) bool // the errors commands that can't safely run twice are retried on, NotRun if nil
+}
+
+// Client is a jkv.Client that retries the commands of Inner
+type Client struct {
+	Inner  jkv.Client
+	Policy Policy
+}
+
+// New returns inner wrapped so commands failing with a retryable error are tried again according to policy
+func New(inner jkv.Client, policy Policy) *Client {
+	if policy.Attempts <= 0 {
+		policy.Attempts = 3
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = 10 * time.Millisecond
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = time.Second
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = Retryable
+	}
+	if policy.NotRun == nil {
+		policy.NotRun = NotRun
+	}
+	return &Client{Inner: inner, Policy: policy}
+}
+
+// transient are the prefixes of redis errors that go away by themselves. Redis replies with them instead of running
+// the command.
+var transient = []string{"BUSY ", "LOADING ", "TRYAGAIN ", "MASTERDOWN "}
+
+// Retryable reports whether err is likely to go away on its own: jkv.ErrLockTimeout, a busy file, a network timeout
+// or a redis error like LOADING. Everything else, e.g. WRONGTYPE or READONLY, fails straight away.
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, jkv.ErrLockTimeout) || errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EBUSY) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return NotRun(err)
+}
+
+// NotRun reports whether err proves the command wasn't run, so it can be retried even if running it twice would do
+// harm: jkv.ErrLockTimeout or a redis error like LOADING. A timeout or a busy file doesn't, the command may have
+// been carried out before the error.
+func NotRun(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, jkv.ErrLockTimeout) {
+		return true
+	}
+	for _, prefix := range transient {
+		if strings.HasPrefix(err.Error(), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the jittered backoff before retry n, counting from 0
+func (p Policy) delay(n int) time.Duration {
+	d := p.BaseDelay << n
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// do runs fn until it succeeds, fails with an error that isn't retryable, runs out of attempts or ctx is done
+func do[T interface{ Err() error }](ctx context.Context, c *Client, fn func() T) T {
+	return retry(ctx, c, c.Policy.Retryable, fn)
+}
+
+// doOnce is do for a command that mustn't run twice, it is only retried on errors that prove it didn't run
+func doOnce[T interface{ Err() error }](ctx context.Context, c *Client, fn func() T) T {
+	return retry(ctx, c, c.Policy.NotRun, fn)
+}
+
+func retry[T interface{ Err() error }](ctx context.Context, c *Client, retryable func(error) bool, fn func() T) T {
+	rec := fn()
+	for n := 0; n < c.Policy.Attempts-1 && retryable(rec.Err()); n++ {
+		timer := time.NewTimer(c.Policy.delay(n))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return rec
+		case <-timer.C:
+		}
+		rec = fn()
+	}
+	return rec
+}
+
+// result adapts a plain error to do
+type result struct{ err error }
+
+func (r result) Err() error { return r.err }
+
+func (c *Client) Open() error {
+	return do(context.Background(), c, func() result { return result{c.Inner.Open()} }).err
+}
+
+func (c *Client) Close()           { c.Inner.Close() }
+func (c *Client) GetDBDir() string { return c.Inner.GetDBDir() }
+
+func (c *Client) Capabilities() jkv.Capabilities { return c.Inner.Capabilities() }
+
+func (c *Client) FlushDB(ctx context.Context) *jkv.StatusCmd {
+	return do(ctx, c, func() *jkv.StatusCmd { return c.Inner.FlushDB(ctx) })
+}
+
+func (c *Client) Get(ctx context.Context, key string) *jkv.StringCmd {
+	return do(ctx, c, func() *jkv.StringCmd { return c.Inner.Get(ctx, key) })
+}
+
+func (c *Client) GetEX(ctx context.Context, key string, opts jkv.ExpiryOptions) *jkv.StringCmd {
+	return do(ctx, c, func() *jkv.StringCmd { return c.Inner.GetEX(ctx, key, opts) })
+}
+
+func (c *Client) Set(ctx context.Context, key, value string, expiration time.Duration) *jkv.StatusCmd {
+	return do(ctx, c, func() *jkv.StatusCmd { return c.Inner.Set(ctx, key, value, expiration) })
+}
+
+func (c *Client) Del(ctx context.Context, keys ...string) *jkv.IntCmd {
+	return doOnce(ctx, c, func() *jkv.IntCmd { return c.Inner.Del(ctx, keys...) })
+}
+
+func (c *Client) SetNX(ctx context.Context, key, value string, expiration time.Duration) *jkv.BoolCmd {
+	return doOnce(ctx, c, func() *jkv.BoolCmd { return c.Inner.SetNX(ctx, key, value, expiration) })
+}
+
+func (c *Client) CompareAndDelete(ctx context.Context, key, value string) *jkv.BoolCmd {
+	return doOnce(ctx, c, func() *jkv.BoolCmd { return c.Inner.CompareAndDelete(ctx, key, value) })
+}
+
+func (c *Client) Incr(ctx context.Context, key string) *jkv.IntCmd {
+	return doOnce(ctx, c, func() *jkv.IntCmd { return c.Inner.Incr(ctx, key) })
+}
+
+func (c *Client) Decr(ctx context.Context, key string) *jkv.IntCmd {
+	return doOnce(ctx, c, func() *jkv.IntCmd { return c.Inner.Decr(ctx, key) })
+}
+
+func (c *Client) SetBit(ctx context.Context, key string, offset int64, value int) *jkv.IntCmd {
+	return doOnce(ctx, c, func() *jkv.IntCmd { return c.Inner.SetBit(ctx, key, offset, value) })
+}
+
+func (c *Client) GetBit(ctx context.Context, key string, offset int64) *jkv.IntCmd {
+	return do(ctx, c, func() *jkv.IntCmd { return c.Inner.GetBit(ctx, key, offset) })
+}
+
+func (c *Client) BitCount(ctx context.Context, key string, bitCount *jkv.BitCount) *jkv.IntCmd {
+	return do(ctx, c, func() *jkv.IntCmd { return c.Inner.BitCount(ctx, key, bitCount) })
+}
+
+func (c *Client) PFAdd(ctx context.Context, key string, elements ...string) *jkv.IntCmd {
+	return doOnce(ctx, c, func() *jkv.IntCmd { return c.Inner.PFAdd(ctx, key, elements...) })
+}
+
+func (c *Client) PFCount(ctx context.Context, keys ...string) *jkv.IntCmd {
+	return do(ctx, c, func() *jkv.IntCmd { return c.Inner.PFCount(ctx, keys...) })
+}
+
+func (c *Client) PFMerge(ctx context.Context, dest string, keys ...string) *jkv.StatusCmd {
+	return do(ctx, c, func() *jkv.StatusCmd { return c.Inner.PFMerge(ctx, dest, keys...) })
+}
+
+func (c *Client) Keys(ctx context.Context, pattern string) *jkv.StringSliceCmd {
+	return do(ctx, c, func() *jkv.StringSliceCmd { return c.Inner.Keys(ctx, pattern) })
+}
+
+func (c *Client) Scan(ctx context.Context, cursor string, match string, count int64) *jkv.ScanCmd {
+	return do(ctx, c, func() *jkv.ScanCmd { return c.Inner.Scan(ctx, cursor, match, count) })
+}
+
+func (c *Client) ScanType(ctx context.Context, cursor string, match string, count int64, keyType string) *jkv.ScanCmd {
+	return do(ctx, c, func() *jkv.ScanCmd { return c.Inner.ScanType(ctx, cursor, match, count, keyType) })
+}
+
+func (c *Client) Exists(ctx context.Context, keys ...string) *jkv.IntCmd {
+	return do(ctx, c, func() *jkv.IntCmd { return c.Inner.Exists(ctx, keys...) })
+}
+
+func (c *Client) Expire(ctx context.Context, key string, expiration time.Duration) *jkv.BoolCmd {
+	return doOnce(ctx, c, func() *jkv.BoolCmd { return c.Inner.Expire(ctx, key, expiration) })
+}
+
+func (c *Client) TTL(ctx context.Context, key string) *jkv.IntCmd {
+	return do(ctx, c, func() *jkv.IntCmd { return c.Inner.TTL(ctx, key) })
+}
+
+func (c *Client) HGet(ctx context.Context, hash, key string) *jkv.StringCmd {
+	return do(ctx, c, func() *jkv.StringCmd { return c.Inner.HGet(ctx, hash, key) })
+}
+
+func (c *Client) HSet(ctx context.Context, hash string, values ...string) *jkv.IntCmd {
+	return doOnce(ctx, c, func() *jkv.IntCmd { return c.Inner.HSet(ctx, hash, values...) })
+}
+
+func (c *Client) HDel(ctx context.Context, hash string, values ...string) *jkv.IntCmd {
+	return doOnce(ctx, c, func() *jkv.IntCmd { return c.Inner.HDel(ctx, hash, values...) })
+}
+
+func (c *Client) HKeys(ctx context.Context, hash string) *jkv.StringSliceCmd {
+	return do(ctx, c, func() *jkv.StringSliceCmd { return c.Inner.HKeys(ctx, hash) })
+}
+
+func (c *Client) HKeysMatch(ctx context.Context, hash, pattern string) *jkv.StringSliceCmd {
+	return do(ctx, c, func() *jkv.StringSliceCmd { return c.Inner.HKeysMatch(ctx, hash, pattern) })
+}
+
+func (c *Client) HGetAll(ctx context.Context, hash string) *jkv.StringStringMapCmd {
+	return do(ctx, c, func() *jkv.StringStringMapCmd { return c.Inner.HGetAll(ctx, hash) })
+}
+
+func (c *Client) HGetAllMatch(ctx context.Context, hash, pattern string) *jkv.StringStringMapCmd {
+	return do(ctx, c, func() *jkv.StringStringMapCmd { return c.Inner.HGetAllMatch(ctx, hash, pattern) })
+}
+
+func (c *Client) HExists(ctx context.Context, hash, key string) *jkv.BoolCmd {
+	return do(ctx, c, func() *jkv.BoolCmd { return c.Inner.HExists(ctx, hash, key) })
+}
+
+func (c *Client) HExpire(ctx context.Context, hash string, seconds int64, fields ...string) *jkv.IntSliceCmd {
+	return doOnce(ctx, c, func() *jkv.IntSliceCmd { return c.Inner.HExpire(ctx, hash, seconds, fields...) })
+}
+
+func (c *Client) HTTL(ctx context.Context, hash string, fields ...string) *jkv.IntSliceCmd {
+	return do(ctx, c, func() *jkv.IntSliceCmd { return c.Inner.HTTL(ctx, hash, fields...) })
+}
+
+func (c *Client) Ping(ctx context.Context) *jkv.StatusCmd {
+	return do(ctx, c, func() *jkv.StatusCmd { return c.Inner.Ping(ctx) })
+}
+
+func (c *Client) Version(ctx context.Context) *jkv.StringCmd {
+	return do(ctx, c, func() *jkv.StringCmd { return c.Inner.Version(ctx) })
+}
+
+func (c *Client) Info(ctx context.Context, sections ...string) *jkv.StringCmd {
+	return do(ctx, c, func() *jkv.StringCmd { return c.Inner.Info(ctx, sections...) })
+}
+
+func (c *Client) ConfigGet(ctx context.Context, parameter string) *jkv.StringStringMapCmd {
+	return do(ctx, c, func() *jkv.StringStringMapCmd { return c.Inner.ConfigGet(ctx, parameter) })
+}
+
+func (c *Client) Do(ctx context.Context, args ...interface{}) *jkv.Cmd {
+	return doOnce(ctx, c, func() *jkv.Cmd { return c.Inner.Do(ctx, args...) })
+}
+
+var _ jkv.Client = (*Client)(nil)
@@ -0,0 +1,130 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/panduit-joeb/jkv"
+	"github.com/stretchr/testify/assert"
+)
+
+// flaky fails Get with err until it has been called failures times
+type flaky struct {
+	jkv.Client
+	err      error
+	failures int
+	calls    int
+}
+
+func (f *flaky) Get(ctx context.Context, key string) *jkv.StringCmd {
+	f.calls++
+	if f.calls <= f.failures {
+		return jkv.NewStringCmd("", f.err)
+	}
+	return jkv.NewStringCmd("value", nil)
+}
+
+func (f *flaky) Incr(ctx context.Context, key string) *jkv.IntCmd {
+	f.calls++
+	if f.calls <= f.failures {
+		return jkv.NewIntCmd(0, f.err)
+	}
+	return jkv.NewIntCmd(1, nil)
+}
+
+func (f *flaky) Expire(ctx context.Context, key string, expiration time.Duration) *jkv.BoolCmd {
+	f.calls++
+	if f.calls <= f.failures {
+		return jkv.NewBoolCmd(false, f.err)
+	}
+	return jkv.NewBoolCmd(true, nil)
+}
+
+// timeout is a network timeout, after which a command may or may not have run
+type timeout struct{}
+
+func (timeout) Error() string   { return "i/o timeout" }
+func (timeout) Timeout() bool   { return true }
+func (timeout) Temporary() bool { return true }
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	policy := Policy{Attempts: 3, BaseDelay: time.Millisecond}
+
+	t.Run("Succeeds after two failures", func(t *testing.T) {
+		a := assert.New(t)
+		inner := &flaky{err: jkv.ErrLockTimeout, failures: 2}
+		rec := New(inner, policy).Get(ctx, "key")
+		a.Nil(rec.Err())
+		a.Equal("value", rec.Val())
+		a.Equal(3, inner.calls)
+	})
+
+	t.Run("Gives up after Attempts", func(t *testing.T) {
+		a := assert.New(t)
+		inner := &flaky{err: jkv.ErrLockTimeout, failures: 5}
+		a.ErrorIs(New(inner, policy).Get(ctx, "key").Err(), jkv.ErrLockTimeout)
+		a.Equal(3, inner.calls)
+	})
+
+	t.Run("Fatal errors are not retried", func(t *testing.T) {
+		a := assert.New(t)
+		inner := &flaky{err: jkv.ErrWrongType, failures: 2}
+		a.ErrorIs(New(inner, policy).Get(ctx, "key").Err(), jkv.ErrWrongType)
+		a.Equal(1, inner.calls)
+	})
+
+	t.Run("Stops when ctx is done", func(t *testing.T) {
+		a := assert.New(t)
+		inner := &flaky{err: jkv.ErrLockTimeout, failures: 2}
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		a.NotNil(New(inner, Policy{BaseDelay: time.Hour}).Get(ctx, "key").Err())
+		a.Equal(1, inner.calls)
+	})
+
+	t.Run("Idempotent commands are retried after a timeout", func(t *testing.T) {
+		a := assert.New(t)
+		inner := &flaky{err: timeout{}, failures: 1}
+		a.Nil(New(inner, policy).Get(ctx, "key").Err())
+		a.Equal(2, inner.calls)
+	})
+
+	t.Run("Other commands are not retried after a timeout", func(t *testing.T) {
+		a := assert.New(t)
+		inner := &flaky{err: timeout{}, failures: 1}
+		a.Equal(timeout{}, New(inner, policy).Incr(ctx, "key").Err())
+		a.Equal(1, inner.calls)
+
+		inner = &flaky{err: timeout{}, failures: 1}
+		a.Equal(timeout{}, New(inner, policy).Expire(ctx, "key", time.Minute).Err())
+		a.Equal(1, inner.calls)
+	})
+
+	t.Run("Other commands are retried when they didn't run", func(t *testing.T) {
+		a := assert.New(t)
+		inner := &flaky{err: errors.New("TRYAGAIN Multiple keys request during rehashing of slot"), failures: 1}
+		rec := New(inner, policy).Incr(ctx, "key")
+		a.Nil(rec.Err())
+		a.Equal(int64(1), rec.Val())
+		a.Equal(2, inner.calls)
+	})
+}
+
+func TestRetryable(t *testing.T) {
+	a := assert.New(t)
+	a.True(Retryable(jkv.ErrLockTimeout))
+	a.True(Retryable(errors.New("LOADING Redis is loading the dataset in memory")))
+	a.False(Retryable(nil))
+	a.False(Retryable(jkv.ErrWrongType))
+	a.False(Retryable(jkv.ErrReadOnly))
+	a.True(Retryable(timeout{}))
+
+	a.True(NotRun(jkv.ErrLockTimeout))
+	a.True(NotRun(errors.New("BUSY Redis is busy running a script")))
+	a.False(NotRun(timeout{}))
+	a.False(NotRun(nil))
+	a.False(NotRun(jkv.ErrWrongType))
+}
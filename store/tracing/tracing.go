@@ -0,0 +1,289 @@
+// Package tracing wraps a jkv.Client so each command runs inside a span of a tracer such as OpenTelemetry's
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/panduit-joeb/jkv"
+)
+
+// Attribute is a key and value set on a span, like attribute.String in OpenTelemetry
+type Attribute struct {
+	Key, Value string
+}
+
+// Span is a span started by a Tracer
+type Span interface {
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts spans. It has the shape of an OpenTelemetry trace.Tracer, which fits with a few lines of glue
+// turning the attributes into attribute.String values and calling SetStatus(codes.Error, ...) in RecordError, so
+// jkv itself doesn't depend on the OpenTelemetry modules.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// The attributes of a span, the span is named after the command
+const (
+	AttrCommand = "jkv.command"
+	AttrKey     = "jkv.key" // the first key, left out for commands without one
+	AttrBackend = "jkv.backend"
+)
+
+// Options choose the tracer spans are started with and how the backend is named on them
+type Options struct {
+	Tracer  Tracer // commands go straight to Inner if nil
+	Backend string // the type of Inner if empty, e.g. "fs" for *fs.Client
+}
+
+// Client is a jkv.Client that traces the commands of Inner
+type Client struct {
+	Inner   jkv.Client
+	Options Options
+}
+
+// New returns inner wrapped so each command runs in a span started with opts.Tracer
+func New(inner jkv.Client, opts Options) *Client {
+	if opts.Backend == "" {
+		opts.Backend = strings.TrimSuffix(strings.TrimPrefix(fmt.Sprintf("%T", inner), "*"), ".Client")
+	}
+	return &Client{Inner: inner, Options: opts}
+}
+
+// traced runs fn in a span named cmd, recording the error it returns on the span unless it is a miss
+func traced[T interface{ Err() error }](ctx context.Context, c *Client, cmd string, keys []string, fn func(context.Context) T) T {
+	if c.Options.Tracer == nil {
+		return fn(ctx)
+	}
+	attrs := []Attribute{{AttrCommand, cmd}, {AttrBackend, c.Options.Backend}}
+	if len(keys) > 0 {
+		attrs = append(attrs, Attribute{AttrKey, keys[0]})
+	}
+	ctx, span := c.Options.Tracer.Start(ctx, cmd, attrs...)
+	defer span.End()
+	rec := fn(ctx)
+	if err := rec.Err(); err != nil && !errors.Is(err, jkv.Nil) {
+		span.RecordError(err)
+	}
+	return rec
+}
+
+// result adapts a plain error to traced
+type result struct{ err error }
+
+func (r result) Err() error { return r.err }
+
+func (c *Client) Open() error {
+	return traced(context.Background(), c, "OPEN", nil, func(context.Context) result { return result{c.Inner.Open()} }).err
+}
+
+func (c *Client) Close()           { c.Inner.Close() }
+func (c *Client) GetDBDir() string { return c.Inner.GetDBDir() }
+
+func (c *Client) Capabilities() jkv.Capabilities { return c.Inner.Capabilities() }
+
+func (c *Client) FlushDB(ctx context.Context) *jkv.StatusCmd {
+	return traced(ctx, c, "FLUSHDB", nil, func(ctx context.Context) *jkv.StatusCmd { return c.Inner.FlushDB(ctx) })
+}
+
+func (c *Client) Get(ctx context.Context, key string) *jkv.StringCmd {
+	return traced(ctx, c, "GET", []string{key}, func(ctx context.Context) *jkv.StringCmd { return c.Inner.Get(ctx, key) })
+}
+
+func (c *Client) GetEX(ctx context.Context, key string, opts jkv.ExpiryOptions) *jkv.StringCmd {
+	return traced(ctx, c, "GETEX", []string{key}, func(ctx context.Context) *jkv.StringCmd {
+		return c.Inner.GetEX(ctx, key, opts)
+	})
+}
+
+func (c *Client) Set(ctx context.Context, key, value string, expiration time.Duration) *jkv.StatusCmd {
+	return traced(ctx, c, "SET", []string{key}, func(ctx context.Context) *jkv.StatusCmd {
+		return c.Inner.Set(ctx, key, value, expiration)
+	})
+}
+
+func (c *Client) Del(ctx context.Context, keys ...string) *jkv.IntCmd {
+	return traced(ctx, c, "DEL", keys, func(ctx context.Context) *jkv.IntCmd { return c.Inner.Del(ctx, keys...) })
+}
+
+func (c *Client) SetNX(ctx context.Context, key, value string, expiration time.Duration) *jkv.BoolCmd {
+	return traced(ctx, c, "SETNX", []string{key}, func(ctx context.Context) *jkv.BoolCmd {
+		return c.Inner.SetNX(ctx, key, value, expiration)
+	})
+}
+
+func (c *Client) CompareAndDelete(ctx context.Context, key, value string) *jkv.BoolCmd {
+	return traced(ctx, c, "CAD", []string{key}, func(ctx context.Context) *jkv.BoolCmd {
+		return c.Inner.CompareAndDelete(ctx, key, value)
+	})
+}
+
+func (c *Client) Incr(ctx context.Context, key string) *jkv.IntCmd {
+	return traced(ctx, c, "INCR", []string{key}, func(ctx context.Context) *jkv.IntCmd { return c.Inner.Incr(ctx, key) })
+}
+
+func (c *Client) Decr(ctx context.Context, key string) *jkv.IntCmd {
+	return traced(ctx, c, "DECR", []string{key}, func(ctx context.Context) *jkv.IntCmd { return c.Inner.Decr(ctx, key) })
+}
+
+func (c *Client) SetBit(ctx context.Context, key string, offset int64, value int) *jkv.IntCmd {
+	return traced(ctx, c, "SETBIT", []string{key}, func(ctx context.Context) *jkv.IntCmd {
+		return c.Inner.SetBit(ctx, key, offset, value)
+	})
+}
+
+func (c *Client) GetBit(ctx context.Context, key string, offset int64) *jkv.IntCmd {
+	return traced(ctx, c, "GETBIT", []string{key}, func(ctx context.Context) *jkv.IntCmd {
+		return c.Inner.GetBit(ctx, key, offset)
+	})
+}
+
+func (c *Client) BitCount(ctx context.Context, key string, bitCount *jkv.BitCount) *jkv.IntCmd {
+	return traced(ctx, c, "BITCOUNT", []string{key}, func(ctx context.Context) *jkv.IntCmd {
+		return c.Inner.BitCount(ctx, key, bitCount)
+	})
+}
+
+func (c *Client) PFAdd(ctx context.Context, key string, elements ...string) *jkv.IntCmd {
+	return traced(ctx, c, "PFADD", []string{key}, func(ctx context.Context) *jkv.IntCmd {
+		return c.Inner.PFAdd(ctx, key, elements...)
+	})
+}
+
+func (c *Client) PFCount(ctx context.Context, keys ...string) *jkv.IntCmd {
+	return traced(ctx, c, "PFCOUNT", keys, func(ctx context.Context) *jkv.IntCmd { return c.Inner.PFCount(ctx, keys...) })
+}
+
+func (c *Client) PFMerge(ctx context.Context, dest string, keys ...string) *jkv.StatusCmd {
+	return traced(ctx, c, "PFMERGE", []string{dest}, func(ctx context.Context) *jkv.StatusCmd {
+		return c.Inner.PFMerge(ctx, dest, keys...)
+	})
+}
+
+func (c *Client) Keys(ctx context.Context, pattern string) *jkv.StringSliceCmd {
+	return traced(ctx, c, "KEYS", nil, func(ctx context.Context) *jkv.StringSliceCmd { return c.Inner.Keys(ctx, pattern) })
+}
+
+func (c *Client) Scan(ctx context.Context, cursor string, match string, count int64) *jkv.ScanCmd {
+	return traced(ctx, c, "SCAN", nil, func(ctx context.Context) *jkv.ScanCmd {
+		return c.Inner.Scan(ctx, cursor, match, count)
+	})
+}
+
+func (c *Client) ScanType(ctx context.Context, cursor string, match string, count int64, keyType string) *jkv.ScanCmd {
+	return traced(ctx, c, "SCAN", nil, func(ctx context.Context) *jkv.ScanCmd {
+		return c.Inner.ScanType(ctx, cursor, match, count, keyType)
+	})
+}
+
+func (c *Client) Exists(ctx context.Context, keys ...string) *jkv.IntCmd {
+	return traced(ctx, c, "EXISTS", keys, func(ctx context.Context) *jkv.IntCmd { return c.Inner.Exists(ctx, keys...) })
+}
+
+func (c *Client) Expire(ctx context.Context, key string, expiration time.Duration) *jkv.BoolCmd {
+	return traced(ctx, c, "EXPIRE", []string{key}, func(ctx context.Context) *jkv.BoolCmd {
+		return c.Inner.Expire(ctx, key, expiration)
+	})
+}
+
+func (c *Client) TTL(ctx context.Context, key string) *jkv.IntCmd {
+	return traced(ctx, c, "TTL", []string{key}, func(ctx context.Context) *jkv.IntCmd { return c.Inner.TTL(ctx, key) })
+}
+
+func (c *Client) HGet(ctx context.Context, hash, key string) *jkv.StringCmd {
+	return traced(ctx, c, "HGET", []string{hash}, func(ctx context.Context) *jkv.StringCmd {
+		return c.Inner.HGet(ctx, hash, key)
+	})
+}
+
+func (c *Client) HSet(ctx context.Context, hash string, values ...string) *jkv.IntCmd {
+	return traced(ctx, c, "HSET", []string{hash}, func(ctx context.Context) *jkv.IntCmd {
+		return c.Inner.HSet(ctx, hash, values...)
+	})
+}
+
+func (c *Client) HDel(ctx context.Context, hash string, values ...string) *jkv.IntCmd {
+	return traced(ctx, c, "HDEL", []string{hash}, func(ctx context.Context) *jkv.IntCmd {
+		return c.Inner.HDel(ctx, hash, values...)
+	})
+}
+
+func (c *Client) HKeys(ctx context.Context, hash string) *jkv.StringSliceCmd {
+	return traced(ctx, c, "HKEYS", []string{hash}, func(ctx context.Context) *jkv.StringSliceCmd {
+		return c.Inner.HKeys(ctx, hash)
+	})
+}
+
+func (c *Client) HKeysMatch(ctx context.Context, hash, pattern string) *jkv.StringSliceCmd {
+	return traced(ctx, c, "HKEYS", []string{hash}, func(ctx context.Context) *jkv.StringSliceCmd {
+		return c.Inner.HKeysMatch(ctx, hash, pattern)
+	})
+}
+
+func (c *Client) HGetAll(ctx context.Context, hash string) *jkv.StringStringMapCmd {
+	return traced(ctx, c, "HGETALL", []string{hash}, func(ctx context.Context) *jkv.StringStringMapCmd {
+		return c.Inner.HGetAll(ctx, hash)
+	})
+}
+
+func (c *Client) HGetAllMatch(ctx context.Context, hash, pattern string) *jkv.StringStringMapCmd {
+	return traced(ctx, c, "HGETALL", []string{hash}, func(ctx context.Context) *jkv.StringStringMapCmd {
+		return c.Inner.HGetAllMatch(ctx, hash, pattern)
+	})
+}
+
+func (c *Client) HExists(ctx context.Context, hash, key string) *jkv.BoolCmd {
+	return traced(ctx, c, "HEXISTS", []string{hash}, func(ctx context.Context) *jkv.BoolCmd {
+		return c.Inner.HExists(ctx, hash, key)
+	})
+}
+
+func (c *Client) HExpire(ctx context.Context, hash string, seconds int64, fields ...string) *jkv.IntSliceCmd {
+	return traced(ctx, c, "HEXPIRE", []string{hash}, func(ctx context.Context) *jkv.IntSliceCmd {
+		return c.Inner.HExpire(ctx, hash, seconds, fields...)
+	})
+}
+
+func (c *Client) HTTL(ctx context.Context, hash string, fields ...string) *jkv.IntSliceCmd {
+	return traced(ctx, c, "HTTL", []string{hash}, func(ctx context.Context) *jkv.IntSliceCmd {
+		return c.Inner.HTTL(ctx, hash, fields...)
+	})
+}
+
+func (c *Client) Ping(ctx context.Context) *jkv.StatusCmd {
+	return traced(ctx, c, "PING", nil, func(ctx context.Context) *jkv.StatusCmd { return c.Inner.Ping(ctx) })
+}
+
+func (c *Client) Version(ctx context.Context) *jkv.StringCmd {
+	return traced(ctx, c, "VERSION", nil, func(ctx context.Context) *jkv.StringCmd { return c.Inner.Version(ctx) })
+}
+
+func (c *Client) Info(ctx context.Context, sections ...string) *jkv.StringCmd {
+	return traced(ctx, c, "INFO", nil, func(ctx context.Context) *jkv.StringCmd { return c.Inner.Info(ctx, sections...) })
+}
+
+func (c *Client) ConfigGet(ctx context.Context, parameter string) *jkv.StringStringMapCmd {
+	return traced(ctx, c, "CONFIG GET", nil, func(ctx context.Context) *jkv.StringStringMapCmd {
+		return c.Inner.ConfigGet(ctx, parameter)
+	})
+}
+
+// Do names its span after the command in args[0], with args[1] as the key
+func (c *Client) Do(ctx context.Context, args ...interface{}) *jkv.Cmd {
+	cmd, keys := "DO", []string{}
+	if len(args) > 0 {
+		cmd = strings.ToUpper(fmt.Sprint(args[0]))
+	}
+	if len(args) > 1 {
+		keys = append(keys, fmt.Sprint(args[1]))
+	}
+	return traced(ctx, c, cmd, keys, func(ctx context.Context) *jkv.Cmd { return c.Inner.Do(ctx, args...) })
+}
+
+var _ jkv.Client = (*Client)(nil)
@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/panduit-joeb/jkv"
+	"github.com/panduit-joeb/jkv/store/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder keeps the spans it starts in memory
+type recorder struct {
+	mu    sync.Mutex
+	spans []*span
+}
+
+type span struct {
+	name  string
+	attrs map[string]string
+	err   error
+	ended bool
+}
+
+func (s *span) RecordError(err error) { s.err = err }
+func (s *span) End()                  { s.ended = true }
+
+func (r *recorder) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	s := &span{name: name, attrs: map[string]string{}}
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+	return ctx, s
+}
+
+func TestTracing(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	inner := fs.NewClient(&fs.Options{Addr: t.TempDir()})
+	rec := &recorder{}
+	c := New(inner, Options{Tracer: rec})
+	a.Nil(c.Open())
+	defer c.Close()
+	a.Nil(c.Set(ctx, "key", "value", 0).Err())
+
+	rec.spans = nil
+	a.Equal("value", c.Get(ctx, "key").Val())
+	a.Len(rec.spans, 1)
+	a.Equal("GET", rec.spans[0].name)
+	a.Equal(map[string]string{AttrCommand: "GET", AttrKey: "key", AttrBackend: "fs"}, rec.spans[0].attrs)
+	a.True(rec.spans[0].ended)
+	a.Nil(rec.spans[0].err)
+
+	// a miss isn't an error, a failure is
+	a.ErrorIs(c.Get(ctx, "missing").Err(), jkv.Nil)
+	a.Nil(rec.spans[1].err)
+	a.Error(c.HSet(ctx, "key", "field", "value").Err())
+	a.Equal("HSET", rec.spans[2].name)
+	a.Error(rec.spans[2].err)
+
+	rec.spans = nil
+	a.Nil(c.Do(ctx, "set", "other", "value").Err())
+	a.Equal("SET", rec.spans[0].name)
+	a.Equal("other", rec.spans[0].attrs[AttrKey])
+
+	// without a tracer commands go straight through
+	plain := New(inner, Options{Backend: "files"})
+	a.Equal("value", plain.Get(ctx, "key").Val())
+	a.Equal("files", plain.Options.Backend)
+}